@@ -24,6 +24,11 @@ func buildCheckCoverageCommand(fs afero.Fs) *cobra.Command {
 				return fmt.Errorf("loading config: %w", err)
 			}
 
+			err = internal.ValidateLocalSources(cfg)
+			if err != nil {
+				return fmt.Errorf("checking coverage: %w", err)
+			}
+
 			checker := &internal.CoverageChecker{
 				Logger: slog.New(internal.NewUTCTextHandler(os.Stderr)),
 				Fs:     fs,
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"backup-rsync/backup/internal"
+	"encoding/json"
 	"fmt"
+	"io"
 	"slices"
 
 	"github.com/spf13/cobra"
@@ -25,14 +27,6 @@ func buildConfigCommand() *cobra.Command {
 				fmt.Fprintf(cmd.OutOrStdout(), "Resolved Configuration:\n%s\n", cfg)
 			},
 		},
-		{
-			use:    "validate",
-			short:  "Validate configuration",
-			errCtx: "validating config",
-			success: func(cmd *cobra.Command, _ internal.Config) {
-				fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid.")
-			},
-		},
 	}
 	configCmd := &cobra.Command{
 		Use:   "config",
@@ -47,9 +41,142 @@ func buildConfigCommand() *cobra.Command {
 		})
 	}
 
+	configCmd.AddCommand(buildConfigValidateCommand(), buildConfigDiffCommand(), buildConfigSchemaCommand())
+
 	return configCmd
 }
 
+func buildConfigValidateCommand() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate configuration",
+		Long: "Check the configuration and report every problem found, grouped by job and mapping,\n" +
+			"with the file, line and column it was found at. Warnings, such as missing filter files,\n" +
+			"do not make the configuration invalid. --format json writes the report for CI. The exit\n" +
+			"code is 1 if the configuration has errors.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("%w: %q (supported: text, json)", internal.ErrUnknownValidationFormat, format)
+			}
+
+			configPath, _ := cmd.Flags().GetString("config")
+
+			_, diagnostics := internal.ValidateConfigFile(configPath, parseSetFlags(cmd))
+
+			err := writeValidation(cmd.OutOrStdout(), format, diagnostics)
+			if err != nil {
+				return err
+			}
+
+			if err := diagnostics.Err(); err != nil {
+				// The report already lists the errors.
+				cmd.SilenceUsage, cmd.SilenceErrors = true, true
+
+				return &ExitError{Code: 1, Err: fmt.Errorf("validating config: %w", err)}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+// validationReport is the report of config validate --format json.
+type validationReport struct {
+	Valid       bool                 `json:"valid"`
+	Errors      int                  `json:"errors"`
+	Warnings    int                  `json:"warnings"`
+	Diagnostics internal.Diagnostics `json:"diagnostics"`
+}
+
+func writeValidation(out io.Writer, format string, diagnostics internal.Diagnostics) error {
+	errs := diagnostics.Count(internal.SeverityError)
+
+	if format == "json" {
+		if diagnostics == nil {
+			diagnostics = internal.Diagnostics{}
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(validationReport{
+			Valid: errs == 0, Errors: errs, Warnings: diagnostics.Count(internal.SeverityWarning),
+			Diagnostics: diagnostics,
+		})
+		if err != nil {
+			return fmt.Errorf("writing validation report: %w", err)
+		}
+
+		return nil
+	}
+
+	if len(diagnostics) > 0 {
+		for line := range slices.Values(diagnostics.Report()) {
+			fmt.Fprintln(out, line)
+		}
+	}
+
+	if errs == 0 {
+		fmt.Fprintln(out, "Configuration is valid.")
+	}
+
+	return nil
+}
+
+func buildConfigDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [other.yaml]",
+		Short: "Compare the jobs of two resolved configurations",
+		Long: "Resolve the configuration and other.yaml, or the configuration with the variables given\n" +
+			"with --after, and list the jobs added, removed or changed: their source and target,\n" +
+			"filter rules and delete and enabled flags. Changes after which a job deletes files it\n" +
+			"did not delete before are marked with \"!\".",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, _ := cmd.Flags().GetString("config")
+			overrides := parseSetFlags(cmd)
+			after := parseOverrideFlag(cmd, "after")
+
+			if len(args) == 0 && len(after) == 0 {
+				return fmt.Errorf("%w: give another configuration or --after overrides", internal.ErrNothingToDiff)
+			}
+
+			afterPath := configPath
+			if len(args) > 0 {
+				afterPath = args[0]
+			}
+
+			before, err := internal.LoadResolvedConfig(configPath, overrides)
+			if err != nil {
+				return fmt.Errorf("loading config %s: %w", configPath, err)
+			}
+
+			resolved, err := internal.LoadResolvedConfig(afterPath, overrides, after)
+			if err != nil {
+				return fmt.Errorf("loading config %s: %w", afterPath, err)
+			}
+
+			for line := range slices.Values(internal.DiffConfigs(before, resolved).Lines()) {
+				fmt.Fprintln(cmd.OutOrStdout(), line)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArray("after", nil,
+		"Compare with the configuration after setting a variable (key=value), can be repeated")
+
+	return cmd
+}
+
 func configRunE(verb configVerb) func(*cobra.Command, []string) error {
 	return func(cmd *cobra.Command, args []string) error {
 		configPath, _ := cmd.Flags().GetString("config")
@@ -65,3 +192,24 @@ func configRunE(verb configVerb) func(*cobra.Command, []string) error {
 		return nil
 	}
 }
+
+func buildConfigSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration format",
+		Long: "Print a JSON Schema of configuration files, for editors to validate and complete them,\n" +
+			"e.g. with a \"# yaml-language-server: $schema=backup.schema.json\" comment.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+
+			err := encoder.Encode(internal.ConfigSchema())
+			if err != nil {
+				return fmt.Errorf("writing schema: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"backup-rsync/backup/internal"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/afero"
@@ -25,11 +31,32 @@ type jobCommandOptions struct {
 	short        string
 	factory      func(rsyncPath string, logPath string, out io.Writer) internal.JobCommand
 	createLogger LoggerFactory
+	// preflight enables the preflight checks of the jobs.
+	preflight bool
+	// report enables the --report-format flag, which writes a run report to the log directory.
+	report bool
+	// metrics enables the --metrics-file flag, which writes the metrics of the run for Prometheus.
+	metrics bool
+	// history appends the results of the run to the history of the config.
+	history bool
+	// notify sends the notifications of the config after the run, running command
+	// notifiers through it. Nil sends none.
+	notify internal.Exec
+	// lock keeps runs of the same config, and with lock_targets of the same targets,
+	// from overlapping, and enables the --wait and --no-wait flags.
+	lock bool
+	// finish runs after all jobs, whether or not they succeeded.
+	finish func(fs afero.Fs, logPath string, out io.Writer) error
 }
 
 // parseSetFlags parses --set flag values (key=value) into a map.
 func parseSetFlags(cmd *cobra.Command) map[string]string {
-	setFlags, _ := cmd.Flags().GetStringArray("set")
+	return parseOverrideFlag(cmd, "set")
+}
+
+// parseOverrideFlag parses the key=value values of a variable override flag into a map.
+func parseOverrideFlag(cmd *cobra.Command, name string) map[string]string {
+	setFlags, _ := cmd.Flags().GetStringArray(name)
 	overrides := make(map[string]string, len(setFlags))
 
 	for s := range slices.Values(setFlags) {
@@ -43,20 +70,62 @@ func parseSetFlags(cmd *cobra.Command) map[string]string {
 }
 
 func buildJobCommand(fs afero.Fs, opts jobCommandOptions) *cobra.Command {
-	return &cobra.Command{
-		Use:   opts.use,
+	cmd := &cobra.Command{
+		Use:   opts.use + " [job...]",
 		Short: opts.short,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			configPath, _ := cmd.Flags().GetString("config")
 			rsyncPath, _ := cmd.Flags().GetString("rsync-path")
 			overrides := parseSetFlags(cmd)
+			applyOpts := parseApplyFlags(cmd)
+
+			if opts.preflight {
+				applyOpts.Preflight = &internal.PreflightChecker{Fs: fs, Volumes: internal.OsVolumeInfo{}}
+			}
+
+			reportFormat, err := parseReportFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			metricsPath, _ := cmd.Flags().GetString("metrics-file")
+
+			if reportFormat != "" || metricsPath != "" || opts.history || opts.notify != nil {
+				applyOpts.Report = internal.NewRunReport(opts.use, configPath)
+			}
 
 			cfg, err := internal.LoadResolvedConfig(configPath, overrides)
 			if err != nil {
 				return fmt.Errorf("loading config: %w", err)
 			}
 
+			cfg, err = parseSelectorFlags(cmd, args).Select(cfg)
+			if err != nil {
+				return fmt.Errorf("selecting jobs: %w", err)
+			}
+
 			out := cmd.OutOrStdout()
+			if applyOpts.Parallel > 1 {
+				out = internal.NewSyncWriter(out)
+			}
+
+			// Stop on SIGINT/SIGTERM: the running rsync receives SIGTERM and the
+			// remaining jobs are reported as cancelled.
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if opts.lock {
+				locks, err := acquireLocks(ctx, cmd, opts.use, configPath, cfg, out)
+				if err != nil {
+					return err
+				}
+
+				defer func() {
+					if releaseErr := internal.ReleaseLocks(locks); releaseErr != nil {
+						fmt.Fprintf(out, "Warning: %v\n", releaseErr)
+					}
+				}()
+			}
 
 			createLogger := opts.createLogger
 			if createLogger == nil {
@@ -72,7 +141,194 @@ func buildJobCommand(fs afero.Fs, opts jobCommandOptions) *cobra.Command {
 
 			command := opts.factory(rsyncPath, logPath, out)
 
-			return cfg.Apply(command, logger)
+			// The outcome of the jobs, which notifications report. Failing to write the run
+			// report or metrics fails the command without turning the run into a failure.
+			runErr := cfg.Apply(ctx, command, logger, applyOpts)
+			err = runErr
+
+			if opts.finish != nil {
+				err = errors.Join(err, opts.finish(fs, logPath, out))
+			}
+
+			if opts.history {
+				history := internal.History{Fs: fs, Path: internal.HistoryPath(configPath)}
+				if historyErr := history.Append(applyOpts.Report); historyErr != nil {
+					fmt.Fprintf(out, "Warning: Failed to record run history: %v\n", historyErr)
+				}
+			}
+
+			if reportFormat != "" {
+				err = errors.Join(err, writeRunReport(fs, logPath, applyOpts.Report, reportFormat, out))
+			}
+
+			if metricsPath != "" {
+				err = errors.Join(err, writeMetrics(fs, configPath, cfg, applyOpts.Report, metricsPath, opts.history, out))
+			}
+
+			if opts.notify != nil {
+				sendNotifications(context.WithoutCancel(ctx), cfg, applyOpts.Report, runErr, opts.notify, out)
+			}
+
+			return err
 		},
 	}
+
+	if opts.report {
+		cmd.Flags().String("report-format", "",
+			"Write a run report to the log directory (format: json or junit)")
+	}
+
+	if opts.metrics {
+		cmd.Flags().String("metrics-file", "",
+			"Write Prometheus metrics of the run to this file, e.g. for the node_exporter textfile collector")
+	}
+
+	if opts.lock {
+		cmd.Flags().Bool("wait", false, "Wait for another run holding the locks of the config or its targets to finish")
+		cmd.Flags().Bool("no-wait", false, "Fail if another run holds the locks of the config or its targets (default)")
+		cmd.MarkFlagsMutuallyExclusive("wait", "no-wait")
+	}
+
+	cmd.Flags().StringArray("job", nil, "Select a job by name or glob pattern, can be repeated")
+	cmd.Flags().StringArray("mapping", nil, "Select the jobs of a mapping by name or glob pattern, can be repeated")
+	cmd.Flags().StringArray("tag", nil, "Select the jobs carrying a tag, can be repeated")
+	cmd.Flags().StringArray("exclude-job", nil, "Exclude a job by name or glob pattern, can be repeated")
+	cmd.Flags().Int("parallel", 1, "Number of jobs to run concurrently")
+	cmd.Flags().Int("parallel-per-target", 1, "Maximum concurrent jobs per mapping target (0 for no limit)")
+
+	return cmd
+}
+
+// parseSelectorFlags combines positional job names with the job selection flags.
+func parseSelectorFlags(cmd *cobra.Command, args []string) internal.JobSelector {
+	jobs, _ := cmd.Flags().GetStringArray("job")
+	mappings, _ := cmd.Flags().GetStringArray("mapping")
+	tags, _ := cmd.Flags().GetStringArray("tag")
+	exclude, _ := cmd.Flags().GetStringArray("exclude-job")
+
+	return internal.JobSelector{
+		Names:    slices.Concat(args, jobs),
+		Mappings: mappings,
+		Tags:     tags,
+		Exclude:  exclude,
+	}
+}
+
+// parseReportFormat reads --report-format, returning "" when no report is requested.
+func parseReportFormat(cmd *cobra.Command) (internal.ReportFormat, error) {
+	format, _ := cmd.Flags().GetString("report-format")
+	if format == "" {
+		return "", nil
+	}
+
+	reportFormat, err := internal.ParseReportFormat(format)
+	if err != nil {
+		return "", fmt.Errorf("invalid --report-format: %w", err)
+	}
+
+	return reportFormat, nil
+}
+
+func writeRunReport(
+	fs afero.Fs, logPath string, report *internal.RunReport, format internal.ReportFormat, out io.Writer,
+) error {
+	path := filepath.Join(logPath, format.FileName())
+
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, internal.LogFilePermission)
+	if err != nil {
+		return fmt.Errorf("writing run report: %w", err)
+	}
+	defer file.Close()
+
+	err = report.Write(file, format)
+	if err != nil {
+		return fmt.Errorf("writing run report: %w", err)
+	}
+
+	fmt.Fprintf(out, "Run report: %s\n", path)
+
+	return nil
+}
+
+// acquireLocks takes the locks of a run of cfg, waiting for other runs with --wait.
+// Where locking is not supported, the run goes ahead without locks.
+func acquireLocks(
+	ctx context.Context, cmd *cobra.Command, use, configPath string, cfg internal.Config, out io.Writer,
+) ([]*internal.Lock, error) {
+	wait, _ := cmd.Flags().GetBool("wait")
+
+	locks, err := internal.AcquireLocks(ctx, cfg.LockPaths(configPath), internal.NewLockInfo(use, configPath), wait, out)
+	if errors.Is(err, internal.ErrLockUnsupported) {
+		fmt.Fprintf(out, "Warning: Running without locks: %v\n", err)
+
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("acquiring lock: %w", err)
+	}
+
+	for lock := range slices.Values(locks) {
+		if lock.Stale != nil {
+			fmt.Fprintf(out, "Warning: Lock %s was left behind by %s, which did not finish\n", lock.Path, lock.Stale)
+		}
+	}
+
+	return locks, nil
+}
+
+// writeMetrics writes the metrics of the run recorded in report to path. With history,
+// for the command recording its runs in the history of the config, the last successes
+// of the jobs are taken from it.
+func writeMetrics(
+	fs afero.Fs, configPath string, cfg internal.Config, report *internal.RunReport, path string, history bool,
+	out io.Writer,
+) error {
+	var entries []internal.HistoryEntry
+
+	if history {
+		var err error
+
+		entries, err = internal.History{Fs: fs, Path: internal.HistoryPath(configPath)}.Read()
+		if err != nil {
+			return fmt.Errorf("writing metrics: %w", err)
+		}
+	}
+
+	err := internal.NewMetrics(report, cfg, entries).WriteFile(fs, path)
+	if err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+
+	fmt.Fprintf(out, "Metrics: %s\n", path)
+
+	return nil
+}
+
+// sendNotifications sends the notifications of cfg for the run recorded in report, which
+// failed with runErr if it is not nil. Failures are reported as warnings: they do not
+// change the outcome of the run.
+func sendNotifications(
+	ctx context.Context, cfg internal.Config, report *internal.RunReport, runErr error, shell internal.Exec,
+	out io.Writer,
+) {
+	if len(cfg.Notifications) == 0 {
+		return
+	}
+
+	notification := internal.NewRunNotification(report, runErr)
+	notification.Hostname, _ = os.Hostname()
+
+	err := internal.SendNotifications(ctx, cfg.Notifications, notification, shell, out)
+	if err != nil {
+		fmt.Fprintf(out, "Warning: %v\n", err)
+	}
+}
+
+// parseApplyFlags reads the job scheduling flags.
+func parseApplyFlags(cmd *cobra.Command) internal.ApplyOptions {
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	perTarget, _ := cmd.Flags().GetInt("parallel-per-target")
+
+	return internal.ApplyOptions{Parallel: parallel, PerTarget: perTarget}
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"backup-rsync/backup/internal"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func buildVerifyManifestCommand(fs afero.Fs) *cobra.Command {
+	var workers int
+
+	cmd := &cobra.Command{
+		Use:   "verify-manifest [job...]",
+		Short: "Check the targets of jobs against their manifests",
+		Long: "Hash every file of the target of each job with manifest enabled, or of its latest\n" +
+			"snapshot, and compare it with the manifest written by run. Reports files that are\n" +
+			"corrupted (same size and modification time, other content), modified, missing or extra.\n" +
+			"Exits with 2 if any target differs from its manifest, and with 1 if one could not be checked.",
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			if workers < 0 {
+				return fmt.Errorf("%w: --workers must not be negative, got %d", internal.ErrInvalidWorkers, workers)
+			}
+
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobs, err := loadJobsWhere(cmd, args, func(job internal.Job) bool { return job.Manifest },
+				"No jobs with manifest enabled.")
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			checker := manifestChecker{
+				manifests: internal.ManifestStore{Fs: fs, Workers: workers},
+				snapshots: internal.SnapshotStore{Fs: fs},
+				out:       cmd.OutOrStdout(),
+			}
+
+			var (
+				errs       []error
+				mismatched int
+			)
+
+			for job := range slices.Values(jobs) {
+				ok, err := checker.check(ctx, job)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("job %s: %w", job.Name, err))
+				} else if !ok {
+					mismatched++
+				}
+			}
+
+			if len(errs) > 0 {
+				return errors.Join(errs...)
+			}
+
+			if mismatched > 0 {
+				cmd.SilenceUsage = true
+
+				return &ExitError{Code: verifyMismatchExitCode,
+					Err: fmt.Errorf("%w: %d of %d jobs", internal.ErrVerifyMismatch, mismatched, len(jobs))}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&workers, "workers", 0, "Number of files to hash concurrently (default: one per CPU)")
+
+	return cmd
+}
+
+type manifestChecker struct {
+	manifests internal.ManifestStore
+	snapshots internal.SnapshotStore
+	out       io.Writer
+}
+
+// check checks the target of job, or its latest snapshot, against its manifest, printing
+// the differences, and reports whether they match.
+func (c manifestChecker) check(ctx context.Context, job internal.Job) (bool, error) {
+	root := job.Target
+
+	if job.Mode == internal.SnapshotMode {
+		snapshot, err := c.snapshots.Find(job.Target, "")
+		if err != nil {
+			return false, err
+		}
+
+		root = snapshot.Path
+	}
+
+	manifest, err := c.manifests.Read(root)
+	if err != nil {
+		return false, err
+	}
+
+	check, err := c.manifests.Check(ctx, root, manifest)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(c.out, "Job: %s (%s, manifest of %s)\n",
+		job.Name, root, manifest.CreatedAt.Local().Format(time.DateTime))
+
+	for _, difference := range []struct {
+		kind  string
+		paths []string
+	}{
+		{"corrupted", check.Corrupted},
+		{"modified", check.Modified},
+		{"missing", check.Missing},
+		{"extra", check.Extra},
+	} {
+		for path := range slices.Values(difference.paths) {
+			fmt.Fprintf(c.out, "  %s %s\n", difference.kind, path)
+		}
+	}
+
+	if check.OK() {
+		fmt.Fprintf(c.out, "  %d files OK\n", check.Files)
+	} else {
+		fmt.Fprintf(c.out, "  %d files checked, %d differences\n", check.Files, check.Differences())
+	}
+
+	return check.OK(), nil
+}
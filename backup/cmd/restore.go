@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"backup-rsync/backup/internal"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func buildRestoreCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
+	var options internal.RestoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "restore <job>",
+		Short: "Restore the backup of a job",
+		Long: "Restore the backup of a job by running rsync from its target back to its source, or to\n" +
+			"the directory given with --to. Without --apply, the restore is only previewed with\n" +
+			"--dry-run. Files missing from the backup are kept unless --delete is given, and restoring\n" +
+			"into the live source requires --overwrite-source.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, _ := cmd.Flags().GetString("config")
+			rsyncPath, _ := cmd.Flags().GetString("rsync-path")
+
+			job, err := loadRestoreJob(configPath, parseSetFlags(cmd), args[0])
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+
+			command := internal.NewRestoreCommand(rsyncPath, shell, out, options)
+			command.Snapshots = internal.SnapshotStore{Fs: fs}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			result := command.Run(ctx, job)
+			command.ReportJobStatus(job.Name, result, slog.New(slog.DiscardHandler))
+
+			if result.Status != internal.Success && result.Status != internal.Warning {
+				return fmt.Errorf("restoring job %s: %s", job.Name, result.Status)
+			}
+
+			if !options.Apply {
+				fmt.Fprintln(out, "Dry run: nothing was restored, add --apply to restore")
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&options.To, "to", "", "Restore into this directory instead of the job's source")
+	cmd.Flags().StringVar(&options.Path, "path", "", "Restore only this file or directory, relative to the job's source")
+	cmd.Flags().StringVar(&options.At, "at", "",
+		"Restore the snapshot taken at or before this time, e.g. 2026-10-17 or 2026-10-17T03:00 (snapshot mode only)")
+	cmd.Flags().BoolVar(&options.Delete, "delete", false, "Delete files that are not in the backup from the destination")
+	cmd.Flags().BoolVar(&options.Apply, "apply", false, "Perform the restore instead of previewing it")
+	cmd.Flags().BoolVar(&options.OverwriteSource, "overwrite-source", false,
+		"Confirm restoring into the job's live source")
+
+	return cmd
+}
+
+// loadRestoreJob loads the configuration and returns the job named name, which must
+// select exactly one job.
+func loadRestoreJob(configPath string, overrides map[string]string, name string) (internal.Job, error) {
+	cfg, err := internal.LoadResolvedConfig(configPath, overrides)
+	if err != nil {
+		return internal.Job{}, fmt.Errorf("loading config: %w", err)
+	}
+
+	cfg, err = internal.JobSelector{Names: []string{name}}.Select(cfg)
+	if err != nil {
+		return internal.Job{}, fmt.Errorf("selecting jobs: %w", err)
+	}
+
+	jobs := cfg.AllJobs()
+	if len(jobs) != 1 {
+		return internal.Job{}, fmt.Errorf("%w: '%s' matches %d jobs, restore one at a time",
+			internal.ErrInvalidRestore, name, len(jobs))
+	}
+
+	return jobs[0], nil
+}
@@ -33,8 +33,13 @@ func BuildRootCommandWithDeps(fs afero.Fs, shell internal.Exec) *cobra.Command {
 		buildListCommand(shell),
 		buildRunCommand(fs, shell),
 		buildSimulateCommand(fs, shell),
+		buildRestoreCommand(fs, shell),
+		buildVerifyCommand(fs, shell),
+		buildVerifyManifestCommand(fs),
 		buildConfigCommand(),
 		buildCheckCoverageCommand(fs),
+		buildSnapshotsCommand(fs),
+		buildStatusCommand(fs),
 		buildVersionCommand(shell),
 	)
 
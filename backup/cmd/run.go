@@ -11,9 +11,17 @@ import (
 )
 
 func buildRunCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
-	return buildJobCommand(fs, jobCommandOptions{
-		use:   "run",
-		short: "Execute the sync jobs",
+	var force bool
+
+	cmd := buildJobCommand(fs, jobCommandOptions{
+		use:       "run",
+		short:     "Execute the sync jobs",
+		preflight: true,
+		report:    true,
+		metrics:   true,
+		history:   true,
+		notify:    shell,
+		lock:      true,
 		createLogger: func(fs afero.Fs, configPath string, now time.Time) (*slog.Logger, string, func() error, error) {
 			logPath := internal.GetLogPath(configPath, now)
 
@@ -22,7 +30,16 @@ func buildRunCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
 			return logger, logPath, cleanup, err
 		},
 		factory: func(rsyncPath string, logPath string, out io.Writer) internal.JobCommand {
-			return internal.NewSyncCommand(rsyncPath, logPath, shell, out)
+			command := internal.NewSyncCommand(rsyncPath, logPath, shell, out)
+			command.Force = force
+			command.Snapshots = internal.SnapshotStore{Fs: fs}
+			command.Manifests = internal.ManifestStore{Fs: fs}
+
+			return command
 		},
 	})
+
+	cmd.Flags().BoolVar(&force, "force", false, "Run jobs even if they would delete more than their max_delete allows")
+
+	return cmd
 }
@@ -2,18 +2,33 @@ package cmd
 
 import (
 	"backup-rsync/backup/internal"
+	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 )
 
+// changeReportFile is the name of the change report written to the log directory.
+const changeReportFile = "changes.json"
+
 func buildSimulateCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
-	return buildJobCommand(fs, jobCommandOptions{
-		use:   "simulate",
-		short: "Simulate the sync jobs",
+	var (
+		changesReport bool
+		topDeletions  int
+		report        *internal.ChangeReport
+	)
+
+	cmd := buildJobCommand(fs, jobCommandOptions{
+		use:       "simulate",
+		short:     "Simulate the sync jobs",
+		preflight: true,
+		report:    true,
+		metrics:   true,
 		createLogger: func(fs afero.Fs, configPath string, now time.Time) (*slog.Logger, string, func() error, error) {
 			logPath := internal.GetLogPath(configPath, now) + "-sim"
 
@@ -22,7 +37,56 @@ func buildSimulateCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
 			return logger, logPath, cleanup, err
 		},
 		factory: func(rsyncPath string, logPath string, out io.Writer) internal.JobCommand {
-			return internal.NewSimulateCommand(rsyncPath, logPath, shell, out)
+			command := internal.NewSimulateCommand(rsyncPath, logPath, shell, out)
+			command.TopDeletions = topDeletions
+			command.Snapshots = internal.SnapshotStore{Fs: fs}
+
+			if changesReport {
+				report = &internal.ChangeReport{}
+				command.Report = report
+			}
+
+			return command
+		},
+		finish: func(fs afero.Fs, logPath string, out io.Writer) error {
+			if report == nil {
+				return nil
+			}
+
+			return writeChangeReport(fs, filepath.Join(logPath, changeReportFile), report, out)
 		},
 	})
+
+	cmd.PreRunE = func(_ *cobra.Command, _ []string) error {
+		if topDeletions < 0 {
+			return fmt.Errorf("%w: --top-deletions must not be negative, got %d",
+				internal.ErrInvalidTopDeletions, topDeletions)
+		}
+
+		return nil
+	}
+
+	cmd.Flags().BoolVar(&changesReport, "changes-report", false,
+		"Write the full change list as JSON to "+changeReportFile+" in the log directory")
+	cmd.Flags().IntVar(&topDeletions, "top-deletions", internal.DefaultTopDeletions,
+		"Number of deletions to list per job")
+
+	return cmd
+}
+
+func writeChangeReport(fs afero.Fs, path string, report *internal.ChangeReport, out io.Writer) error {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, internal.LogFilePermission)
+	if err != nil {
+		return fmt.Errorf("writing change report: %w", err)
+	}
+	defer file.Close()
+
+	err = report.WriteJSON(file)
+	if err != nil {
+		return fmt.Errorf("writing change report: %w", err)
+	}
+
+	fmt.Fprintf(out, "Change report: %s\n", path)
+
+	return nil
 }
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"backup-rsync/backup/internal"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+func buildSnapshotsCommand(fs afero.Fs) *cobra.Command {
+	snapshotsCmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "Manage the snapshots of jobs in snapshot mode",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list [job...]",
+		Short: "List the snapshots of jobs in snapshot mode",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jobs, err := loadSnapshotJobs(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			store := internal.SnapshotStore{Fs: fs}
+
+			for job := range slices.Values(jobs) {
+				err = listSnapshots(cmd.OutOrStdout(), store, job)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune [job...]",
+		Short: "Remove the snapshots not kept by the retention policy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			jobs, err := loadSnapshotJobs(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			store := internal.SnapshotStore{Fs: fs}
+
+			for job := range slices.Values(jobs) {
+				err = pruneSnapshots(cmd.OutOrStdout(), store, job, dryRun)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+	pruneCmd.Flags().Bool("dry-run", false, "Show the snapshots that would be removed without removing them")
+
+	snapshotsCmd.AddCommand(listCmd, pruneCmd)
+
+	return snapshotsCmd
+}
+
+// loadSnapshotJobs loads the configuration and returns the selected jobs in snapshot mode.
+func loadSnapshotJobs(cmd *cobra.Command, args []string) ([]internal.Job, error) {
+	return loadJobsWhere(cmd, args, func(job internal.Job) bool { return job.Mode == internal.SnapshotMode },
+		"No jobs in snapshot mode.")
+}
+
+// loadJobsWhere loads the configuration and returns the selected jobs for which keep is
+// true, printing none if there are none.
+func loadJobsWhere(cmd *cobra.Command, args []string, keep func(internal.Job) bool, none string) ([]internal.Job, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+
+	cfg, err := internal.LoadResolvedConfig(configPath, parseSetFlags(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	cfg, err = internal.JobSelector{Names: args}.Select(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("selecting jobs: %w", err)
+	}
+
+	jobs := slices.DeleteFunc(cfg.AllJobs(), func(job internal.Job) bool { return !keep(job) })
+	if len(jobs) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), none)
+	}
+
+	return jobs, nil
+}
+
+func listSnapshots(out io.Writer, store internal.SnapshotStore, job internal.Job) error {
+	snapshots, err := store.List(job.Target)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	fmt.Fprintf(out, "Job: %s (%s)\n", job.Name, job.Target)
+
+	if len(snapshots) == 0 {
+		fmt.Fprintln(out, "  no snapshots")
+	}
+
+	for i, snapshot := range snapshots {
+		latest := ""
+		if i == len(snapshots)-1 {
+			latest = " (latest)"
+		}
+
+		fmt.Fprintf(out, "  %s%s\n", snapshot.Name, latest)
+	}
+
+	return nil
+}
+
+func pruneSnapshots(out io.Writer, store internal.SnapshotStore, job internal.Job, dryRun bool) error {
+	var retention internal.Retention
+	if job.Retention != nil {
+		retention = *job.Retention
+	}
+
+	// Partial snapshots left by failed runs are removed even without a retention policy.
+	pruned, err := store.Prune(job.Target, retention, dryRun)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	if retention.IsZero() && len(pruned) == 0 {
+		fmt.Fprintf(out, "Job: %s has no retention policy, keeping all snapshots\n", job.Name)
+
+		return nil
+	}
+
+	verb := "Pruned"
+	if dryRun {
+		verb = "Would prune"
+	}
+
+	fmt.Fprintf(out, "Job: %s\n", job.Name)
+
+	if len(pruned) == 0 {
+		fmt.Fprintln(out, "  nothing to prune")
+	}
+
+	for snapshot := range slices.Values(pruned) {
+		fmt.Fprintf(out, "  %s %s\n", verb, snapshot.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"backup-rsync/backup/internal"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// ExitError is an error that sets the exit code of the program.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func buildStatusCommand(fs afero.Fs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status [job...]",
+		Short: "Show the last run and last success of each job",
+		Long: "Show the last run, last success and consecutive failures of each job, as recorded\n" +
+			"by run. Jobs that have not succeeded within their max_age are reported as CRITICAL.\n" +
+			"The exit code follows the Nagios plugin conventions: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, _ := cmd.Flags().GetString("config")
+
+			cfg, err := internal.LoadResolvedConfig(configPath, parseSetFlags(cmd))
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+
+			cfg, err = internal.JobSelector{Names: args}.Select(cfg)
+			if err != nil {
+				return fmt.Errorf("selecting jobs: %w", err)
+			}
+
+			entries, err := internal.History{Fs: fs, Path: internal.HistoryPath(configPath)}.Read()
+			if err != nil {
+				return fmt.Errorf("reading history: %w", err)
+			}
+
+			healths := internal.JobHealths(cfg.AllJobs(), entries, time.Now())
+			state := printStatus(cmd.OutOrStdout(), healths)
+
+			if state == internal.HealthOK {
+				return nil
+			}
+
+			cmd.SilenceUsage = true
+
+			return &ExitError{Code: state.ExitCode(), Err: fmt.Errorf("status %s", state)}
+		},
+	}
+}
+
+// printStatus prints a summary line followed by a line per job and returns the worst state.
+func printStatus(out io.Writer, healths []internal.JobHealth) internal.HealthState {
+	state := internal.HealthOK
+	counts := make(map[internal.HealthState]int)
+
+	for health := range slices.Values(healths) {
+		state = state.Worse(health.State)
+		counts[health.State]++
+	}
+
+	fmt.Fprintf(out, "BACKUP %s: %d ok, %d warning, %d critical, %d unknown\n", state,
+		counts[internal.HealthOK], counts[internal.HealthWarning],
+		counts[internal.HealthCritical], counts[internal.HealthUnknown])
+
+	for health := range slices.Values(healths) {
+		fmt.Fprintf(out, "%s: %s\n", health.Job, formatHealth(health))
+	}
+
+	return state
+}
+
+func formatHealth(health internal.JobHealth) string {
+	if health.LastRun == nil {
+		details := string(health.State) + ", never run"
+		if health.MaxAge > 0 {
+			details += fmt.Sprintf(" (max_age %s)", internal.FormatAge(health.MaxAge))
+		}
+
+		return details
+	}
+
+	lastRun := health.LastRun
+	parts := []string{
+		string(health.State),
+		fmt.Sprintf("last run %s %s", lastRun.Time.Local().Format(time.DateTime), lastRun.Status),
+	}
+
+	if lastRun.Reason != "" {
+		parts[1] += " (" + lastRun.Reason + ")"
+	}
+
+	if health.LastSuccess.IsZero() {
+		parts = append(parts, "never succeeded")
+	} else {
+		parts = append(parts, "last success "+internal.FormatAge(health.Age)+" ago")
+	}
+
+	if health.Stale() {
+		parts[len(parts)-1] += fmt.Sprintf(" (max_age %s)", internal.FormatAge(health.MaxAge))
+	}
+
+	if health.ConsecutiveFailures > 0 {
+		parts = append(parts, fmt.Sprintf("%d consecutive failures", health.ConsecutiveFailures))
+	}
+
+	return strings.Join(parts, ", ")
+}
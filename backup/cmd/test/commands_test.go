@@ -2,7 +2,14 @@ package cmd_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 
@@ -15,15 +22,25 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var errExitStatus = errors.New("exit status 1")
+
 type stubExec struct {
 	output []byte
 	err    error
 }
 
-func (s *stubExec) Execute(_ string, _ ...string) ([]byte, error) {
+func (s *stubExec) Execute(_ context.Context, _ string, _ ...string) ([]byte, error) {
 	return s.output, s.err
 }
 
+func (s *stubExec) Stream(_ context.Context, handler internal.LineHandler, _ string, _ ...string) error {
+	for line := range strings.Lines(string(s.output)) {
+		handler(internal.Stdout, strings.TrimSuffix(line, "\n"))
+	}
+
+	return s.err
+}
+
 func executeCommand(t *testing.T, args ...string) (string, error) {
 	t.Helper()
 
@@ -175,6 +192,68 @@ func TestConfigValidate_DuplicateJobNames(t *testing.T) {
 	assert.Contains(t, err.Error(), "validating config")
 }
 
+func TestConfigValidate_ReportsAllProblems(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("same", "a", "a").
+		AddJobToMapping("same", "b", "b").
+		AddJobToMapping("bad name", "c", "c").
+		Build())
+
+	stdout, err := executeCommand(t, "config", "validate", "--config", cfgPath)
+
+	var exitErr *cmd.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.Code)
+	require.ErrorIs(t, err, internal.ErrJobValidation)
+
+	assert.Contains(t, stdout, "Job same\n  "+cfgPath+":9:9: error: name: job validation failed: duplicate job name: same\n")
+	assert.Contains(t, stdout, "Job bad name\n")
+	assert.Contains(t, stdout, "Summary: 2 errors, 0 warnings\n")
+	assert.NotContains(t, stdout, "Configuration is valid.")
+}
+
+func TestConfigValidate_JSON(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("same", "a", "a").
+		AddJobToMapping("same", "b", "b").
+		Build())
+
+	stdout, err := executeCommand(t, "config", "validate", "--config", cfgPath, "--format", "json")
+	require.Error(t, err)
+
+	var report struct {
+		Valid       bool
+		Errors      int
+		Diagnostics []map[string]any
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.False(t, report.Valid)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, []map[string]any{{
+		"severity": "error", "mapping": "m", "job": "same", "field": "name",
+		"file": cfgPath, "line": 9.0, "column": 9.0,
+		"message": "job validation failed: duplicate job name: same",
+	}}, report.Diagnostics)
+
+	cfgPath = testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	stdout, err = executeCommand(t, "config", "validate", "--config", cfgPath, "--format", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"valid": true, "errors": 0, "warnings": 0, "diagnostics": []}`, stdout)
+}
+
+func TestConfigValidate_UnknownFormat(t *testing.T) {
+	_, err := executeCommand(t, "config", "validate", "--format", "xml")
+
+	require.ErrorIs(t, err, internal.ErrUnknownValidationFormat)
+}
+
 // --- run ---
 
 func TestRun_ValidConfig(t *testing.T) {
@@ -192,6 +271,468 @@ func TestRun_ValidConfig(t *testing.T) {
 	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
 }
 
+func TestRun_Parallel(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m1", "/home", "/backup1").
+		AddJobToMapping("docs", "docs", "docs").
+		AddJobToMapping("music", "music", "music").
+		AddMapping("m2", "/data", "/backup2").
+		AddJobToMapping("photos", "photos", "photos").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell,
+		"run", "--config", cfgPath, "--parallel", "3", "--parallel-per-target", "1")
+
+	require.NoError(t, err)
+
+	docs := strings.Index(stdout, "Status [docs]: SUCCESS")
+	music := strings.Index(stdout, "Status [music]: SUCCESS")
+	photos := strings.Index(stdout, "Status [photos]: SUCCESS")
+
+	require.NotEqual(t, -1, docs)
+	assert.Less(t, docs, music, "statuses are reported in configuration order")
+	assert.Less(t, music, photos, "statuses are reported in configuration order")
+	assert.Contains(t, stdout, "Summary: 3 succeeded, 0 failed, 0 skipped")
+}
+
+// --- job selection ---
+
+func selectionConfig(t *testing.T) string {
+	t.Helper()
+
+	return testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("home", "/home", "/backup/home").
+		AddJobToMapping("docs", "docs", "docs", testutil.Tags("daily")).
+		AddJobToMapping("music", "music", "music").
+		AddMapping("data", "/data", "/backup/data").
+		AddJobToMapping("photos", "photos", "photos", testutil.Tags("daily")).
+		Build())
+}
+
+func TestJobSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		notWant []string
+	}{
+		{"Positional", []string{"music"}, []string{"music"}, []string{"docs", "photos"}},
+		{"JobFlagGlob", []string{"--job", "d*"}, []string{"docs"}, []string{"music", "photos"}},
+		{"Mapping", []string{"--mapping", "data"}, []string{"photos"}, []string{"docs", "music"}},
+		{"Tag", []string{"--tag", "daily"}, []string{"docs", "photos"}, []string{"music"}},
+		{"Exclude", []string{"--exclude-job", "docs"}, []string{"music", "photos"}, []string{"docs"}},
+	}
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	for _, command := range []string{"list", "run", "simulate"} {
+		for _, test := range tests {
+			t.Run(command+"/"+test.name, func(t *testing.T) {
+				args := append([]string{command, "--config", selectionConfig(t)}, test.args...)
+
+				stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, args...)
+
+				require.NoError(t, err)
+
+				for _, name := range test.want {
+					assert.Contains(t, stdout, "Job: "+name)
+				}
+
+				for _, name := range test.notWant {
+					assert.NotContains(t, stdout, "Job: "+name)
+				}
+			})
+		}
+	}
+}
+
+func TestJobSelection_UnknownJob(t *testing.T) {
+	_, err := executeCommand(t, "run", "--config", selectionConfig(t), "videos")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "selecting jobs")
+	assert.Contains(t, err.Error(), `unknown job "videos" (valid jobs: docs, music, photos)`)
+}
+
+func TestRun_MaxDelete(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		MaxDelete("0").
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n*deleting   old.txt\n")}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrJobFailure)
+	assert.Contains(t, stdout, "Status [docs]: REFUSED (1 of 1 entries would be deleted, max_delete is 0)")
+	assert.Contains(t, stdout, "1 refused")
+
+	stdout, err = executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath, "--force")
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
+	assert.NotContains(t, stdout, "Checking deletions")
+}
+
+func TestRun_PreflightFailure(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "/home"
+    target: "/backup"
+    preflight:
+      require_file: ".backup-target-id"
+      on_failure: skip
+    jobs:
+      - name: "docs"
+        source: "docs"
+        target: "docs"
+`)
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+	fs := afero.NewMemMapFs()
+
+	stdout, err := executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath)
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Status [docs]: SKIPPED (preflight: required file /backup/.backup-target-id not found)")
+	assert.NotContains(t, stdout, "Job: docs")
+
+	require.NoError(t, afero.WriteFile(fs, "/backup/.backup-target-id", nil, 0644))
+
+	stdout, err = executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath)
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
+}
+
+func snapshotConfig(t *testing.T) string {
+	t.Helper()
+
+	return testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "/home"
+    target: "/backup"
+    jobs:
+      - name: "docs"
+        source: "docs"
+        target: "docs"
+        mode: snapshot
+        retention:
+          daily: 2
+      - name: "music"
+        source: "music"
+        target: "music"
+        mode: snapshot
+      - name: "photos"
+        source: "photos"
+        target: "photos"
+`)
+}
+
+func snapshotFs(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	for _, dir := range []string{"2026-10-15T02-00-00", "2026-10-16T02-00-00", "2026-10-17T02-00-00.partial"} {
+		require.NoError(t, fs.MkdirAll("/backup/docs/"+dir, 0755))
+	}
+
+	require.NoError(t, fs.MkdirAll("/backup/docs/2026-10-14T02-00-00", 0755))
+
+	return fs
+}
+
+func TestSnapshotsList(t *testing.T) {
+	stdout, err := executeCommandWithFs(t, snapshotFs(t), "snapshots", "list", "--config", snapshotConfig(t))
+
+	require.NoError(t, err)
+	assert.Equal(t, "Job: docs (/backup/docs)\n"+
+		"  2026-10-14T02-00-00\n"+
+		"  2026-10-15T02-00-00\n"+
+		"  2026-10-16T02-00-00 (latest)\n"+
+		"Job: music (/backup/music)\n"+
+		"  no snapshots\n", stdout)
+}
+
+func TestSnapshotsList_NoSnapshotJobs(t *testing.T) {
+	stdout, err := executeCommandWithFs(t, snapshotFs(t), "snapshots", "list", "--config", snapshotConfig(t), "photos")
+
+	require.NoError(t, err)
+	assert.Equal(t, "No jobs in snapshot mode.\n", stdout)
+}
+
+func TestSnapshotsPrune(t *testing.T) {
+	fs := snapshotFs(t)
+	cfgPath := snapshotConfig(t)
+
+	stdout, err := executeCommandWithFs(t, fs, "snapshots", "prune", "--config", cfgPath, "--dry-run")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Job: docs\n"+
+		"  Would prune 2026-10-17T02-00-00.partial\n"+
+		"  Would prune 2026-10-14T02-00-00\n"+
+		"Job: music has no retention policy, keeping all snapshots\n", stdout)
+
+	exists, err := afero.DirExists(fs, "/backup/docs/2026-10-14T02-00-00")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	stdout, err = executeCommandWithFs(t, fs, "snapshots", "prune", "--config", cfgPath, "docs")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Job: docs\n  Pruned 2026-10-17T02-00-00.partial\n  Pruned 2026-10-14T02-00-00\n", stdout)
+
+	exists, err = afero.DirExists(fs, "/backup/docs/2026-10-14T02-00-00")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	stdout, err = executeCommandWithFs(t, fs, "snapshots", "prune", "--config", cfgPath, "docs")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Job: docs\n  nothing to prune\n", stdout)
+}
+
+func TestSnapshotsList_UnknownJob(t *testing.T) {
+	_, err := executeCommandWithFs(t, snapshotFs(t), "snapshots", "list", "--config", snapshotConfig(t), "nope")
+
+	require.ErrorIs(t, err, internal.ErrUnknownJob)
+}
+
+func TestRun_RemoteTarget(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "/home"
+    target: "/volume1"
+    target_remote:
+      host: "nas"
+      user: "backup"
+      port: 2222
+    jobs:
+      - name: "docs"
+        source: "docs"
+        target: "docs"
+`)
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath)
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "-e ssh -o BatchMode=yes -p 2222 /home/docs/ backup@nas:/volume1/docs\n")
+	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
+}
+
+func TestRun_ReportFormat(t *testing.T) {
+	tests := []struct {
+		format, file string
+		want         []string
+	}{
+		{"json", "report.json", []string{`"command": "run"`, `"rsync_version": "rsync version 3.2.7 protocol version 31"`,
+			`"name": "docs"`, `"status": "SUCCESS"`, `"log_path": "logs/`}},
+		{"junit", "report.xml", []string{`<testsuite name="backup run" tests="1" failures="0" skipped="0"`,
+			`<testcase name="docs" classname="backup.run"`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+				AddMapping("m", "/home", "/backup").
+				AddJobToMapping("docs", "docs", "docs").
+				Build())
+
+			shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+			fs := afero.NewMemMapFs()
+
+			stdout, err := executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath, "--report-format", test.format)
+			require.NoError(t, err)
+
+			reports, err := afero.Glob(fs, "logs/*/"+test.file)
+			require.NoError(t, err)
+			require.Len(t, reports, 1)
+			assert.Contains(t, stdout, "Run report: "+reports[0])
+
+			content, err := afero.ReadFile(fs, reports[0])
+			require.NoError(t, err)
+
+			for want := range slices.Values(test.want) {
+				assert.Contains(t, string(content), want)
+			}
+		})
+	}
+}
+
+func TestRun_MetricsFile(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/metrics", 0o755))
+
+	stdout, err := executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath, "--metrics-file", "/metrics/backup.prom")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Metrics: /metrics/backup.prom\n")
+
+	content, err := afero.ReadFile(fs, "/metrics/backup.prom")
+	require.NoError(t, err)
+
+	labels := `{config="` + cfgPath + `",mode="run",mapping="m",job="docs"}`
+
+	assert.Contains(t, string(content), "backup_job_exit_code"+labels+" 0\n")
+	assert.Contains(t, string(content), "backup_job_last_success_timestamp_seconds"+labels+" ")
+	assert.Contains(t, string(content), `backup_run_jobs{config="`+cfgPath+`",mode="run",status="SUCCESS"} 1`)
+}
+
+func TestSimulate_MetricsFile(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/metrics", 0o755))
+
+	_, err := executeCommandWithDeps(t, fs, shell, "simulate", "--config", cfgPath, "--metrics-file", "/metrics/backup.prom")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "/metrics/backup.prom")
+	require.NoError(t, err)
+
+	labels := `{config="` + cfgPath + `",mode="simulate",mapping="m",job="docs"}`
+
+	assert.Contains(t, string(content), "backup_job_exit_code"+labels+" 0\n")
+	assert.NotContains(t, string(content), "backup_job_last_success_timestamp_seconds")
+}
+
+func TestVerify_NoMetricsFile(t *testing.T) {
+	_, err := executeCommand(t, "verify", "--config", "sync.yaml", "--metrics-file", "/metrics/backup.prom")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown flag: --metrics-file")
+}
+
+func TestRun_Locked(t *testing.T) {
+	lockDir := t.TempDir()
+	cfgPath := testutil.WriteConfigFile(t, "lock_dir: "+lockDir+"\n"+testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	lockPath := internal.Config{LockDir: lockDir}.LockPaths(cfgPath)[0]
+
+	held, err := internal.AcquireLock(t.Context(), lockPath, internal.NewLockInfo("run", cfgPath), false, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	_, err = executeCommand(t, "run", "--config", cfgPath)
+	require.ErrorIs(t, err, internal.ErrLocked)
+
+	_, err = executeCommand(t, "run", "--config", cfgPath, "--wait", "--no-wait")
+	require.Error(t, err)
+
+	require.NoError(t, held.Release())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	_, err = executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath, "--no-wait")
+	require.NoError(t, err)
+	assert.NoFileExists(t, lockPath)
+}
+
+func TestRun_UnknownReportFormat(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	_, err := executeCommand(t, "run", "--config", cfgPath, "--report-format", "csv")
+
+	require.ErrorIs(t, err, internal.ErrUnknownReportFormat)
+}
+
+func TestRun_Notifications(t *testing.T) {
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	t.Cleanup(server.Close)
+
+	cfgPath := testutil.WriteConfigFile(t, `notifications:
+  - on: [success]
+    webhook:
+      url: `+server.URL+`
+      body: '{"status": "{{.Status}}", "summary": {{json .Summary}}}'
+  - webhook:
+      url: `+server.URL+`/failures
+`+testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath)
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Sent notification: webhook to "+strings.TrimPrefix(server.URL, "http://")+"\n")
+	require.Len(t, bodies, 1)
+	assert.JSONEq(t, `{"status": "success", "summary": ["Summary: 1 succeeded, 0 failed, 0 skipped"]}`, bodies[0])
+}
+
+func TestRun_NotificationIgnoresMetricsFailure(t *testing.T) {
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	t.Cleanup(server.Close)
+
+	cfgPath := testutil.WriteConfigFile(t, "notifications:\n  - on: [always]\n    webhook:\n      url: "+server.URL+
+		"\n      body: '{{.Status}}'\n"+testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	// A corrupt history keeps the metrics from being written.
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, internal.HistoryPath(cfgPath), []byte("garbage\n"), 0o644))
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+
+	_, err := executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath, "--metrics-file", "/metrics/backup.prom")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "writing metrics")
+	assert.Equal(t, []string{"success"}, bodies)
+}
+
+func TestRun_NotificationFailure(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, "notifications:\n  - on: [always]\n    command: page-oncall\n"+
+		testutil.NewConfigBuilder().
+			AddMapping("m", "/home", "/backup").
+			AddJobToMapping("docs", "docs", "docs").
+			Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n"), err: errExitStatus}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "run", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrJobFailure)
+	assert.Contains(t, stdout, `Warning: notification failed: command "page-oncall": running notification command: exit status 1`)
+}
+
 // --- simulate ---
 
 func TestSimulate_ValidConfig(t *testing.T) {
@@ -209,6 +750,93 @@ func TestSimulate_ValidConfig(t *testing.T) {
 	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
 }
 
+func TestSimulate_ChangeReport(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs", testutil.Enabled(true)).
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n" +
+		">f+++++++++ a.txt\n*deleting   old.txt\n")}
+	fs := afero.NewMemMapFs()
+
+	stdout, err := executeCommandWithDeps(t, fs, shell, "simulate", "--config", cfgPath, "--changes-report")
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Changes [docs]: 1 new, 0 updated, 1 deleted")
+	assert.Contains(t, stdout, "Top deletions [docs]:\n  old.txt\n")
+
+	reports, err := afero.Glob(fs, "logs/*-sim/changes.json")
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Contains(t, stdout, "Change report: "+reports[0])
+
+	content, err := afero.ReadFile(fs, reports[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"path": "old.txt"`)
+	assert.Contains(t, string(content), `"type": "deleted"`)
+}
+
+func TestSimulate_NegativeTopDeletions(t *testing.T) {
+	_, err := executeCommand(t, "simulate", "--config", "sync.yaml", "--top-deletions", "-1")
+
+	require.ErrorIs(t, err, internal.ErrInvalidTopDeletions)
+}
+
+// --- status ---
+
+func TestStatus(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		AddJobToMapping("music", "music", "music").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n")}
+	fs := afero.NewMemMapFs()
+
+	_, err := executeCommandWithDeps(t, fs, shell, "run", "--config", cfgPath, "docs")
+	require.NoError(t, err)
+
+	stdout, err := executeCommandWithDeps(t, fs, shell, "status", "--config", cfgPath)
+
+	var exitErr *cmd.ExitError
+
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.Code)
+	assert.Contains(t, stdout, "BACKUP UNKNOWN: 1 ok, 0 warning, 0 critical, 1 unknown\n")
+	assert.Regexp(t, `docs: OK, last run \S+ \S+ SUCCESS, last success 0m ago\n`, stdout)
+	assert.Contains(t, stdout, "music: UNKNOWN, never run\n")
+
+	stdout, err = executeCommandWithDeps(t, fs, shell, "status", "--config", cfgPath, "docs")
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "BACKUP OK: 1 ok, 0 warning, 0 critical, 0 unknown\n")
+}
+
+func TestStatus_Stale(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, "max_age: 24h\n"+testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	fs := afero.NewMemMapFs()
+	history := `{"time":"2020-01-01T03:00:00Z","job":"docs","status":"SUCCESS","duration_seconds":1}
+{"time":"2020-01-02T03:00:00Z","job":"docs","status":"FAILURE","reason":"rsync exit code 23: partial transfer due to error","duration_seconds":1,"exit_code":23}
+`
+	require.NoError(t, afero.WriteFile(fs, internal.HistoryPath(cfgPath), []byte(history), 0o644))
+
+	stdout, err := executeCommandWithFs(t, fs, "status", "--config", cfgPath)
+
+	var exitErr *cmd.ExitError
+
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.Code)
+	assert.Contains(t, stdout, "BACKUP CRITICAL: 0 ok, 0 warning, 1 critical, 0 unknown\n")
+	assert.Contains(t, stdout, "FAILURE (rsync exit code 23: partial transfer due to error)")
+	assert.Contains(t, stdout, " ago (max_age 1d), 1 consecutive failures\n")
+}
+
 // --- version ---
 
 func TestVersion_ErrorPaths(t *testing.T) {
@@ -238,6 +866,18 @@ func TestCheckCoverage_MissingConfig(t *testing.T) {
 	assert.Contains(t, err.Error(), "loading config")
 }
 
+func TestCheckCoverage_RemoteSource(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("web", "root@web1:/srv", "/backup/web").
+		AddJobToMapping("www", "www", "www").
+		Build())
+
+	_, err := executeCommand(t, "check-coverage", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrRemoteSource)
+	assert.Contains(t, err.Error(), "job 'www' backs up root@web1:/srv/www/")
+}
+
 func TestCheckCoverage_WithUncoveredPaths(t *testing.T) {
 	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
 		AddMapping("m", "/src", "/dst").
@@ -405,6 +1045,95 @@ func TestList_WithSetFlag(t *testing.T) {
 	assert.Contains(t, stdout, "Job: alice_docs")
 }
 
+// --- config diff ---
+
+func TestConfigDiff_OtherConfig(t *testing.T) {
+	dir := t.TempDir()
+	before := testutil.WriteConfigFileInDir(t, dir, "before.yaml", testutil.NewConfigBuilder().
+		AddMapping("home", "/home/user", "/backup/user").
+		AddJobToMapping("docs", "docs", "docs", testutil.Delete(false)).
+		AddJobToMapping("music", "music", "music").
+		Build())
+	after := testutil.WriteConfigFileInDir(t, dir, "after.yaml", testutil.NewConfigBuilder().
+		AddMapping("home", "/home/user", "/backup/user").
+		AddJobToMapping("docs", "docs", "docs", testutil.Delete(true), testutil.Exclusions("*.tmp")).
+		AddJobToMapping("photos", "photos", "photos", testutil.Enabled(false)).
+		Build())
+
+	stdout, err := executeCommand(t, "config", "diff", after, "--config", before)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Removed music\n"+
+		"Changed docs\n"+
+		"! delete: false -> true\n"+
+		"  filter: added --exclude=*.tmp\n"+
+		"Added photos\n"+
+		"  source: /home/user/photos/\n"+
+		"  target: /backup/user/photos\n"+
+		"  delete: true\n"+
+		"  enabled: false\n"+
+		"Summary: 1 added, 1 removed, 1 changed, 1 risky changes\n", stdout)
+}
+
+func TestConfigDiff_After(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		TemplateVar("user").
+		AddMapping("home", "/home/${user}", "/backup/${user}").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	stdout, err := executeCommand(t, "config", "diff", "--config", cfgPath, "--set", "user=alice", "--after", "user=bob")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Changed docs\n"+
+		"! source: /home/alice/docs/ -> /home/bob/docs/\n"+
+		"! target: /backup/alice/docs -> /backup/bob/docs\n"+
+		"Summary: 0 added, 0 removed, 1 changed, 2 risky changes\n", stdout)
+
+	stdout, err = executeCommand(t, "config", "diff", "--config", cfgPath, "--set", "user=alice", "--after", "user=alice")
+
+	require.NoError(t, err)
+	assert.Equal(t, "No changes to jobs.\n", stdout)
+}
+
+func TestConfigDiff_NothingToCompare(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("home", "/home/user", "/backup/user").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	_, err := executeCommand(t, "config", "diff", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrNothingToDiff)
+}
+
+// --- config schema and strict decoding ---
+
+func TestConfigSchema(t *testing.T) {
+	stdout, err := executeCommand(t, "config", "schema")
+
+	require.NoError(t, err)
+
+	var schema map[string]any
+
+	require.NoError(t, json.Unmarshal([]byte(stdout), &schema))
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema["$schema"])
+	assert.Contains(t, schema["definitions"], "Job")
+}
+
+func TestConfigValidate_UnknownField(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("home", "/home/user", "/backup/user").
+		AddJobToMapping("docs", "docs", "docs").
+		Build()+"lock_target: true\n")
+
+	_, err := executeCommand(t, "config", "validate", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrUnknownField)
+	assert.Contains(t, err.Error(), cfgPath+":")
+	assert.Contains(t, err.Error(), `:1: unknown field "lock_target" in Config`)
+}
+
 // --- template: variables validation at command level ---
 
 func TestConfigValidate_TemplateVarsMissing(t *testing.T) {
@@ -537,3 +1266,154 @@ func TestList_WithInclude(t *testing.T) {
 	assert.Contains(t, stdout, "Job: alice_docs")
 	assert.Contains(t, stdout, "Job: bob_docs")
 }
+
+func TestRestore(t *testing.T) {
+	shell := &stubExec{output: []byte("sent 1,024 bytes  received 64 bytes\n")}
+
+	stdout, err := executeCommandWithDeps(t, snapshotFs(t), shell,
+		"restore", "--config", snapshotConfig(t), "docs", "--at", "2026-10-15", "--path", "reports")
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Command: /usr/bin/rsync --dry-run -aiv --stats --relative "+
+		"/backup/docs/2026-10-15T02-00-00/./reports /home/docs/\n")
+	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
+	assert.Contains(t, stdout, "add --apply to restore")
+}
+
+func TestRestore_Refused(t *testing.T) {
+	stdout, err := executeCommandWithDeps(t, snapshotFs(t), &stubExec{},
+		"restore", "--config", snapshotConfig(t), "photos", "--apply")
+
+	require.Error(t, err)
+	assert.Contains(t, stdout, "Status [photos]: REFUSED")
+
+	_, err = executeCommandWithDeps(t, snapshotFs(t), &stubExec{},
+		"restore", "--config", snapshotConfig(t), "photos", "--apply", "--overwrite-source")
+	require.NoError(t, err)
+}
+
+func TestRestore_SelectsOneJob(t *testing.T) {
+	_, err := executeCommandWithDeps(t, snapshotFs(t), &stubExec{}, "restore", "--config", snapshotConfig(t), "*")
+	require.ErrorIs(t, err, internal.ErrInvalidRestore)
+
+	_, err = executeCommandWithDeps(t, snapshotFs(t), &stubExec{}, "restore", "--config", snapshotConfig(t))
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n.f..t...... touched.txt\n")}
+
+	stdout, err := executeCommandWithDeps(t, afero.NewMemMapFs(), shell, "verify", "--config", cfgPath)
+
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Command: /usr/bin/rsync --dry-run --checksum -aiv --stats")
+	assert.Contains(t, stdout, "Status [docs]: SUCCESS")
+}
+
+func TestVerify_Differences(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	shell := &stubExec{output: []byte("rsync version 3.2.7 protocol version 31\n>f+++++++++ report.pdf\n")}
+	fs := afero.NewMemMapFs()
+
+	stdout, err := executeCommandWithDeps(t, fs, shell, "verify", "--config", cfgPath, "--report-format", "json")
+
+	var exitErr *cmd.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.Code)
+	require.ErrorIs(t, err, internal.ErrVerifyMismatch)
+	assert.Contains(t, stdout, "Status [docs]: MISMATCH (1 file differs)\nDiffers [docs]: missing report.pdf\n")
+
+	reports, err := afero.Glob(fs, "logs/*-verify/report.json")
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+
+	content, err := afero.ReadFile(fs, reports[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"status": "MISMATCH"`)
+	assert.Contains(t, string(content), `"path": "report.pdf"`)
+}
+
+func TestVerify_InvalidSample(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		Build())
+
+	_, err := executeCommand(t, "verify", "--config", cfgPath, "--sample", "0%")
+
+	require.ErrorIs(t, err, internal.ErrInvalidSample)
+}
+
+func TestVerifyManifest(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "/home"
+    target: "/backup"
+    jobs:
+      - name: "docs"
+        source: "docs"
+        target: "docs"
+        manifest: true
+      - name: "music"
+        source: "music"
+        target: "music"
+`)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/backup/docs/a.txt", []byte("hello"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/backup/docs/b.txt", []byte("world"), 0644))
+
+	store := internal.ManifestStore{Fs: fs}
+	manifest, _, err := store.Build(t.Context(), "docs", "/backup/docs", internal.Manifest{})
+	require.NoError(t, err)
+	require.NoError(t, store.Write("/backup/docs", manifest))
+
+	stdout, err := executeCommandWithFs(t, fs, "verify-manifest", "--config", cfgPath)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "Job: docs (/backup/docs, manifest of ")
+	assert.Contains(t, stdout, "  2 files OK\n")
+
+	require.NoError(t, afero.WriteFile(fs, "/backup/docs/b.txt", []byte("world!"), 0644))
+	require.NoError(t, fs.Remove("/backup/docs/a.txt"))
+
+	stdout, err = executeCommandWithFs(t, fs, "verify-manifest", "--config", cfgPath)
+
+	var exitErr *cmd.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 2, exitErr.Code)
+	assert.Contains(t, stdout, "  modified b.txt\n  missing a.txt\n  1 files checked, 2 differences\n")
+}
+
+func TestVerifyManifest_NoManifest(t *testing.T) {
+	cfgPath := testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "/home"
+    target: "/backup"
+    jobs:
+      - name: "docs"
+        source: "docs"
+        target: "docs"
+        manifest: true
+`)
+
+	_, err := executeCommand(t, "verify-manifest", "--config", cfgPath)
+
+	require.ErrorIs(t, err, internal.ErrNoManifest)
+}
+
+func TestVerifyManifest_NegativeWorkers(t *testing.T) {
+	_, err := executeCommand(t, "verify-manifest", "--config", "sync.yaml", "--workers", "-1")
+
+	require.ErrorIs(t, err, internal.ErrInvalidWorkers)
+}
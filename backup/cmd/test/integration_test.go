@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"backup-rsync/backup/cmd"
 	"backup-rsync/backup/internal/testutil"
@@ -578,3 +579,52 @@ func splitNonEmpty(s string) []string {
 
 	return result
 }
+
+// --- run: snapshot mode hard-links unchanged files ---
+
+func TestIntegration_Run_Snapshot(t *testing.T) {
+	src, dst := setupDirs(t)
+
+	writeFile(t, filepath.Join(src, "unchanged.txt"), "same")
+	writeFile(t, filepath.Join(src, "changed.txt"), "v1")
+
+	cfgPath := testutil.WriteConfigFile(t, `
+mappings:
+  - name: "m"
+    source: "`+src+`"
+    target: "`+dst+`"
+    jobs:
+      - name: "snap"
+        source: ""
+        target: ""
+        mode: snapshot
+`)
+
+	_, err := executeIntegrationCommand(t, "run", "--config", cfgPath)
+	require.NoError(t, err)
+
+	first, err := os.Readlink(filepath.Join(dst, "latest"))
+	require.NoError(t, err)
+
+	// Snapshot names have a resolution of one second.
+	time.Sleep(time.Second)
+	writeFile(t, filepath.Join(src, "changed.txt"), "v2")
+
+	stdout, err := executeIntegrationCommand(t, "run", "--config", cfgPath)
+	require.NoError(t, err)
+	assert.Contains(t, stdout, "--link-dest="+filepath.Join(dst, "latest"))
+
+	second, err := os.Readlink(filepath.Join(dst, "latest"))
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+
+	assert.Equal(t, "v1", readFileContent(t, filepath.Join(dst, first, "changed.txt")))
+	assert.Equal(t, "v2", readFileContent(t, filepath.Join(dst, second, "changed.txt")))
+
+	firstInfo, err := os.Stat(filepath.Join(dst, first, "unchanged.txt"))
+	require.NoError(t, err)
+
+	secondInfo, err := os.Stat(filepath.Join(dst, second, "unchanged.txt"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(firstInfo, secondInfo), "unchanged files should be hard links")
+}
@@ -32,7 +32,7 @@ func TestBuildRootCommand_HelpOutput(t *testing.T) {
 	assert.Contains(t, helpOutput, "--rsync-path string   Path to the rsync binary (default \"/usr/bin/rsync\")")
 
 	// check each sub-command is listed
-	subCommands := []string{"list", "run", "simulate", "config", "check-coverage", "version"}
+	subCommands := []string{"list", "run", "simulate", "restore", "verify", "verify-manifest", "config", "check-coverage", "snapshots", "status", "version"}
 	for _, cmdName := range subCommands {
 		assert.Regexp(t, "(?m)^  "+cmdName, helpOutput, "Help output should list the sub-command: "+cmdName)
 	}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"backup-rsync/backup/internal"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// verifyMismatchExitCode is the exit code of a verification that completed but found
+// differences, as opposed to 1 for a verification that failed.
+const verifyMismatchExitCode = 2
+
+func buildVerifyCommand(fs afero.Fs, shell internal.Exec) *cobra.Command {
+	var (
+		sample        string
+		samplePercent float64
+	)
+
+	cmd := buildJobCommand(fs, jobCommandOptions{
+		use:    "verify",
+		short:  "Verify that the targets match their sources by checksum",
+		report: true,
+		lock:   true,
+		createLogger: func(fs afero.Fs, configPath string, now time.Time) (*slog.Logger, string, func() error, error) {
+			logPath := internal.GetLogPath(configPath, now) + "-verify"
+
+			logger, cleanup, err := internal.CreateMainLogger(fs, logPath)
+
+			return logger, logPath, cleanup, err
+		},
+		factory: func(rsyncPath string, logPath string, out io.Writer) internal.JobCommand {
+			command := internal.NewVerifyCommand(rsyncPath, logPath, shell, out)
+			command.SamplePercent = samplePercent
+			command.Snapshots = internal.SnapshotStore{Fs: fs}
+			command.Fs = fs
+
+			return command
+		},
+	})
+
+	cmd.Long = "Verify that the target of each job matches its source byte for byte, by running\n" +
+		"rsync with --dry-run --checksum and listing every file that is missing, extra or changed.\n" +
+		"Exits with 2 if the targets differ, and with 1 if a job could not be verified."
+
+	cmd.PreRunE = func(_ *cobra.Command, _ []string) error {
+		if sample == "" {
+			return nil
+		}
+
+		var err error
+
+		samplePercent, err = internal.ParseSamplePercent(sample)
+
+		return err
+	}
+
+	runE := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		err := runE(cmd, args)
+		if errors.Is(err, internal.ErrVerifyMismatch) {
+			cmd.SilenceUsage = true
+
+			return &ExitError{Code: verifyMismatchExitCode, Err: err}
+		}
+
+		return err
+	}
+
+	cmd.Flags().StringVar(&sample, "sample", "",
+		"Verify a random sample of this percentage of the files of each job, e.g. 10%")
+
+	return cmd
+}
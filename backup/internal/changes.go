@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidTopDeletions = errors.New("invalid top deletions")
+
+// ChangeType classifies an itemized change reported by rsync -i.
+type ChangeType string
+
+const (
+	// ChangeNew is a file that does not exist on the receiver yet.
+	ChangeNew ChangeType = "new"
+	// ChangeUpdated is an existing file whose content is transferred.
+	ChangeUpdated ChangeType = "updated"
+	// ChangeDeleted is an entry removed from the receiver by --delete.
+	ChangeDeleted ChangeType = "deleted"
+	// ChangeAttributes is an entry whose attributes change but whose content does not.
+	ChangeAttributes ChangeType = "attribute-only"
+	// ChangeSymlink is a symlink that is created or changed.
+	ChangeSymlink ChangeType = "symlink"
+	// ChangeDirectory is a directory that is created or changed.
+	ChangeDirectory ChangeType = "directory"
+)
+
+// ChangeTypes lists all change types in reporting order.
+var ChangeTypes = []ChangeType{
+	ChangeNew, ChangeUpdated, ChangeDeleted, ChangeAttributes, ChangeSymlink, ChangeDirectory,
+}
+
+const deletingMessage = "*deleting"
+
+// Change is a single entry of rsync's itemize-changes output.
+type Change struct {
+	Type ChangeType `json:"type"`
+	Path string     `json:"path"`
+	// Itemize is the raw change code, e.g. ">f.st......".
+	Itemize string `json:"itemize"`
+	// LinkTarget is the target of a symlink change.
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// ParseChange parses a line of rsync --itemize-changes output. It returns false
+// for lines that are not itemized changes, such as progress and statistics.
+func ParseChange(line string) (Change, bool) {
+	code, path, found := strings.Cut(line, " ")
+	if !found {
+		return Change{}, false
+	}
+
+	if code == deletingMessage {
+		path = strings.TrimLeft(path, " ")
+		if path == "" {
+			return Change{}, false
+		}
+
+		return Change{Type: ChangeDeleted, Path: path, Itemize: code}, true
+	}
+
+	if !isItemizeCode(code) || path == "" {
+		return Change{}, false
+	}
+
+	change := Change{Path: path, Itemize: code}
+	attributes := code[2:]
+
+	switch code[1] {
+	case 'd':
+		change.Type = ChangeDirectory
+	case 'L':
+		change.Type = ChangeSymlink
+		change.Path, change.LinkTarget, _ = strings.Cut(path, " -> ")
+	default:
+		switch {
+		case strings.Trim(attributes, "+") == "":
+			change.Type = ChangeNew
+		case code[0] == '<' || code[0] == '>':
+			change.Type = ChangeUpdated
+		case strings.Trim(attributes, ". ") == "":
+			// Reported with -ii: the entry is unchanged.
+			return Change{}, false
+		default:
+			change.Type = ChangeAttributes
+		}
+	}
+
+	return change, true
+}
+
+// isItemizeCode reports whether code looks like an rsync change code (YXcstpoguax).
+// Releases before 3.0 print two attribute characters fewer.
+func isItemizeCode(code string) bool {
+	const minLength, maxLength = 9, 11
+
+	return len(code) >= minLength && len(code) <= maxLength &&
+		strings.ContainsRune("<>ch.", rune(code[0])) &&
+		strings.ContainsRune("fdLDS", rune(code[1]))
+}
+
+// ChangeParser collects the itemized changes of rsync output fed to it line by line.
+type ChangeParser struct {
+	changes []Change
+}
+
+// Feed parses a single line of rsync output.
+func (p *ChangeParser) Feed(line string) {
+	if change, ok := ParseChange(line); ok {
+		p.changes = append(p.changes, change)
+	}
+}
+
+// Changes returns the changes seen so far.
+func (p *ChangeParser) Changes() []Change {
+	return p.changes
+}
+
+// CountChanges counts changes by type.
+func CountChanges(changes []Change) map[ChangeType]int {
+	counts := make(map[ChangeType]int, len(ChangeTypes))
+	for change := range slices.Values(changes) {
+		counts[change.Type]++
+	}
+
+	return counts
+}
+
+// DeletionGroup is a deleted path together with the number of deleted entries it contains.
+type DeletionGroup struct {
+	Path    string
+	Entries int
+}
+
+// TopDeletions groups deletions by their outermost deleted directory and returns
+// the n groups with the most entries. Deleting a directory removes its contents
+// first, so "old/" with 500 files is reported once instead of 501 times.
+func TopDeletions(changes []Change, n int) []DeletionGroup {
+	deleted := make(map[string]bool)
+
+	for change := range slices.Values(changes) {
+		if change.Type == ChangeDeleted {
+			deleted[change.Path] = true
+		}
+	}
+
+	entries := make(map[string]int)
+
+	for path := range deleted {
+		entries[outermostDeleted(path, deleted)]++
+	}
+
+	groups := make([]DeletionGroup, 0, len(entries))
+	for path, count := range entries {
+		groups = append(groups, DeletionGroup{Path: path, Entries: count})
+	}
+
+	slices.SortFunc(groups, func(a, b DeletionGroup) int {
+		return cmp.Or(cmp.Compare(b.Entries, a.Entries), cmp.Compare(a.Path, b.Path))
+	})
+
+	return groups[:min(max(n, 0), len(groups))]
+}
+
+// outermostDeleted returns the shortest deleted directory containing path, or path itself.
+func outermostDeleted(path string, deleted map[string]bool) string {
+	for i := range len(path) - 1 {
+		if path[i] == '/' && deleted[path[:i+1]] {
+			return path[:i+1]
+		}
+	}
+
+	return path
+}
+
+// FormatChangeCounts renders the per-type counts of changes, e.g. "3 new, 1 updated, ...".
+func FormatChangeCounts(changes []Change) string {
+	counts := CountChanges(changes)
+	parts := make([]string, 0, len(ChangeTypes))
+
+	for changeType := range slices.Values(ChangeTypes) {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[changeType], changeType))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// JobChanges holds the itemized changes of one job.
+type JobChanges struct {
+	Job     string    `json:"job"`
+	Status  JobStatus `json:"status"`
+	Changes []Change  `json:"changes"`
+}
+
+// ChangeReport collects the changes of all jobs of a simulate run.
+type ChangeReport struct {
+	Jobs []JobChanges `json:"jobs"`
+}
+
+// Add records the changes of a job.
+func (r *ChangeReport) Add(jobName string, result JobResult) {
+	changes := result.Changes
+	if changes == nil {
+		changes = []Change{}
+	}
+
+	r.Jobs = append(r.Jobs, JobChanges{Job: jobName, Status: result.Status, Changes: changes})
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *ChangeReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("encoding change report: %w", err)
+	}
+
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"path/filepath"
 	"slices"
-	"strings"
 
 	"github.com/spf13/afero"
 )
@@ -18,20 +17,34 @@ type CoverageChecker struct {
 
 func (c *CoverageChecker) IsExcludedGlobally(path string, mappings []Mapping) bool {
 	for mapping := range slices.Values(mappings) {
-		for exclusion := range slices.Values(mapping.Exclusions) {
-			exclusionPath := filepath.Join(mapping.Source, exclusion)
-			if strings.HasPrefix(NormalizePath(path), exclusionPath) {
-				c.Logger.Info(fmt.Sprintf("EXCLUDED: Path '%s' is globally excluded by '%s' in source '%s'",
-					path, exclusion, mapping.Source))
-
-				return true
-			}
+		if rule, excluded := c.matcher().Excluded(mapping.FilterRules(), mapping.Source, path); excluded {
+			c.Logger.Info(fmt.Sprintf("EXCLUDED: Path '%s' is globally excluded by '%s' in source '%s'",
+				path, rule.Pattern, mapping.Source))
+
+			return true
 		}
 	}
 
 	return false
 }
 
+// matcher evaluates filter rules, reading merge files from the checked filesystem.
+func (c *CoverageChecker) matcher() FilterMatcher {
+	return FilterMatcher{Fs: c.Fs}
+}
+
+// ValidateLocalSources returns an error if a job has a remote source. Coverage is
+// checked by walking the local filesystem, which cannot see remote sources.
+func ValidateLocalSources(cfg Config) error {
+	for _, job := range cfg.AllJobs() {
+		if job.SourceRemote != nil {
+			return fmt.Errorf("%w: job '%s' backs up %s", ErrRemoteSource, job.Name, job.SourceSpec())
+		}
+	}
+
+	return nil
+}
+
 func (c *CoverageChecker) ListUncoveredPaths(cfg Config) []string {
 	var result []string
 
@@ -47,11 +60,9 @@ func (c *CoverageChecker) ListUncoveredPaths(cfg Config) []string {
 }
 
 func (c *CoverageChecker) isExcluded(path string, job Job) bool {
-	normalized := NormalizePath(path)
+	_, excluded := c.matcher().Excluded(job.FilterRules(), job.Source, path)
 
-	return slices.ContainsFunc(job.Exclusions, func(exclusion string) bool {
-		return strings.HasPrefix(normalized, filepath.Join(job.Source, exclusion))
-	})
+	return excluded
 }
 
 func (c *CoverageChecker) isCoveredByJob(path string, job Job) bool {
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -10,6 +12,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +22,7 @@ var (
 	ErrJobValidation       = errors.New("job validation failed")
 	ErrOverlappingPath     = errors.New("overlapping path detected")
 	ErrJobFailure          = errors.New("one or more jobs failed")
+	ErrRunCancelled        = errors.New("run cancelled")
 	ErrMissingTemplateVars = errors.New("missing required template variables")
 	ErrNestedIncludes      = errors.New("nested includes are not supported")
 )
@@ -37,11 +41,25 @@ type Include struct {
 // Mapping defines a source-to-target directory pair with its own list of backup jobs.
 // Job paths within a mapping are relative to the mapping's Source and Target.
 type Mapping struct {
-	Name       string   `yaml:"name"`
-	Source     string   `yaml:"source"`
-	Target     string   `yaml:"target"`
-	Exclusions []string `yaml:"exclusions,omitempty"`
-	Jobs       []Job    `yaml:"jobs"`
+	Name        string        `yaml:"name"`
+	Source      string        `yaml:"source"`
+	Target      string        `yaml:"target"`
+	Exclusions  []string      `yaml:"exclusions,omitempty"`
+	Filters     []FilterRule  `yaml:"filters,omitempty"`
+	ExcludeFrom []string      `yaml:"exclude_from,omitempty"`
+	MaxDelete   *DeleteLimit  `yaml:"max_delete,omitempty"`
+	MaxAge      time.Duration `yaml:"max_age,omitempty"`
+	Preflight   *Preflight    `yaml:"preflight,omitempty"`
+	Mode        Mode          `yaml:"mode,omitempty"`
+	Retention   *Retention    `yaml:"retention,omitempty"`
+	Rsync       *RsyncOptions `yaml:"rsync,omitempty"`
+	Hooks       *Hooks        `yaml:"hooks,omitempty"`
+	// SourceRemote and TargetRemote place the source or the target on a host reached over SSH.
+	SourceRemote *Remote `yaml:"source_remote,omitempty"`
+	TargetRemote *Remote `yaml:"target_remote,omitempty"`
+	Jobs         []Job   `yaml:"jobs"`
+	// Position is where the mapping is defined, for reporting problems with it.
+	Position Position `yaml:"-"`
 }
 
 // Config represents the overall backup configuration.
@@ -49,7 +67,29 @@ type Config struct {
 	Template  *Template         `yaml:"template,omitempty"`
 	Include   []Include         `yaml:"include,omitempty"`
 	Variables map[string]string `yaml:"variables,omitempty"`
-	Mappings  []Mapping         `yaml:"mappings"`
+	// MaxDelete is the default deletion limit of all jobs that delete.
+	MaxDelete *DeleteLimit `yaml:"max_delete,omitempty"`
+	// MaxAge is the default time after which a job that has not succeeded is reported as stale.
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+	// Rsync holds the default rsync options of all jobs.
+	Rsync *RsyncOptions `yaml:"rsync,omitempty"`
+	// Hooks run around every job, outside the hooks of its mapping and its own.
+	Hooks *Hooks `yaml:"hooks,omitempty"`
+	// LockDir holds the lock files that keep runs from overlapping. It defaults to the
+	// temporary directory.
+	LockDir string `yaml:"lock_dir,omitempty"`
+	// LockTargets locks the target of every job in addition to the config, so that runs
+	// of other configs writing to the same targets wait for each other.
+	LockTargets bool `yaml:"lock_targets,omitempty"`
+	// Notifications are sent when a run completes.
+	Notifications []Notification `yaml:"notifications,omitempty"`
+	Mappings      []Mapping      `yaml:"mappings"`
+}
+
+// FilterRules returns the rsync filter rules of the mapping: its filters, followed
+// by its exclusions and exclude_from files.
+func (m Mapping) FilterRules() []FilterRule {
+	return filterRules(m.Filters, m.Exclusions, m.ExcludeFrom)
 }
 
 // AllJobs returns a flat list of all jobs across all mappings.
@@ -62,7 +102,10 @@ func (cfg Config) AllJobs() []Job {
 	return jobs
 }
 
+// String returns the configuration as YAML, with its secrets redacted.
 func (cfg Config) String() string {
+	cfg.Variables = redactVariables(cfg.Variables, cfg.Notifications)
+
 	out, err := yaml.Marshal(cfg)
 	if err != nil {
 		return fmt.Sprintf("error marshaling config: %v", err)
@@ -71,7 +114,10 @@ func (cfg Config) String() string {
 	return string(out)
 }
 
-func (cfg Config) Apply(rsync JobCommand, logger *slog.Logger) error {
+// Apply runs all jobs through rsync, scheduling them according to opts, and
+// reports each status in configuration order followed by a summary.
+// Cancelling ctx stops the running jobs and marks the remaining ones as cancelled.
+func (cfg Config) Apply(ctx context.Context, rsync JobCommand, logger *slog.Logger, opts ApplyOptions) error {
 	versionInfo, fullpath, err := rsync.GetVersionInfo()
 	if err != nil {
 		logger.Info(fmt.Sprintf("Failed to fetch rsync version: %v", err))
@@ -80,35 +126,197 @@ func (cfg Config) Apply(rsync JobCommand, logger *slog.Logger) error {
 		logger.Info("Rsync Version Info: " + versionInfo)
 	}
 
-	counts := make(map[JobStatus]int)
-	allJobs := cfg.AllJobs()
+	summary := NewRunSummary()
+	allJobs := cfg.scheduledJobs()
+
+	if opts.Report != nil {
+		opts.Report.start(versionInfo, time.Now())
+	}
+
+	runScheduled(ctx, rsync, allJobs, opts, func(job Job, result JobResult, duration time.Duration) {
+		rsync.ReportJobStatus(job.Name, result, logger)
+		summary.Add(result)
+
+		if opts.Report != nil {
+			opts.Report.add(job, result, duration, rsync.JobLogPath(job))
+		}
+	})
+
+	rsync.ReportSummary(summary, logger)
+
+	if opts.Report != nil {
+		opts.Report.finish(summary, time.Now())
+	}
+
+	counts := summary.Counts
 
-	for job := range slices.Values(allJobs) {
-		status := job.Apply(rsync)
-		rsync.ReportJobStatus(job.Name, status, logger)
-		counts[status]++
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %d of %d jobs cancelled", ErrRunCancelled, counts[Cancelled], len(allJobs))
 	}
 
-	rsync.ReportSummary(counts, logger)
+	if failed := counts[Failure] + counts[Timeout] + counts[Refused]; failed > 0 {
+		return fmt.Errorf("%w: %d of %d jobs", ErrJobFailure, failed, len(allJobs))
+	}
 
-	if counts[Failure] > 0 {
-		return fmt.Errorf("%w: %d of %d jobs", ErrJobFailure, counts[Failure], len(allJobs))
+	if counts[Mismatch] > 0 {
+		return fmt.Errorf("%w: %d of %d jobs", ErrVerifyMismatch, counts[Mismatch], len(allJobs))
 	}
 
 	return nil
 }
 
+// LoadConfig decodes a configuration. Keys that are not part of the configuration
+// format are errors, reported with their line and column.
 func LoadConfig(reader io.Reader) (Config, error) {
-	var cfg Config
+	cfg, diagnostics := decodeConfig(reader, "")
+	if err := diagnostics.Err(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// decodeConfig decodes the configuration read from file, which names the file in the
+// positions of its mappings, its jobs and its problems. Unknown keys are reported and
+// values that fail to decode are reported and left out, so that the rest of the
+// configuration can still be checked. Only a file that is not YAML decodes to nothing.
+func decodeConfig(reader io.Reader, file string) (Config, Diagnostics) {
+	var document yaml.Node
 
-	err := yaml.NewDecoder(reader).Decode(&cfg)
+	err := yaml.NewDecoder(reader).Decode(&document)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to decode YAML: %w", err)
+		return Config{}, yamlDiagnostics(err, file)
 	}
 
-	// Defaults are handled in Job.UnmarshalYAML
+	schema := ConfigSchema()
 
-	return cfg, nil
+	// yaml ignores the unknown keys.
+	diagnostics := knownFieldsChecker{file: file, definitions: schema.Definitions}.check(&document, schema, "Config")
+
+	for {
+		var cfg Config
+
+		err = document.Decode(&cfg)
+		if err == nil {
+			// Defaults are handled in Job.UnmarshalYAML
+
+			recordPositions(&cfg, &document, file)
+
+			return cfg, diagnostics
+		}
+
+		// A value rejected by its type stops yaml, so each one is dropped from the
+		// document before decoding it again. A line is reported once: the problems of
+		// what is left of a value, or of an unknown key in it, are not new.
+		dropped := false
+
+		for diagnostic := range slices.Values(yamlDiagnostics(err, file)) {
+			if diagnostic.Line == 0 {
+				diagnostics = append(diagnostics, diagnostic)
+
+				continue
+			}
+
+			if !slices.ContainsFunc(diagnostics, func(d Diagnostic) bool { return d.Line == diagnostic.Line }) {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+
+			if dropValue(&document, diagnostic.Line) {
+				dropped = true
+			}
+		}
+
+		if !dropped {
+			return Config{}, diagnostics
+		}
+	}
+}
+
+// dropValue removes the innermost mapping value or sequence item of node that starts at
+// line, and reports whether there was one.
+func dropValue(node *yaml.Node, line int) bool {
+	step := 1
+	if node.Kind == yaml.MappingNode {
+		step = 2 //nolint:mnd // key and value
+	}
+
+	for i := step - 1; i < len(node.Content); i += step {
+		if dropValue(node.Content[i], line) {
+			return true
+		}
+	}
+
+	if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode {
+		return false
+	}
+
+	for i := step - 1; i < len(node.Content); i += step {
+		if node.Content[i].Line == line {
+			node.Content = slices.Delete(node.Content, i-step+1, i+1)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordPositions sets the positions of the mappings and jobs of cfg to where they are
+// listed in document.
+func recordPositions(cfg *Config, document *yaml.Node, file string) {
+	mappings := sequenceItems(mappingValue(document, "mappings"))
+
+	for mIdx := range min(len(mappings), len(cfg.Mappings)) {
+		mapping := &cfg.Mappings[mIdx]
+		mapping.Position = Position{File: file, Line: mappings[mIdx].Line, Column: mappings[mIdx].Column}
+
+		jobs := sequenceItems(mappingValue(mappings[mIdx], "jobs"))
+
+		for jIdx := range min(len(jobs), len(mapping.Jobs)) {
+			mapping.Jobs[jIdx].Position = Position{File: file, Line: jobs[jIdx].Line, Column: jobs[jIdx].Column}
+		}
+	}
+}
+
+// valueNode follows documents and aliases to the node holding the value of node.
+func valueNode(node *yaml.Node) *yaml.Node {
+	for node != nil && (node.Kind == yaml.DocumentNode || node.Kind == yaml.AliasNode) {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		} else if len(node.Content) > 0 {
+			node = node.Content[0]
+		} else {
+			node = nil
+		}
+	}
+
+	return node
+}
+
+// mappingValue returns the value of key in the YAML mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = valueNode(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// sequenceItems returns the items of the YAML sequence node, or nil.
+func sequenceItems(node *yaml.Node) []*yaml.Node {
+	node = valueNode(node)
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	return node.Content
 }
 
 func SubstituteVariables(input string, variables map[string]string) string {
@@ -120,6 +328,20 @@ func SubstituteVariables(input string, variables map[string]string) string {
 	return strings.NewReplacer(oldnew...).Replace(input)
 }
 
+// resolveEach resolves variables and macros in each of inputs.
+func resolveEach(inputs []string, variables map[string]string) error {
+	for i, input := range inputs {
+		resolved, err := resolveField(input, variables)
+		if err != nil {
+			return fmt.Errorf("resolving %q: %w", input, err)
+		}
+
+		inputs[i] = resolved
+	}
+
+	return nil
+}
+
 func resolveField(input string, variables map[string]string) (string, error) {
 	result := SubstituteVariables(input, variables)
 
@@ -164,62 +386,79 @@ func ResolveVariables(variables map[string]string) map[string]string {
 // and unresolved macros are validated — this is the final resolution step.
 // When joinPaths is false, only variable/macro substitution is performed,
 // used by expandIncludes so that path joining happens only once.
-func resolveFields(cfg Config, joinPaths bool) (Config, error) {
+// Fields that fail to resolve are reported and left as they are.
+func resolveFields(cfg Config, joinPaths bool) (Config, Diagnostics) {
 	resolved := cfg
 	resolved.Variables = ResolveVariables(cfg.Variables)
 
+	diagnostics := slices.Concat(
+		diagnose(resolveInto(&resolved.LockDir, resolved.Variables), inConfig("lock_dir")),
+		diagnose(resolveRsyncOptions(resolved.Rsync, resolved.Variables), inConfig("rsync")),
+		diagnose(resolveHooks(resolved.Hooks, resolved.Variables), inConfig("hooks")),
+		diagnose(resolveNotifications(resolved.Notifications, resolved.Variables), inConfig("notifications")))
+
 	for mIdx := range resolved.Mappings {
-		err := resolveMapping(&resolved.Mappings[mIdx], resolved.Variables, joinPaths)
-		if err != nil {
-			return Config{}, err
+		diagnostics = append(diagnostics, resolveMapping(&resolved.Mappings[mIdx], resolved.Variables, joinPaths)...)
+
+		if joinPaths {
+			inheritMappingDefaults(&resolved.Mappings[mIdx], resolved)
 		}
 	}
 
+	// Fields that failed to resolve still hold their macros.
 	if joinPaths {
-		err := ValidateNoUnresolvedMacros(resolved)
-		if err != nil {
-			return Config{}, fmt.Errorf("macro resolution incomplete: %w", err)
-		}
+		diagnostics = append(diagnostics,
+			diagnose(ValidateNoUnresolvedMacros(checkable(resolved, diagnostics)), inConfig(""))...)
 	}
 
-	return resolved, nil
+	return resolved, diagnostics
 }
 
-func resolveMapping(mapping *Mapping, variables map[string]string, joinPaths bool) error {
-	var err error
-
-	mapping.Name, err = resolveField(mapping.Name, variables)
+// resolveInto resolves variables and macros in *value, leaving it as it is on error.
+func resolveInto(value *string, variables map[string]string) error {
+	resolved, err := resolveField(*value, variables)
 	if err != nil {
-		return fmt.Errorf("resolving mapping name %q: %w", mapping.Name, err)
+		return fmt.Errorf("resolving %q: %w", *value, err)
 	}
 
-	mapping.Source, err = resolveField(mapping.Source, variables)
-	if err != nil {
-		return fmt.Errorf("resolving mapping source %q: %w", mapping.Source, err)
-	}
+	*value = resolved
 
-	mapping.Target, err = resolveField(mapping.Target, variables)
-	if err != nil {
-		return fmt.Errorf("resolving mapping target %q: %w", mapping.Target, err)
+	return nil
+}
+
+func resolveMapping(mapping *Mapping, variables map[string]string, joinPaths bool) Diagnostics {
+	var diagnostics Diagnostics
+
+	report := func(field string, err error) {
+		diagnostics = append(diagnostics, diagnose(err, inMapping(*mapping, field))...)
 	}
 
+	report("name", resolveInto(&mapping.Name, variables))
+	report("source", resolveInto(&mapping.Source, variables))
+	report("rsync", resolveRsyncOptions(mapping.Rsync, variables))
+	report("filters", resolveFilterRules(mapping.Filters, variables))
+	report("exclude_from", resolveEach(mapping.ExcludeFrom, variables))
+	report("hooks", resolveHooks(mapping.Hooks, variables))
+	report("source", resolveMappingLocation(&mapping.SourceRemote, &mapping.Source, variables))
+	report("target", resolveInto(&mapping.Target, variables))
+	report("target", resolveMappingLocation(&mapping.TargetRemote, &mapping.Target, variables))
+
 	for jIdx := range mapping.Jobs {
 		job := &mapping.Jobs[jIdx]
 
-		job.Name, err = resolveField(job.Name, variables)
-		if err != nil {
-			return fmt.Errorf("resolving job name %q: %w", job.Name, err)
-		}
-
-		job.Source, err = resolveField(job.Source, variables)
-		if err != nil {
-			return fmt.Errorf("resolving job source %q: %w", job.Source, err)
+		reportJob := func(field string, err error) {
+			diagnostics = append(diagnostics, diagnose(err, inJob(*job, field))...)
 		}
 
-		job.Target, err = resolveField(job.Target, variables)
-		if err != nil {
-			return fmt.Errorf("resolving job target %q: %w", job.Target, err)
-		}
+		reportJob("name", resolveInto(&job.Name, variables))
+		reportJob("source", resolveInto(&job.Source, variables))
+		reportJob("target", resolveInto(&job.Target, variables))
+		reportJob("source_remote", resolveRemote(job.SourceRemote, variables))
+		reportJob("target_remote", resolveRemote(job.TargetRemote, variables))
+		reportJob("rsync", resolveRsyncOptions(job.Rsync, variables))
+		reportJob("filters", resolveFilterRules(job.Filters, variables))
+		reportJob("exclude_from", resolveEach(job.ExcludeFrom, variables))
+		reportJob("hooks", resolveHooks(job.Hooks, variables))
 
 		if joinPaths {
 			job.Source = filepath.Join(mapping.Source, job.Source) + "/"
@@ -227,57 +466,156 @@ func resolveMapping(mapping *Mapping, variables map[string]string, joinPaths boo
 		}
 	}
 
+	return diagnostics
+}
+
+// resolveMappingLocation resolves the remote of a mapping side and moves a remote given
+// inline in its path into it, leaving both as they are on error.
+func resolveMappingLocation(remote **Remote, path *string, variables map[string]string) error {
+	resolvedRemote, resolvedPath, err := resolveLocation(*remote, *path, variables)
+	if err != nil {
+		return err
+	}
+
+	*remote, *path = resolvedRemote, resolvedPath
+
 	return nil
 }
 
+// inheritMappingDefaults fills in the job settings left unset from the mapping,
+// and the mapping settings from the config-wide defaults.
+func inheritMappingDefaults(mapping *Mapping, cfg Config) {
+	mapping.MaxDelete = inheritDeleteLimit(mapping.MaxDelete, cfg.MaxDelete)
+	mapping.Rsync = mapping.Rsync.inherit(cfg.Rsync)
+	mapping.Hooks = mapping.Hooks.inherit(cfg.Hooks)
+	mapping.MaxAge = cmp.Or(mapping.MaxAge, cfg.MaxAge)
+	mappingRules := mapping.FilterRules()
+	mappingSource := NormalizePath(mapping.Source)
+
+	for jIdx := range mapping.Jobs {
+		job := &mapping.Jobs[jIdx]
+		job.MappingFilters = rebaseFilterRules(mappingRules,
+			strings.TrimPrefix(NormalizePath(job.Source), mappingSource))
+		job.MaxDelete = inheritDeleteLimit(job.MaxDelete, mapping.MaxDelete)
+		job.Rsync = job.Rsync.inherit(mapping.Rsync)
+		job.Hooks = job.Hooks.inherit(mapping.Hooks)
+		job.MaxAge = cmp.Or(job.MaxAge, mapping.MaxAge)
+
+		if job.Mode == "" {
+			job.Mode = mapping.Mode
+		}
+
+		if job.Retention == nil {
+			job.Retention = mapping.Retention
+		}
+
+		if job.SourceRemote == nil {
+			job.SourceRemote = mapping.SourceRemote
+		}
+
+		if job.TargetRemote == nil {
+			job.TargetRemote = mapping.TargetRemote
+		}
+	}
+}
+
 // ResolveConfig resolves all variables, macros, and joins job paths with mapping base paths.
 func ResolveConfig(cfg Config) (Config, error) {
-	return resolveFields(cfg, true)
+	resolved, diagnostics := resolveFields(cfg, true)
+	if err := diagnostics.Err(); err != nil {
+		return Config{}, err
+	}
+
+	return resolved, nil
 }
 
+// ValidateJobNames reports each duplicate job name and each name with spaces or
+// non-ASCII characters.
 func ValidateJobNames(jobs []Job) error {
-	var invalidNames []string
+	var errs []error
 
 	nameSet := make(map[string]bool)
 
 	for job := range slices.Values(jobs) {
 		if nameSet[job.Name] {
-			invalidNames = append(invalidNames, "duplicate job name: "+job.Name)
+			errs = append(errs, jobDiagnostic(job, "name",
+				fmt.Errorf("%w: duplicate job name: %s", ErrJobValidation, job.Name)))
 		} else {
 			nameSet[job.Name] = true
 		}
 
 		if strings.ContainsFunc(job.Name, func(r rune) bool { return r > 127 || r == ' ' }) {
-			invalidNames = append(invalidNames, "invalid characters in job name: "+job.Name)
+			errs = append(errs, jobDiagnostic(job, "name",
+				fmt.Errorf("%w: invalid characters in job name: %s", ErrJobValidation, job.Name)))
 		}
 	}
 
-	if len(invalidNames) > 0 {
-		return fmt.Errorf("%w: %v", ErrJobValidation, invalidNames)
-	}
-
-	return nil
+	return errors.Join(errs...)
 }
 
+// validateJobPaths reports each job whose path of pathType lies within the one of
+// another job.
 func validateJobPaths(jobs []Job, pathType string, getPath func(job Job) string) error {
+	var errs []error
+
 	for i, job1 := range jobs {
 		for j, job2 := range jobs {
 			if i != j {
 				path1, path2 := NormalizePath(getPath(job1)), NormalizePath(getPath(job2))
 
-				excluded := pathType == "source" && slices.ContainsFunc(job2.Exclusions, func(exclusion string) bool {
-					return strings.HasPrefix(path1, NormalizePath(filepath.Join(job2.Source, exclusion)))
-				})
+				// A source excluded by the other job's filter rules is not backed up twice.
+				excluded := false
+				if pathType == "source" {
+					_, excluded = FilterMatcher{}.Excluded(job2.FilterRules(), getPath(job2), path1)
+				}
 
 				if !excluded && strings.HasPrefix(path1, path2) {
-					return fmt.Errorf("%w: job '%s' has a %s path overlapping with job '%s'",
-						ErrOverlappingPath, job1.Name, pathType, job2.Name)
+					errs = append(errs, jobDiagnostic(job1, pathType,
+						fmt.Errorf("%w: job '%s' has a %s path overlapping with job '%s'",
+							ErrOverlappingPath, job1.Name, pathType, job2.Name)))
 				}
 			}
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// resolveFilterFiles makes the merge and exclude_from files of all filter rules
+// relative to the config directory.
+func resolveFilterFiles(cfg Config, configDir string) {
+	for mIdx := range cfg.Mappings {
+		mapping := &cfg.Mappings[mIdx]
+		absFilterFiles(mapping.Filters, configDir)
+		absPaths(mapping.ExcludeFrom, configDir)
+
+		for jIdx := range mapping.Jobs {
+			job := &mapping.Jobs[jIdx]
+			absFilterFiles(job.Filters, configDir)
+			absFilterFiles(job.MappingFilters, configDir)
+			absPaths(job.ExcludeFrom, configDir)
+		}
+	}
+}
+
+// validateRsyncOptions checks the rsync options of the configuration, of each mapping
+// and of each job. Options are inherited, so a problem is reported only at the level
+// that sets the options having it.
+func validateRsyncOptions(cfg Config) Diagnostics {
+	inherited := diagnose(cfg.Rsync.Validate(), inConfig("rsync"))
+	diagnostics := inherited
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		mappingLevel := diagnose(mapping.Rsync.Validate(), inMapping(mapping, "rsync")).without(inherited)
+		diagnostics = append(diagnostics, mappingLevel...)
+
+		for job := range slices.Values(mapping.Jobs) {
+			diagnostics = append(diagnostics,
+				diagnose(job.Rsync.Validate(), inJob(job, "rsync")).without(inherited).without(mappingLevel)...)
+		}
+	}
+
+	return diagnostics
 }
 
 // ValidateTemplateVars checks that all variables declared in the template section have values.
@@ -301,59 +639,73 @@ func ValidateTemplateVars(cfg Config) error {
 	return nil
 }
 
-func loadTemplateConfig(templatePath string) (Config, error) {
+func loadTemplateConfig(templatePath string) (Config, Diagnostics) {
 	templateFile, err := os.Open(templatePath)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to open: %w", err)
+		return Config{}, Diagnostics{configDiagnostic("include", fmt.Errorf("failed to open: %w", err))}
 	}
 	defer templateFile.Close()
 
-	cfg, err := LoadConfig(templateFile)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse: %w", err)
+	cfg, diagnostics := decodeConfig(templateFile, templatePath)
+	if len(diagnostics) > 0 {
+		return Config{}, diagnostics
 	}
 
 	if len(cfg.Include) > 0 {
-		return Config{}, ErrNestedIncludes
+		return Config{}, Diagnostics{configDiagnostic("include", ErrNestedIncludes)}
 	}
 
 	return cfg, nil
 }
 
-func expandIncludes(cfg *Config, configDir string) error {
+// expandIncludes instantiates each template included by cfg and adds its mappings.
+// Templates with problems are reported and left out.
+func expandIncludes(cfg *Config, configDir string) Diagnostics {
+	var diagnostics Diagnostics
+
 	for inc := range slices.Values(cfg.Include) {
 		templatePath := inc.Uses
 		if !filepath.IsAbs(templatePath) {
 			templatePath = filepath.Join(configDir, templatePath)
 		}
 
-		tmplCfg, err := loadTemplateConfig(templatePath)
-		if err != nil {
-			return fmt.Errorf("include %q: %w", inc.Uses, err)
-		}
+		tmplCfg, tmplDiagnostics := loadTemplateConfig(templatePath)
+		if len(tmplDiagnostics) == 0 {
+			if tmplCfg.Variables == nil {
+				tmplCfg.Variables = make(map[string]string)
+			}
 
-		if tmplCfg.Variables == nil {
-			tmplCfg.Variables = make(map[string]string)
-		}
+			maps.Copy(tmplCfg.Variables, inc.With)
 
-		maps.Copy(tmplCfg.Variables, inc.With)
+			tmplDiagnostics = diagnose(ValidateTemplateVars(tmplCfg), inConfig("include"))
+		}
 
-		err = ValidateTemplateVars(tmplCfg)
-		if err != nil {
-			return fmt.Errorf("include %q: %w", inc.Uses, err)
+		if len(tmplDiagnostics) == 0 {
+			tmplCfg, tmplDiagnostics = resolveFields(tmplCfg, false)
 		}
 
-		resolved, err := resolveFields(tmplCfg, false)
-		if err != nil {
-			return fmt.Errorf("include %q: resolving config: %w", inc.Uses, err)
+		if len(tmplDiagnostics) > 0 {
+			diagnostics = append(diagnostics, included(inc, tmplDiagnostics)...)
+
+			continue
 		}
 
-		cfg.Mappings = append(cfg.Mappings, resolved.Mappings...)
+		cfg.Mappings = append(cfg.Mappings, tmplCfg.Mappings...)
 	}
 
 	cfg.Include = nil
 
-	return nil
+	return diagnostics
+}
+
+// included prefixes the messages of the diagnostics of a template with the include
+// that uses it.
+func included(inc Include, diagnostics Diagnostics) Diagnostics {
+	for i := range diagnostics {
+		diagnostics[i].Message = fmt.Sprintf("include %q: %s", inc.Uses, diagnostics[i].Message)
+	}
+
+	return diagnostics
 }
 
 func mergeOverrides(cfg Config, overrides []map[string]string) Config {
@@ -368,55 +720,111 @@ func mergeOverrides(cfg Config, overrides []map[string]string) Config {
 	return cfg
 }
 
+// LoadResolvedConfig loads the configuration at configPath, applies overrides to its
+// variables, and resolves and validates it. Its error is a *ValidationError.
 func LoadResolvedConfig(configPath string, overrides ...map[string]string) (Config, error) {
-	configFile, err := os.Open(configPath)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to open config: %w", err)
+	cfg, diagnostics := ValidateConfigFile(configPath, overrides...)
+	if err := diagnostics.Err(); err != nil {
+		return Config{}, err
 	}
-	defer configFile.Close()
 
-	cfg, err := LoadConfig(configFile)
+	return cfg, nil
+}
+
+// ValidateConfigFile loads, resolves and validates the configuration at configPath like
+// LoadResolvedConfig, and returns every problem found instead of the first one. The
+// configuration returned is usable only without errors among the diagnostics.
+//
+// The checks run in stages: decoding the file, expanding its includes, resolving
+// variables and macros, and checking the resolved jobs. Only a file that is not YAML
+// stops them; values, templates, mappings and jobs with problems are left out of the
+// later stages, which go on with the rest of the configuration.
+func ValidateConfigFile(configPath string, overrides ...map[string]string) (Config, Diagnostics) {
+	cfg, diagnostics := loadConfigFile(configPath, overrides)
+	diagnostics.locate(cfg, configPath)
+
+	return cfg, diagnostics
+}
+
+func loadConfigFile(configPath string, overrides []map[string]string) (Config, Diagnostics) {
+	configFile, err := os.Open(configPath)
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to parse YAML: %w", err)
+		return Config{}, Diagnostics{newDiagnostic(SeverityError, fmt.Errorf("failed to open config: %w", err))}
 	}
+	defer configFile.Close()
 
+	cfg, diagnostics := decodeConfig(configFile, configPath)
 	cfg = mergeOverrides(cfg, overrides)
 
-	return resolveAndValidate(cfg, filepath.Dir(configPath))
+	resolvedCfg, resolveDiagnostics := resolveAndValidate(cfg, filepath.Dir(configPath))
+
+	return resolvedCfg, append(diagnostics, resolveDiagnostics...)
 }
 
-func resolveAndValidate(cfg Config, configDir string) (Config, error) {
-	err := expandIncludes(&cfg, configDir)
-	if err != nil {
-		return Config{}, fmt.Errorf("expanding includes: %w", err)
-	}
+func resolveAndValidate(cfg Config, configDir string) (Config, Diagnostics) {
+	diagnostics := append(expandIncludes(&cfg, configDir),
+		diagnose(ValidateTemplateVars(cfg), inConfig("variables"))...)
 
-	err = ValidateTemplateVars(cfg)
-	if err != nil {
-		return Config{}, fmt.Errorf("template validation failed: %w", err)
+	resolvedCfg, resolveDiagnostics := resolveFields(cfg, true)
+	diagnostics = append(diagnostics, resolveDiagnostics...)
+
+	resolveFilterFiles(resolvedCfg, configDir)
+
+	// The checks go on with the mappings and jobs that resolved. Options and notifications
+	// that did not, which mappings and jobs inherit, are not checked.
+	checked := checkable(resolvedCfg, resolveDiagnostics)
+	allJobs := checked.AllJobs()
+
+	var rsyncDiagnostics, notificationDiagnostics Diagnostics
+
+	if !resolveDiagnostics.inConfigField("rsync") {
+		rsyncDiagnostics = validateRsyncOptions(checked)
 	}
 
-	resolvedCfg, err := ResolveConfig(cfg)
-	if err != nil {
-		return Config{}, fmt.Errorf("config resolution failed: %w", err)
+	if !resolveDiagnostics.inConfigField("notifications") {
+		notificationDiagnostics = diagnose(validateNotifications(checked.Notifications), inConfig("notifications"))
 	}
 
-	allJobs := resolvedCfg.AllJobs()
+	diagnostics = slices.Concat(diagnostics,
+		diagnose(ValidateJobNames(allJobs), inConfig("")),
+		rsyncDiagnostics,
+		diagnose(validateRetryPolicies(allJobs), inConfig("")),
+		diagnose(validateRemotes(checked.Mappings), inConfig("")),
+		notificationDiagnostics,
+		diagnose(validateJobPaths(allJobs, "source", Job.SourceSpec), inConfig("")),
+		diagnose(validateJobPaths(allJobs, "target", Job.TargetSpec), inConfig("")),
+		missingFilterFiles(checked.Mappings))
 
-	err = ValidateJobNames(allJobs)
-	if err != nil {
-		return Config{}, fmt.Errorf("job validation failed: %w", err)
+	return resolvedCfg, diagnostics
+}
+
+// checkable returns cfg without the mappings and jobs that have errors among diagnostics,
+// which still hold the variables and macros that failed to resolve.
+func checkable(cfg Config, diagnostics Diagnostics) Config {
+	if diagnostics.Count(SeverityError) == 0 {
+		return cfg
 	}
 
-	err = validateJobPaths(allJobs, "source", func(job Job) string { return job.Source })
-	if err != nil {
-		return Config{}, fmt.Errorf("job source path validation failed: %w", err)
+	failed := func(mapping, job string, position Position) bool {
+		return slices.ContainsFunc(diagnostics, func(d Diagnostic) bool {
+			return d.Severity == SeverityError && d.Mapping == mapping && d.Job == job && d.Position == position
+		})
 	}
 
-	err = validateJobPaths(allJobs, "target", func(job Job) string { return job.Target })
-	if err != nil {
-		return Config{}, fmt.Errorf("job target path validation failed: %w", err)
+	mappings := make([]Mapping, 0, len(cfg.Mappings))
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		if failed(mapping.Name, "", mapping.Position) {
+			continue
+		}
+
+		mapping.Jobs = slices.DeleteFunc(slices.Clone(mapping.Jobs), func(job Job) bool {
+			return failed("", job.Name, job.Position)
+		})
+		mappings = append(mappings, mapping)
 	}
 
-	return resolvedCfg, nil
+	cfg.Mappings = mappings
+
+	return cfg
 }
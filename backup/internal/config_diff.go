@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+var ErrNothingToDiff = errors.New("nothing to compare")
+
+// JobDiffKind tells whether a job was added, removed or changed between two configurations.
+type JobDiffKind string
+
+const (
+	JobAdded   JobDiffKind = "Added"
+	JobRemoved JobDiffKind = "Removed"
+	JobChanged JobDiffKind = "Changed"
+)
+
+// FieldChange is a change to a setting of a job. Before is empty for the settings of
+// added jobs and for added filter rules, After for removed filter rules.
+type FieldChange struct {
+	Field  string
+	Before string
+	After  string
+	// Risky marks changes after which the job may delete files it did not delete before.
+	Risky bool
+}
+
+// JobDiff lists the changes to a job between two configurations.
+type JobDiff struct {
+	Job     string
+	Kind    JobDiffKind
+	Changes []FieldChange
+}
+
+// ConfigDiff is the difference between the jobs of two resolved configurations.
+type ConfigDiff struct {
+	Jobs []JobDiff
+}
+
+// DiffConfigs compares the jobs of two resolved configurations by name: their source
+// and target, filter rules and the delete and enabled flags. Removed jobs are listed
+// first, followed by the added and changed jobs in the order of after.
+func DiffConfigs(before, after Config) ConfigDiff {
+	previous := make(map[string]Job)
+	for job := range slices.Values(before.AllJobs()) {
+		previous[job.Name] = job
+	}
+
+	current := make(map[string]bool)
+	for job := range slices.Values(after.AllJobs()) {
+		current[job.Name] = true
+	}
+
+	var diff ConfigDiff
+
+	for job := range slices.Values(before.AllJobs()) {
+		if !current[job.Name] {
+			diff.Jobs = append(diff.Jobs, JobDiff{Job: job.Name, Kind: JobRemoved})
+		}
+	}
+
+	for job := range slices.Values(after.AllJobs()) {
+		old, ok := previous[job.Name]
+		if !ok {
+			diff.Jobs = append(diff.Jobs, JobDiff{Job: job.Name, Kind: JobAdded, Changes: addedJob(job)})
+
+			continue
+		}
+
+		if changes := diffJob(old, job); len(changes) > 0 {
+			diff.Jobs = append(diff.Jobs, JobDiff{Job: job.Name, Kind: JobChanged, Changes: changes})
+		}
+	}
+
+	return diff
+}
+
+// deletes reports whether running job deletes files from its target.
+func (job Job) deletes() bool {
+	return job.Enabled && job.Delete
+}
+
+// addedJob lists the paths of an added job, and its delete and enabled flags where
+// they are not the defaults of a job that does not delete.
+func addedJob(job Job) []FieldChange {
+	changes := []FieldChange{
+		{Field: "source", After: job.SourceSpec()},
+		{Field: "target", After: job.TargetSpec()},
+	}
+
+	if job.Delete {
+		changes = append(changes, FieldChange{Field: "delete", After: "true", Risky: job.deletes()})
+	}
+
+	if !job.Enabled {
+		changes = append(changes, FieldChange{Field: "enabled", After: "false"})
+	}
+
+	return changes
+}
+
+// diffJob compares the settings of a job present in both configurations.
+func diffJob(before, after Job) []FieldChange {
+	var changes []FieldChange
+
+	field := func(name, old, value string, risky bool) {
+		if old != value {
+			changes = append(changes, FieldChange{Field: name, Before: old, After: value, Risky: risky})
+		}
+	}
+
+	newlyDeletes := after.deletes() && !before.deletes()
+
+	// A job that deletes removes the files of its target that are not in its new source,
+	// and the files of its new target that are not in its source.
+	field("source", before.SourceSpec(), after.SourceSpec(), after.deletes())
+	field("target", before.TargetSpec(), after.TargetSpec(), after.deletes())
+	field("delete", strconv.FormatBool(before.Delete), strconv.FormatBool(after.Delete), newlyDeletes)
+	field("enabled", strconv.FormatBool(before.Enabled), strconv.FormatBool(after.Enabled), newlyDeletes)
+
+	oldRules, rules := filterArguments(before), filterArguments(after)
+
+	for rule := range slices.Values(oldRules) {
+		if !slices.Contains(rules, rule) {
+			changes = append(changes, FieldChange{Field: "filter", Before: rule})
+		}
+	}
+
+	for rule := range slices.Values(rules) {
+		if !slices.Contains(oldRules, rule) {
+			changes = append(changes, FieldChange{Field: "filter", After: rule})
+		}
+	}
+
+	return changes
+}
+
+func filterArguments(job Job) []string {
+	var args []string
+	for rule := range slices.Values(job.FilterRules()) {
+		args = append(args, rule.Argument())
+	}
+
+	return args
+}
+
+// Risky returns the number of risky changes.
+func (d ConfigDiff) Risky() int {
+	risky := 0
+
+	for job := range slices.Values(d.Jobs) {
+		for change := range slices.Values(job.Changes) {
+			if change.Risky {
+				risky++
+			}
+		}
+	}
+
+	return risky
+}
+
+// Lines returns the diff as lines of text. Each job is introduced by its kind and name,
+// followed by its changes; risky changes are marked with "!".
+func (d ConfigDiff) Lines() []string {
+	if len(d.Jobs) == 0 {
+		return []string{"No changes to jobs."}
+	}
+
+	counts := make(map[JobDiffKind]int)
+
+	var lines []string
+
+	for job := range slices.Values(d.Jobs) {
+		counts[job.Kind]++
+		lines = append(lines, fmt.Sprintf("%s %s", job.Kind, job.Job))
+
+		for change := range slices.Values(job.Changes) {
+			lines = append(lines, change.line())
+		}
+	}
+
+	return append(lines, fmt.Sprintf("Summary: %d added, %d removed, %d changed, %d risky changes",
+		counts[JobAdded], counts[JobRemoved], counts[JobChanged], d.Risky()))
+}
+
+func (change FieldChange) line() string {
+	marker := " "
+	if change.Risky {
+		marker = "!"
+	}
+
+	switch {
+	case change.Field == "filter" && change.Before == "":
+		return fmt.Sprintf("%s filter: added %s", marker, change.After)
+	case change.Field == "filter":
+		return fmt.Sprintf("%s filter: removed %s", marker, change.Before)
+	case change.Before == "":
+		return fmt.Sprintf("%s %s: %s", marker, change.Field, change.After)
+	default:
+		return fmt.Sprintf("%s %s: %s -> %s", marker, change.Field, change.Before, change.After)
+	}
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidDeleteLimit = errors.New("invalid max_delete")
+
+const unlimitedDeletes = "none"
+
+// DeleteLimit caps the number of entries a job may delete from its target, either as an
+// absolute count ("500") or as a percentage of the entries in the target ("10%").
+// "none" disables the limit, e.g. to override a default for a single job.
+type DeleteLimit struct {
+	Value     float64
+	Percent   bool
+	Unlimited bool
+}
+
+// ParseDeleteLimit parses a max_delete value.
+func ParseDeleteLimit(value string) (DeleteLimit, error) {
+	value = strings.TrimSpace(value)
+
+	if value == unlimitedDeletes {
+		return DeleteLimit{Unlimited: true}, nil
+	}
+
+	if number, isPercent := strings.CutSuffix(value, "%"); isPercent {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return DeleteLimit{}, fmt.Errorf("%w: %q is not a percentage between 0%% and 100%%", ErrInvalidDeleteLimit, value)
+		}
+
+		return DeleteLimit{Value: percent, Percent: true}, nil
+	}
+
+	count, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || count < 0 {
+		return DeleteLimit{}, fmt.Errorf("%w: %q must be a number, a percentage or %q",
+			ErrInvalidDeleteLimit, value, unlimitedDeletes)
+	}
+
+	return DeleteLimit{Value: float64(count)}, nil
+}
+
+func (l DeleteLimit) String() string {
+	switch {
+	case l.Unlimited:
+		return unlimitedDeletes
+	case l.Percent:
+		return strconv.FormatFloat(l.Value, 'f', -1, 64) + "%"
+	default:
+		return strconv.FormatFloat(l.Value, 'f', 0, 64)
+	}
+}
+
+// Exceeded reports whether deleting deletions of the total entries in the target
+// goes over the limit.
+func (l DeleteLimit) Exceeded(deletions, total int64) bool {
+	switch {
+	case l.Unlimited:
+		return false
+	case l.Percent:
+		return total > 0 && float64(deletions)*100 > l.Value*float64(total)
+	default:
+		return float64(deletions) > l.Value
+	}
+}
+
+// UnmarshalYAML parses the limit from a scalar such as 500 or "10%".
+func (l *DeleteLimit) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%w: line %d: expected a number, a percentage or %q",
+			ErrInvalidDeleteLimit, node.Line, unlimitedDeletes)
+	}
+
+	limit, err := ParseDeleteLimit(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	*l = limit
+
+	return nil
+}
+
+// MarshalYAML writes the limit in the form it is configured.
+func (l DeleteLimit) MarshalYAML() (any, error) {
+	return l.String(), nil
+}
+
+// inheritDeleteLimit returns limit, or fallback when limit is not set.
+func inheritDeleteLimit(limit, fallback *DeleteLimit) *DeleteLimit {
+	if limit != nil {
+		return limit
+	}
+
+	return fallback
+}
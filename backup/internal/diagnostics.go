@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownValidationFormat = errors.New("unknown validation format")
+
+// Severity tells whether a diagnostic makes a configuration unusable.
+type Severity string
+
+const (
+	// SeverityError is a problem that keeps the configuration from being loaded.
+	SeverityError Severity = "error"
+	// SeverityWarning is a likely mistake that does not keep the configuration from being loaded.
+	SeverityWarning Severity = "warning"
+)
+
+// Position is where a mapping, a job or a key is defined in a configuration file.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// String returns the position as "file:line:column", leaving out the parts not known.
+func (p Position) String() string {
+	parts := make([]string, 0, 3) //nolint:mnd // file, line and column
+
+	if p.File != "" {
+		parts = append(parts, p.File)
+	}
+
+	if p.Line > 0 {
+		parts = append(parts, fmt.Sprint(p.Line))
+
+		if p.Column > 0 {
+			parts = append(parts, fmt.Sprint(p.Column))
+		}
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// Diagnostic is a problem found in a configuration, with the mapping, job and field it
+// concerns where they are known.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Mapping  string   `json:"mapping,omitempty"`
+	Job      string   `json:"job,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Position
+	Message string `json:"message"`
+	// Err is the problem, wrapping the static error it is an instance of.
+	Err error `json:"-"`
+}
+
+func newDiagnostic(severity Severity, err error) Diagnostic {
+	return Diagnostic{Severity: severity, Message: err.Error(), Err: err}
+}
+
+// configDiagnostic is an error in a top-level field of the configuration.
+func configDiagnostic(field string, err error) Diagnostic {
+	diagnostic := newDiagnostic(SeverityError, err)
+	diagnostic.Field = field
+
+	return diagnostic
+}
+
+// mappingDiagnostic is an error in a field of mapping.
+func mappingDiagnostic(mapping Mapping, field string, err error) Diagnostic {
+	diagnostic := configDiagnostic(field, err)
+	diagnostic.Mapping, diagnostic.Position = mapping.Name, mapping.Position
+
+	return diagnostic
+}
+
+// jobDiagnostic is an error in a field of job.
+func jobDiagnostic(job Job, field string, err error) Diagnostic {
+	diagnostic := configDiagnostic(field, err)
+	diagnostic.Job, diagnostic.Position = job.Name, job.Position
+
+	return diagnostic
+}
+
+// inConfig, inMapping and inJob return the functions diagnose builds the diagnostics
+// of errors in field of the configuration, of mapping and of job with.
+func inConfig(field string) func(error) Diagnostic {
+	return func(err error) Diagnostic { return configDiagnostic(field, err) }
+}
+
+func inMapping(mapping Mapping, field string) func(error) Diagnostic {
+	return func(err error) Diagnostic { return mappingDiagnostic(mapping, field, err) }
+}
+
+func inJob(job Job, field string) func(error) Diagnostic {
+	return func(err error) Diagnostic { return jobDiagnostic(job, field, err) }
+}
+
+// yamlDiagnostics returns a diagnostic for each problem of err, an error decoding the
+// YAML of file, at the line yaml or the type rejecting a value reports it on.
+func yamlDiagnostics(err error, file string) Diagnostics {
+	messages := []string{err.Error()}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	diagnostics := make(Diagnostics, 0, len(messages))
+
+	for message := range slices.Values(messages) {
+		line, message := cutLine(strings.TrimPrefix(message, "yaml: "))
+
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: SeverityError, Position: Position{File: file, Line: line},
+			Message: "failed to parse YAML: " + message, Err: err,
+		})
+	}
+
+	return diagnostics
+}
+
+// cutLine returns the line of a "line N: " in message, at its start or after a ": ", and
+// message without it. The line is zero when there is none.
+func cutLine(message string) (int, string) {
+	for start := 0; start < len(message); {
+		index := strings.Index(message[start:], "line ")
+		if index < 0 {
+			break
+		}
+
+		index += start
+
+		var line int
+
+		_, err := fmt.Sscanf(message[index:], "line %d:", &line)
+		if err == nil && (index == 0 || strings.HasSuffix(message[:index], ": ")) {
+			_, rest, _ := strings.Cut(message[index:], ": ")
+
+			return line, message[:index] + rest
+		}
+
+		start = index + len("line ")
+	}
+
+	return 0, message
+}
+
+// inConfigField reports whether there is an error in field of the configuration itself,
+// outside of its mappings and jobs.
+func (d Diagnostics) inConfigField(field string) bool {
+	return slices.ContainsFunc(d, func(diagnostic Diagnostic) bool {
+		return diagnostic.Severity == SeverityError && diagnostic.Mapping == "" && diagnostic.Job == "" &&
+			diagnostic.Field == field
+	})
+}
+
+// subject names what the diagnostic is about: its job, mapping and field.
+func (d Diagnostic) subject() string {
+	var subject string
+
+	switch {
+	case d.Job != "":
+		subject = fmt.Sprintf("job '%s'", d.Job)
+	case d.Mapping != "":
+		subject = fmt.Sprintf("mapping '%s'", d.Mapping)
+	}
+
+	if d.Field != "" {
+		subject = strings.TrimSpace(subject + " " + d.Field)
+	}
+
+	return subject
+}
+
+func (d Diagnostic) Error() string {
+	message := d.Message
+	if subject := d.subject(); subject != "" {
+		message = subject + ": " + message
+	}
+
+	if position := d.Position.String(); position != "" {
+		message = position + ": " + message
+	}
+
+	return message
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+// Diagnostics are the problems found in a configuration.
+type Diagnostics []Diagnostic
+
+// Count returns the number of diagnostics of severity.
+func (d Diagnostics) Count(severity Severity) int {
+	count := 0
+
+	for diagnostic := range slices.Values(d) {
+		if diagnostic.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Err returns a *ValidationError holding the errors of d, or nil without errors.
+func (d Diagnostics) Err() error {
+	errs := slices.DeleteFunc(slices.Clone(d), func(diagnostic Diagnostic) bool {
+		return diagnostic.Severity != SeverityError
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Diagnostics: errs}
+}
+
+// without returns the diagnostics of d whose message is not among the ones of reported.
+func (d Diagnostics) without(reported Diagnostics) Diagnostics {
+	return slices.DeleteFunc(d, func(diagnostic Diagnostic) bool {
+		return slices.ContainsFunc(reported, func(other Diagnostic) bool { return other.Message == diagnostic.Message })
+	})
+}
+
+// Report returns the diagnostics as lines of text, grouped by the job or mapping they
+// concern, or under "Configuration" for the others, and followed by a summary.
+func (d Diagnostics) Report() []string {
+	type group struct {
+		title string
+		lines []string
+	}
+
+	var groups []*group
+
+	byTitle := make(map[string]*group)
+
+	for diagnostic := range slices.Values(d) {
+		title := "Configuration"
+
+		switch {
+		case diagnostic.Job != "":
+			title = "Job " + diagnostic.Job
+		case diagnostic.Mapping != "":
+			title = "Mapping " + diagnostic.Mapping
+		}
+
+		current, ok := byTitle[title]
+		if !ok {
+			current = &group{title: title}
+			byTitle[title] = current
+			groups = append(groups, current)
+		}
+
+		line := fmt.Sprintf("%s: %s", diagnostic.Severity, diagnostic.Message)
+		if diagnostic.Field != "" {
+			line = fmt.Sprintf("%s: %s: %s", diagnostic.Severity, diagnostic.Field, diagnostic.Message)
+		}
+
+		if position := diagnostic.Position.String(); position != "" {
+			line = position + ": " + line
+		}
+
+		current.lines = append(current.lines, "  "+line)
+	}
+
+	var lines []string
+
+	for group := range slices.Values(groups) {
+		lines = append(lines, group.title)
+		lines = append(lines, group.lines...)
+	}
+
+	return append(lines, fmt.Sprintf("Summary: %d errors, %d warnings",
+		d.Count(SeverityError), d.Count(SeverityWarning)))
+}
+
+// locate fills in the mapping of the diagnostics of jobs, and file for the diagnostics
+// without a position.
+func (d Diagnostics) locate(cfg Config, file string) {
+	mappings := make(map[string]string)
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		for job := range slices.Values(mapping.Jobs) {
+			mappings[job.Name] = mapping.Name
+		}
+	}
+
+	for i := range d {
+		if d[i].Job != "" && d[i].Mapping == "" {
+			d[i].Mapping = mappings[d[i].Job]
+		}
+
+		if d[i].File == "" {
+			d[i].File = file
+		}
+	}
+}
+
+// diagnose returns a diagnostic built by diagnostic for each error joined in err.
+// Diagnostics joined in err are kept as they are.
+func diagnose(err error, diagnostic func(error) Diagnostic) Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	//nolint:errorlint // only errors joined at the top level are split
+	switch err := err.(type) {
+	case Diagnostic:
+		return Diagnostics{err}
+	case interface{ Unwrap() []error }:
+		var diagnostics Diagnostics
+		for part := range slices.Values(err.Unwrap()) {
+			diagnostics = append(diagnostics, diagnose(part, diagnostic)...)
+		}
+
+		return diagnostics
+	default:
+		return Diagnostics{diagnostic(err)}
+	}
+}
+
+// ValidationError is returned for a configuration with errors. It unwraps to each of them.
+type ValidationError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Diagnostics))
+	for diagnostic := range slices.Values(e.Diagnostics) {
+		messages = append(messages, diagnostic.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Diagnostics))
+	for diagnostic := range slices.Values(e.Diagnostics) {
+		errs = append(errs, diagnostic)
+	}
+
+	return errs
+}
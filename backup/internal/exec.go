@@ -1,29 +1,140 @@
 package internal
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// TerminateGracePeriod is how long a cancelled command may take to exit after
+// being sent SIGTERM before it is killed.
+const TerminateGracePeriod = 10 * time.Second
+
+// OutputStream identifies the stream a line of command output was written to.
+type OutputStream int
+
+const (
+	// Stdout is the standard output stream of a command.
+	Stdout OutputStream = iota
+	// Stderr is the standard error stream of a command.
+	Stderr
+)
+
+// LineHandler receives each line of command output, without its line terminator,
+// as soon as it is produced. It is never called concurrently.
+type LineHandler func(stream OutputStream, line string)
+
 // Exec abstracts command execution for testability.
 type Exec interface {
-	Execute(name string, args ...string) ([]byte, error)
+	Execute(ctx context.Context, name string, args ...string) ([]byte, error)
+	Stream(ctx context.Context, handler LineHandler, name string, args ...string) error
 }
 
 // OsExec implements Exec using actual os/exec.
 type OsExec struct{}
 
-// Execute runs the actual command.
-func (r *OsExec) Execute(name string, args ...string) ([]byte, error) {
-	ctx := context.Background()
+// command prepares a command that receives SIGTERM when ctx is cancelled or
+// times out, so that rsync can shut down cleanly, and is killed if it is still
+// running after TerminateGracePeriod.
+func (r *OsExec) command(ctx context.Context, name string, args ...string) *exec.Cmd {
 	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = TerminateGracePeriod
+
+	return cmd
+}
 
-	output, err := cmd.CombinedOutput()
+// Execute runs the actual command and returns its combined output.
+func (r *OsExec) Execute(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := r.command(ctx, name, args...).CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute command '%s %s': %w", name, strings.Join(args, " "), err)
 	}
 
 	return output, nil
 }
+
+// Stream runs the actual command and passes its stdout and stderr to handler line by line
+// while it runs, without buffering the whole output. The output is read until the command
+// exits and, for at most TerminateGracePeriod after that, until its pipes are closed, so
+// that a child left running by the command, such as ssh, cannot keep it from returning.
+func (r *OsExec) Stream(ctx context.Context, handler LineHandler, name string, args ...string) error {
+	cmd := r.command(ctx, name, args...)
+
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start command '%s %s': %w", name, strings.Join(args, " "), err)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	serialized := func(stream OutputStream, line string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handler(stream, line)
+	}
+
+	wg.Go(func() { readLines(stdout, Stdout, serialized) })
+	wg.Go(func() { readLines(stderr, Stderr, serialized) })
+
+	err = cmd.Wait()
+
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	wg.Wait()
+
+	// ErrWaitDelay means the command succeeded but left its output open.
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return fmt.Errorf("failed to execute command '%s %s': %w", name, strings.Join(args, " "), err)
+	}
+
+	return nil
+}
+
+// ExitCode returns the exit code of a command that failed with err, if it ran at all.
+func ExitCode(err error) (int, bool) {
+	var exitErr interface{ ExitCode() int }
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() < 0 {
+		return 0, false
+	}
+
+	return exitErr.ExitCode(), true
+}
+
+// readLines reads reader until EOF and passes every line to handler.
+func readLines(reader io.Reader, stream OutputStream, handler LineHandler) {
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if line != "" {
+			handler(stream, strings.TrimRight(line, "\r\n"))
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				handler(Stderr, "error reading output: "+err.Error())
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidFilterRule = errors.New("invalid filter rule")
+	ErrMissingFilterFile = errors.New("filter file not found")
+)
+
+// FilterAction is the kind of an rsync filter rule.
+type FilterAction string
+
+const (
+	// FilterInclude keeps the entries matching the pattern, overriding later excludes.
+	FilterInclude FilterAction = "include"
+	// FilterExclude skips the entries matching the pattern.
+	FilterExclude FilterAction = "exclude"
+	// FilterMerge reads further rules from a file.
+	FilterMerge FilterAction = "merge"
+	// FilterDirMerge reads further rules from a file of the given name in each directory.
+	FilterDirMerge FilterAction = "dir_merge"
+	// FilterExcludeFrom reads exclude patterns from a file, one per line.
+	FilterExcludeFrom FilterAction = "exclude_from"
+)
+
+var filterActions = []FilterAction{FilterInclude, FilterExclude, FilterMerge, FilterDirMerge, FilterExcludeFrom}
+
+// FilterRule is a single rsync filter rule. Rules are evaluated in order and the
+// first include or exclude rule matching an entry decides whether it is transferred.
+type FilterRule struct {
+	Action  FilterAction
+	Pattern string
+}
+
+// UnmarshalYAML decodes a rule written as a single-key map, e.g. {exclude: "*.tmp"}.
+func (r *FilterRule) UnmarshalYAML(node *yaml.Node) error {
+	var rule map[FilterAction]string
+
+	err := node.Decode(&rule)
+	if err != nil || len(rule) != 1 {
+		return fmt.Errorf("%w: line %d: expected one of %v with a pattern", ErrInvalidFilterRule, node.Line, filterActions)
+	}
+
+	for action, pattern := range rule {
+		if !slices.Contains(filterActions, action) || pattern == "" {
+			return fmt.Errorf("%w: line %d: %s %q", ErrInvalidFilterRule, node.Line, action, pattern)
+		}
+
+		*r = FilterRule{Action: action, Pattern: pattern}
+	}
+
+	return nil
+}
+
+// MarshalYAML writes the rule in the form it is configured.
+func (r FilterRule) MarshalYAML() (any, error) {
+	return map[FilterAction]string{r.Action: r.Pattern}, nil
+}
+
+func (r FilterRule) String() string {
+	return string(r.Action) + " " + r.Pattern
+}
+
+// Argument returns the rsync argument for the rule.
+func (r FilterRule) Argument() string {
+	switch r.Action {
+	case FilterInclude:
+		return "--include=" + r.Pattern
+	case FilterMerge:
+		return "--filter=merge " + r.Pattern
+	case FilterDirMerge:
+		return "--filter=dir-merge " + r.Pattern
+	case FilterExcludeFrom:
+		return "--exclude-from=" + r.Pattern
+	default:
+		return "--exclude=" + r.Pattern
+	}
+}
+
+// usesFile reports whether the rule's pattern names a file to read rules from,
+// which is resolved relative to the config directory.
+func (r FilterRule) usesFile() bool {
+	return r.Action == FilterMerge || r.Action == FilterExcludeFrom
+}
+
+// filterRules returns the rules of filters followed by exclusions and excludeFrom files.
+func filterRules(filters []FilterRule, exclusions, excludeFrom []string) []FilterRule {
+	rules := slices.Clone(filters)
+
+	for exclusion := range slices.Values(exclusions) {
+		rules = append(rules, FilterRule{Action: FilterExclude, Pattern: exclusion})
+	}
+
+	for file := range slices.Values(excludeFrom) {
+		rules = append(rules, FilterRule{Action: FilterExcludeFrom, Pattern: file})
+	}
+
+	return rules
+}
+
+// rebaseFilterRules adapts the rules of a mapping to a job whose source is the
+// subdirectory subdir of the mapping source. Anchored patterns ("/cache/") are
+// relative to the mapping source: they are rewritten relative to the job source,
+// or dropped if they lie outside of it.
+func rebaseFilterRules(rules []FilterRule, subdir string) []FilterRule {
+	if subdir == "" {
+		return slices.Clone(rules)
+	}
+
+	rebased := make([]FilterRule, 0, len(rules))
+
+	for rule := range slices.Values(rules) {
+		if rule.Action != FilterInclude && rule.Action != FilterExclude || !strings.HasPrefix(rule.Pattern, "/") {
+			rebased = append(rebased, rule)
+
+			continue
+		}
+
+		if pattern, inside := strings.CutPrefix(rule.Pattern, subdir+"/"); inside && pattern != "" {
+			rebased = append(rebased, FilterRule{Action: rule.Action, Pattern: "/" + pattern})
+		}
+	}
+
+	return rebased
+}
+
+// resolveFilterRules resolves variables and macros in the patterns of rules.
+func resolveFilterRules(rules []FilterRule, variables map[string]string) error {
+	for i := range rules {
+		pattern, err := resolveField(rules[i].Pattern, variables)
+		if err != nil {
+			return fmt.Errorf("resolving filter %q: %w", rules[i], err)
+		}
+
+		rules[i].Pattern = pattern
+	}
+
+	return nil
+}
+
+// absFilterFiles makes the files of merge and exclude_from rules absolute, relative to dir.
+func absFilterFiles(rules []FilterRule, dir string) {
+	for i := range rules {
+		if rules[i].usesFile() && !filepath.IsAbs(rules[i].Pattern) {
+			rules[i].Pattern = filepath.Join(dir, rules[i].Pattern)
+		}
+	}
+}
+
+// missingFilterFiles warns about the merge and exclude_from files of the mappings and
+// their jobs that do not exist, which rsync fails on when the job runs.
+func missingFilterFiles(mappings []Mapping) Diagnostics {
+	var diagnostics Diagnostics
+
+	check := func(filters []FilterRule, excludeFrom []string, diagnostic func(field string, err error) Diagnostic) {
+		warnMissing := func(field, path string) {
+			_, err := os.Stat(path)
+			if errors.Is(err, os.ErrNotExist) {
+				warning := diagnostic(field, fmt.Errorf("%w: %s", ErrMissingFilterFile, path))
+				warning.Severity = SeverityWarning
+				diagnostics = append(diagnostics, warning)
+			}
+		}
+
+		for rule := range slices.Values(filters) {
+			if rule.usesFile() {
+				warnMissing("filters", rule.Pattern)
+			}
+		}
+
+		for path := range slices.Values(excludeFrom) {
+			warnMissing("exclude_from", path)
+		}
+	}
+
+	for mapping := range slices.Values(mappings) {
+		check(mapping.Filters, mapping.ExcludeFrom, func(field string, err error) Diagnostic {
+			return mappingDiagnostic(mapping, field, err)
+		})
+
+		for job := range slices.Values(mapping.Jobs) {
+			check(job.Filters, job.ExcludeFrom, func(field string, err error) Diagnostic {
+				return jobDiagnostic(job, field, err)
+			})
+		}
+	}
+
+	return diagnostics
+}
+
+func absPaths(paths []string, dir string) {
+	for i := range paths {
+		if !filepath.IsAbs(paths[i]) {
+			paths[i] = filepath.Join(dir, paths[i])
+		}
+	}
+}
+
+// FilterMatcher evaluates filter rules the way rsync does. Merge files are read from
+// Fs; without an Fs, only the include and exclude rules given directly are evaluated.
+type FilterMatcher struct {
+	Fs afero.Fs
+}
+
+// matchRule is an include or exclude rule, with the directory its anchored pattern is relative to.
+type matchRule struct {
+	include bool
+	pattern string
+	base    string
+	source  FilterRule
+}
+
+// Excluded reports whether rsync, transferring the directory root with rules, skips the
+// directory path. A directory is skipped if it or one of its parents is excluded. It
+// returns the rule that excluded it.
+func (m FilterMatcher) Excluded(rules []FilterRule, root, path string) (FilterRule, bool) {
+	root, path = NormalizePath(root), NormalizePath(path)
+
+	rel, inside := strings.CutPrefix(path, root)
+	if !inside || rel == "" || rel[0] != '/' {
+		return FilterRule{}, false
+	}
+
+	for end := 1; end <= len(rel); end++ {
+		if end < len(rel) && rel[end] != '/' {
+			continue
+		}
+
+		rule, matched := m.firstMatch(rules, root, rel[:end])
+		if matched && !rule.include {
+			return rule.source, true
+		}
+	}
+
+	return FilterRule{}, false
+}
+
+// firstMatch returns the first include or exclude rule matching the directory rel.
+func (m FilterMatcher) firstMatch(rules []FilterRule, root, rel string) (matchRule, bool) {
+	for rule := range slices.Values(rules) {
+		for candidate := range slices.Values(m.expand(rule, root, rel)) {
+			if matchFilterPattern(candidate.pattern, strings.TrimPrefix(rel, candidate.base)) {
+				return candidate, true
+			}
+		}
+	}
+
+	return matchRule{}, false
+}
+
+// expand turns rule into the include and exclude rules that apply to the directory rel.
+func (m FilterMatcher) expand(rule FilterRule, root, rel string) []matchRule {
+	switch rule.Action {
+	case FilterInclude, FilterExclude:
+		return []matchRule{{include: rule.Action == FilterInclude, pattern: rule.Pattern, source: rule}}
+	case FilterMerge:
+		return m.readRules(rule.Pattern, "", false, rule)
+	case FilterExcludeFrom:
+		return m.readRules(rule.Pattern, "", true, rule)
+	default:
+		// Files in deeper directories take precedence over those of their parents.
+		var expanded []matchRule
+
+		for dir := rel; dir != ""; {
+			dir = dir[:strings.LastIndex(dir, "/")]
+			expanded = append(expanded, m.readRules(root+dir+"/"+rule.Pattern, dir, false, rule)...)
+		}
+
+		return expanded
+	}
+}
+
+// readRules reads the rules of a merge file. Lines are "+ pattern" or "- pattern",
+// or just patterns to exclude when excludesOnly is set. Unreadable files have no rules.
+func (m FilterMatcher) readRules(path, base string, excludesOnly bool, source FilterRule) []matchRule {
+	if m.Fs == nil {
+		return nil
+	}
+
+	file, err := m.Fs.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []matchRule
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		rule := matchRule{pattern: line, base: base, source: source}
+
+		if !excludesOnly {
+			action, pattern, found := strings.Cut(line, " ")
+			if !found || (action != "+" && action != "-" && action != "include" && action != "exclude") {
+				continue
+			}
+
+			rule.include, rule.pattern = action == "+" || action == "include", strings.TrimSpace(pattern)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// matchFilterPattern reports whether the rsync pattern matches the directory rel, a path
+// relative to the transfer root starting with "/". A leading "/" anchors the pattern to
+// the root; other patterns containing "/" or "**" match the end of the path, and the
+// remaining ones its last component. "dir/***" matches dir and everything inside it.
+func matchFilterPattern(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if prefix, found := strings.CutSuffix(pattern, "/***"); found {
+		return matchFilterPattern(prefix, rel) || matchFilterPattern(prefix+"/**", rel)
+	}
+
+	if anchored, found := strings.CutPrefix(pattern, "/"); found {
+		return globMatch(anchored, rel[1:])
+	}
+
+	if !strings.Contains(pattern, "/") && !strings.Contains(pattern, "**") {
+		return globMatch(pattern, rel[strings.LastIndex(rel, "/")+1:])
+	}
+
+	for i := range len(rel) {
+		if rel[i] == '/' && globMatch(pattern, rel[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// globMatch matches name against an rsync wildcard pattern: "*" matches within a path
+// component, "**" across components, "?" a single character other than "/" and
+// "[...]" a character class.
+func globMatch(pattern, name string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			doubleStar := strings.HasPrefix(pattern, "**")
+			rest := strings.TrimLeft(pattern, "*")
+
+			for i := 0; i <= len(name); i++ {
+				if globMatch(rest, name[i:]) {
+					return true
+				}
+
+				if !doubleStar && i < len(name) && name[i] == '/' {
+					return false
+				}
+			}
+
+			return false
+		case '?':
+			if name == "" || name[0] == '/' {
+				return false
+			}
+		case '[':
+			end := strings.IndexByte(pattern[1:], ']') + 1
+			if end == 0 || name == "" || !matchClass(pattern[1:end], name[0]) {
+				return false
+			}
+
+			pattern, name = pattern[end+1:], name[1:]
+
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+
+			fallthrough
+		default:
+			if name == "" || name[0] != pattern[0] {
+				return false
+			}
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return name == ""
+}
+
+// matchClass matches c against the contents of a character class such as "a-z" or "!0-9".
+func matchClass(class string, c byte) bool {
+	negated := class != "" && (class[0] == '!' || class[0] == '^')
+	if negated {
+		class = class[1:]
+	}
+
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return !negated
+			}
+
+			i += 2
+		} else if class[i] == c {
+			return !negated
+		}
+	}
+
+	return negated
+}
@@ -26,7 +26,13 @@ func NewUTCTextHandler(w io.Writer) slog.Handler {
 	})
 }
 
+// NormalizePath removes duplicate and trailing slashes from path. Remote paths are
+// reduced to "host:path", as the user they are accessed as does not change the location.
 func NormalizePath(path string) string {
+	if remote, remotePath, isRemote := SplitRemotePath(path); isRemote {
+		return remote.Host + ":" + NormalizePath(remotePath)
+	}
+
 	return strings.TrimSuffix(strings.ReplaceAll(path, "//", "/"), "/")
 }
 
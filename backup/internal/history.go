@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// HistoryEntry records the outcome of one job in a run.
+type HistoryEntry struct {
+	// Time is the start of the run the job was part of.
+	Time            time.Time `json:"time"`
+	Job             string    `json:"job"`
+	Status          JobStatus `json:"status"`
+	Reason          string    `json:"reason,omitempty"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	ExitCode        int       `json:"exit_code,omitempty"`
+}
+
+// succeeded reports whether the job completed, possibly with a warning.
+func (e HistoryEntry) succeeded() bool {
+	return e.Status == Success || e.Status == Warning
+}
+
+// failed reports whether the job ran and failed. Skipped and cancelled jobs neither
+// failed nor succeeded.
+func (e HistoryEntry) failed() bool {
+	return e.Status == Failure || e.Status == Timeout || e.Status == Refused
+}
+
+// HistoryPath returns the history file of the config at configPath, next to its log directories.
+func HistoryPath(configPath string) string {
+	return "logs/history-" + strings.TrimSuffix(filepath.Base(configPath), ".yaml") + ".jsonl"
+}
+
+// History is a file of HistoryEntry values, one JSON object per line, appended to by every run.
+type History struct {
+	Fs   afero.Fs
+	Path string
+}
+
+// Append records the jobs of report.
+func (h History) Append(report *RunReport) error {
+	err := h.Fs.MkdirAll(filepath.Dir(h.Path), LogDirPermission)
+	if err != nil {
+		return fmt.Errorf("creating history directory: %w", err)
+	}
+
+	file, err := h.Fs.OpenFile(h.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, LogFilePermission)
+	if err != nil {
+		return fmt.Errorf("opening history: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+
+	for job := range slices.Values(report.Jobs) {
+		err = encoder.Encode(HistoryEntry{
+			Time:            report.StartTime,
+			Job:             job.Name,
+			Status:          job.Status,
+			Reason:          job.Reason,
+			DurationSeconds: job.DurationSeconds,
+			ExitCode:        job.ExitCode,
+		})
+		if err != nil {
+			return fmt.Errorf("writing history: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Read returns all entries in the order they were recorded. A missing history has no entries.
+func (h History) Read() ([]HistoryEntry, error) {
+	file, err := h.Fs.Open(h.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var entry HistoryEntry
+
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("reading history %s, line %d: %w", h.Path, line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("reading history %s: %w", h.Path, err)
+	}
+
+	return entries, nil
+}
+
+// HealthState is the state of a job as reported by the status command. The exit code
+// of the command follows the conventions of Nagios plugins.
+type HealthState string
+
+const (
+	// HealthOK means the job last succeeded within its max_age.
+	HealthOK HealthState = "OK"
+	// HealthWarning means the job failed its latest runs, but last succeeded within its max_age.
+	HealthWarning HealthState = "WARNING"
+	// HealthCritical means the job has not succeeded within its max_age.
+	HealthCritical HealthState = "CRITICAL"
+	// HealthUnknown means the job has never run.
+	HealthUnknown HealthState = "UNKNOWN"
+)
+
+// healthStates orders the states from best to worst.
+var healthStates = []HealthState{HealthOK, HealthWarning, HealthUnknown, HealthCritical}
+
+// ExitCode returns the exit code of a Nagios plugin reporting the state.
+func (s HealthState) ExitCode() int {
+	switch s {
+	case HealthOK:
+		return 0
+	case HealthWarning:
+		return 1
+	case HealthCritical:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Worse returns the worse of s and other.
+func (s HealthState) Worse(other HealthState) HealthState {
+	if slices.Index(healthStates, other) > slices.Index(healthStates, s) {
+		return other
+	}
+
+	return s
+}
+
+// JobHealth summarizes the history of a job.
+type JobHealth struct {
+	Job     string
+	State   HealthState
+	LastRun *HistoryEntry
+	// LastSuccess is the time of the latest run the job succeeded in, or zero.
+	LastSuccess time.Time
+	// ConsecutiveFailures counts the failed runs since the last success.
+	ConsecutiveFailures int
+	// Age is the time since LastSuccess.
+	Age    time.Duration
+	MaxAge time.Duration
+}
+
+// Stale reports whether the job has not succeeded within its max_age.
+func (h JobHealth) Stale() bool {
+	return h.MaxAge > 0 && (h.LastSuccess.IsZero() || h.Age > h.MaxAge)
+}
+
+// JobHealths summarizes the history of each enabled job in jobs at time now.
+func JobHealths(jobs []Job, entries []HistoryEntry, now time.Time) []JobHealth {
+	healths := make([]JobHealth, 0, len(jobs))
+
+	for job := range slices.Values(jobs) {
+		if job.Enabled {
+			healths = append(healths, jobHealth(job, entries, now))
+		}
+	}
+
+	return healths
+}
+
+func jobHealth(job Job, entries []HistoryEntry, now time.Time) JobHealth {
+	health := JobHealth{Job: job.Name, MaxAge: job.MaxAge}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Job != job.Name {
+			continue
+		}
+
+		if health.LastRun == nil {
+			health.LastRun = &entry
+		}
+
+		if entry.succeeded() {
+			health.LastSuccess = entry.Time
+			health.Age = now.Sub(entry.Time)
+
+			break
+		}
+
+		if entry.failed() {
+			health.ConsecutiveFailures++
+		}
+	}
+
+	switch {
+	case health.Stale():
+		health.State = HealthCritical
+	case health.LastRun == nil:
+		health.State = HealthUnknown
+	case health.ConsecutiveFailures > 0:
+		health.State = HealthWarning
+	default:
+		health.State = HealthOK
+	}
+
+	return health
+}
+
+// FormatAge formats an age in its two largest units of days, hours and minutes,
+// e.g. "2d3h", "5h" or "45m".
+func FormatAge(age time.Duration) string {
+	const day = 24 * time.Hour
+
+	age = age.Truncate(time.Minute)
+
+	switch {
+	case age >= day && age%day >= time.Hour:
+		return fmt.Sprintf("%dd%dh", age/day, (age%day)/time.Hour)
+	case age >= day:
+		return fmt.Sprintf("%dd", age/day)
+	case age >= time.Hour && age%time.Hour != 0:
+		return fmt.Sprintf("%dh%dm", age/time.Hour, (age%time.Hour)/time.Minute)
+	case age >= time.Hour:
+		return fmt.Sprintf("%dh", age/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", age/time.Minute)
+	}
+}
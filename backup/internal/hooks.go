@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidHook = errors.New("invalid hook")
+	ErrHookFailed  = errors.New("hook failed")
+)
+
+// HookShell runs hook commands. Hooks are run through env so that they receive
+// the variables describing the job in addition to the environment of the tool.
+const HookShell = "sh"
+
+// HookStage is the point of a job run at which a hook runs.
+type HookStage string
+
+const (
+	// BeforeHook runs before rsync. A failing before hook skips the job.
+	BeforeHook HookStage = "before"
+	// AfterHook runs after the job, whatever its status.
+	AfterHook HookStage = "after"
+	// OnFailureHook runs after a job that neither succeeded nor completed with a warning,
+	// before the after hooks.
+	OnFailureHook HookStage = "on_failure"
+)
+
+// Hook is a shell command run around a job.
+type Hook struct {
+	Command string `yaml:"command"`
+	// Safe hooks run in simulate as well, e.g. because they only read or report.
+	Safe bool `yaml:"safe,omitempty"`
+}
+
+// UnmarshalYAML accepts a command string or a map with command and safe.
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*h = Hook{Command: node.Value}
+	} else {
+		type plain Hook
+
+		err := node.Decode((*plain)(h))
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %w", ErrInvalidHook, node.Line, err)
+		}
+	}
+
+	if h.Command == "" {
+		return fmt.Errorf("%w: line %d: command is required", ErrInvalidHook, node.Line)
+	}
+
+	return nil
+}
+
+// MarshalYAML writes unsafe hooks as a plain command.
+func (h Hook) MarshalYAML() (any, error) {
+	if !h.Safe {
+		return h.Command, nil
+	}
+
+	type plain Hook
+
+	return plain(h), nil
+}
+
+// Hooks are the commands run around the jobs of a config, a mapping or a single job.
+type Hooks struct {
+	Before    []Hook `yaml:"before,omitempty"`
+	After     []Hook `yaml:"after,omitempty"`
+	OnFailure []Hook `yaml:"on_failure,omitempty"`
+}
+
+// inherit returns the hooks combined with those of parent. The before hooks of parent
+// run first, and its after and on_failure hooks last, so that the enclosing levels
+// wrap the inner ones.
+func (h *Hooks) inherit(parent *Hooks) *Hooks {
+	if h == nil {
+		return parent
+	}
+
+	if parent == nil {
+		return h
+	}
+
+	return &Hooks{
+		Before:    slices.Concat(parent.Before, h.Before),
+		After:     slices.Concat(h.After, parent.After),
+		OnFailure: slices.Concat(h.OnFailure, parent.OnFailure),
+	}
+}
+
+// Stage returns the hooks that run at stage.
+func (h *Hooks) Stage(stage HookStage) []Hook {
+	if h == nil {
+		return nil
+	}
+
+	switch stage {
+	case BeforeHook:
+		return h.Before
+	case AfterHook:
+		return h.After
+	default:
+		return h.OnFailure
+	}
+}
+
+// resolveHooks resolves variables and macros in the commands of hooks.
+func resolveHooks(hooks *Hooks, variables map[string]string) error {
+	if hooks == nil {
+		return nil
+	}
+
+	for stage := range slices.Values([]HookStage{BeforeHook, AfterHook, OnFailureHook}) {
+		for i, hook := range hooks.Stage(stage) {
+			command, err := resolveField(hook.Command, variables)
+			if err != nil {
+				return fmt.Errorf("resolving %s hook %q: %w", stage, hook.Command, err)
+			}
+
+			hooks.Stage(stage)[i].Command = command
+		}
+	}
+
+	return nil
+}
+
+// hookEnvironment returns the variables describing job to a hook run at stage.
+func (c SharedCommand) hookEnvironment(job Job, stage HookStage, status JobStatus, simulate bool) []string {
+	env := []string{
+		"BACKUP_HOOK=" + string(stage),
+		"BACKUP_JOB_NAME=" + job.Name,
+		"BACKUP_SOURCE=" + job.SourceSpec(),
+		"BACKUP_TARGET=" + job.TargetSpec(),
+		"BACKUP_LOG=" + c.JobLogPath(job),
+		fmt.Sprintf("BACKUP_SIMULATE=%t", simulate),
+	}
+
+	if status != "" {
+		env = append(env, "BACKUP_STATUS="+string(status))
+	}
+
+	return env
+}
+
+// runHooks runs the hooks of job at stage one after another and stops at the first
+// that fails. In simulate, only safe hooks run.
+func (c SharedCommand) runHooks(
+	ctx context.Context, job Job, stage HookStage, status JobStatus, simulate bool,
+) error {
+	for hook := range slices.Values(job.Hooks.Stage(stage)) {
+		if simulate && !hook.Safe {
+			fmt.Fprintf(c.Output, "Hook [%s] not run in simulate: %s\n", stage, hook.Command)
+
+			continue
+		}
+
+		fmt.Fprintf(c.Output, "Hook [%s]: %s\n", stage, hook.Command)
+
+		args := append(c.hookEnvironment(job, stage, status, simulate), HookShell, "-c", hook.Command)
+
+		err := c.Shell.Stream(ctx, c.streamOutput(true, nil), "env", args...)
+		if err != nil {
+			return fmt.Errorf("%w: %s hook %q: %w", ErrHookFailed, stage, hook.Command, err)
+		}
+	}
+
+	return nil
+}
+
+// withHooks runs the before hooks of job, then its preflight checks and run, then its
+// on_failure and after hooks. A failing before hook or preflight check skips run; the
+// on_failure and after hooks still run so that they can undo what the before hooks did.
+// They run even if ctx is cancelled, and their failures are reported without changing
+// the result.
+func (c SharedCommand) withHooks(
+	ctx context.Context, job Job, simulate bool, run func(context.Context) JobResult,
+) JobResult {
+	if job.preflight != nil {
+		sync := run
+		run = func(ctx context.Context) JobResult {
+			if result, ok := job.preflight(); !ok {
+				return result
+			}
+
+			return sync(ctx)
+		}
+	}
+
+	if job.Hooks == nil {
+		return run(ctx)
+	}
+
+	var result JobResult
+
+	err := c.runHooks(ctx, job, BeforeHook, "", simulate)
+	switch {
+	case err == nil:
+		result = run(ctx)
+	case ctx.Err() != nil:
+		result = JobResult{Status: statusForError(ctx), Reason: err.Error()}
+	default:
+		result = JobResult{Status: Skipped, Reason: err.Error()}
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	stages := []HookStage{AfterHook}
+	if result.Status != Success && result.Status != Warning {
+		stages = []HookStage{OnFailureHook, AfterHook}
+	}
+
+	for stage := range slices.Values(stages) {
+		err = c.runHooks(ctx, job, stage, result.Status, simulate)
+		if err != nil {
+			fmt.Fprintf(c.Output, "Warning: %v\n", err)
+		}
+	}
+
+	return result
+}
+
+// PrintHooks lists the hooks of job.
+func (c SharedCommand) PrintHooks(job Job) {
+	for stage := range slices.Values([]HookStage{BeforeHook, AfterHook, OnFailureHook}) {
+		for hook := range slices.Values(job.Hooks.Stage(stage)) {
+			safe := ""
+			if hook.Safe {
+				safe = " (safe)"
+			}
+
+			fmt.Fprintf(c.Output, "Hook [%s]: %s%s\n", stage, hook.Command, safe)
+		}
+	}
+}
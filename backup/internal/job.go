@@ -1,7 +1,10 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,30 +13,120 @@ import (
 //
 //nolint:recvcheck // UnmarshalYAML requires pointer receiver while Apply uses value receiver
 type Job struct {
-	Name       string   `yaml:"name"`
-	Source     string   `yaml:"source"`
-	Target     string   `yaml:"target"`
-	Delete     bool     `yaml:"delete"`
-	Enabled    bool     `yaml:"enabled"`
-	Exclusions []string `yaml:"exclusions,omitempty"`
+	Name        string        `yaml:"name"`
+	Source      string        `yaml:"source"`
+	Target      string        `yaml:"target"`
+	Delete      bool          `yaml:"delete"`
+	Enabled     bool          `yaml:"enabled"`
+	Exclusions  []string      `yaml:"exclusions,omitempty"`
+	Filters     []FilterRule  `yaml:"filters,omitempty"`
+	ExcludeFrom []string      `yaml:"exclude_from,omitempty"`
+	Tags        []string      `yaml:"tags,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	MaxDelete   *DeleteLimit  `yaml:"max_delete,omitempty"`
+	MaxAge      time.Duration `yaml:"max_age,omitempty"`
+	Preflight   *Preflight    `yaml:"preflight,omitempty"`
+	Mode        Mode          `yaml:"mode,omitempty"`
+	Retention   *Retention    `yaml:"retention,omitempty"`
+	// Manifest records the files of the target with their hashes after each sync.
+	Manifest    bool          `yaml:"manifest,omitempty"`
+	Rsync       *RsyncOptions `yaml:"rsync,omitempty"`
+	Hooks       *Hooks        `yaml:"hooks,omitempty"`
+	RetryPolicy `yaml:",inline"`
+	// SourceRemote and TargetRemote override the remotes of the mapping.
+	SourceRemote *Remote `yaml:"source_remote,omitempty"`
+	TargetRemote *Remote `yaml:"target_remote,omitempty"`
+	// MappingFilters are the filter rules of the mapping, adapted to the job's source.
+	MappingFilters []FilterRule `yaml:"-"`
+	// Position is where the job is defined, for reporting problems with it.
+	Position Position `yaml:"-"`
+	// preflight checks the preflight conditions of the job after its before hooks, which
+	// may prepare them, e.g. by mounting the target. Nil checks nothing.
+	preflight func() (JobResult, bool)
 }
 
 // JobYAML is a helper struct for proper YAML unmarshaling with defaults.
 type JobYAML struct {
-	Name       string   `yaml:"name"`
-	Source     string   `yaml:"source"`
-	Target     string   `yaml:"target"`
-	Delete     *bool    `yaml:"delete"`
-	Enabled    *bool    `yaml:"enabled"`
-	Exclusions []string `yaml:"exclusions,omitempty"`
+	Name         string        `yaml:"name"`
+	Source       string        `yaml:"source"`
+	Target       string        `yaml:"target"`
+	Delete       *bool         `yaml:"delete"`
+	Enabled      *bool         `yaml:"enabled"`
+	Exclusions   []string      `yaml:"exclusions,omitempty"`
+	Filters      []FilterRule  `yaml:"filters,omitempty"`
+	ExcludeFrom  []string      `yaml:"exclude_from,omitempty"`
+	Tags         []string      `yaml:"tags,omitempty"`
+	Timeout      time.Duration `yaml:"timeout,omitempty"`
+	MaxDelete    *DeleteLimit  `yaml:"max_delete,omitempty"`
+	MaxAge       time.Duration `yaml:"max_age,omitempty"`
+	Preflight    *Preflight    `yaml:"preflight,omitempty"`
+	Mode         Mode          `yaml:"mode,omitempty"`
+	Retention    *Retention    `yaml:"retention,omitempty"`
+	Manifest     bool          `yaml:"manifest,omitempty"`
+	Rsync        *RsyncOptions `yaml:"rsync,omitempty"`
+	Hooks        *Hooks        `yaml:"hooks,omitempty"`
+	SourceRemote *Remote       `yaml:"source_remote,omitempty"`
+	TargetRemote *Remote       `yaml:"target_remote,omitempty"`
+	RetryPolicy  `yaml:",inline"`
 }
 
-func (job Job) Apply(rsync JobCommand) JobStatus {
+// SourceSpec returns the source as passed to rsync, including the remote host if any.
+func (job Job) SourceSpec() string {
+	return job.SourceRemote.Spec(job.Source)
+}
+
+// TargetSpec returns the target as passed to rsync, including the remote host if any.
+func (job Job) TargetSpec() string {
+	return job.TargetRemote.Spec(job.Target)
+}
+
+// FilterRules returns the rsync filter rules of the job in the order rsync evaluates
+// them: its filters, exclusions and exclude_from files, followed by those of its mapping.
+func (job Job) FilterRules() []FilterRule {
+	return append(filterRules(job.Filters, job.Exclusions, job.ExcludeFrom), job.MappingFilters...)
+}
+
+// Remote returns the remote side of the job, or nil if both sides are local.
+func (job Job) Remote() *Remote {
+	if job.SourceRemote != nil {
+		return job.SourceRemote
+	}
+
+	return job.TargetRemote
+}
+
+// Apply runs the job unless it is disabled or the run has already been cancelled.
+// A configured Timeout bounds the time rsync may take.
+func (job Job) Apply(ctx context.Context, rsync JobCommand) JobResult {
 	if !job.Enabled {
-		return Skipped
+		return JobResult{Status: Skipped}
+	}
+
+	if ctx.Err() != nil {
+		return JobResult{Status: Cancelled}
 	}
 
-	return rsync.Run(job)
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	return rsync.Run(ctx, job)
+}
+
+// statusForError maps a failed command to a job status, distinguishing
+// timeouts and cancellations from ordinary failures.
+func statusForError(ctx context.Context) JobStatus {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		return Cancelled
+	default:
+		return Failure
+	}
 }
 
 func boolDefault(ptr *bool, defaultVal bool) bool {
@@ -57,6 +150,21 @@ func (job *Job) UnmarshalYAML(node *yaml.Node) error {
 	job.Source = jobYAML.Source
 	job.Target = jobYAML.Target
 	job.Exclusions = jobYAML.Exclusions
+	job.Filters = jobYAML.Filters
+	job.ExcludeFrom = jobYAML.ExcludeFrom
+	job.Tags = jobYAML.Tags
+	job.Timeout = jobYAML.Timeout
+	job.MaxDelete = jobYAML.MaxDelete
+	job.MaxAge = jobYAML.MaxAge
+	job.Preflight = jobYAML.Preflight
+	job.Mode = jobYAML.Mode
+	job.Retention = jobYAML.Retention
+	job.Manifest = jobYAML.Manifest
+	job.Rsync = jobYAML.Rsync
+	job.Hooks = jobYAML.Hooks
+	job.RetryPolicy = jobYAML.RetryPolicy
+	job.SourceRemote = jobYAML.SourceRemote
+	job.TargetRemote = jobYAML.TargetRemote
 	job.Delete = boolDefault(jobYAML.Delete, true)
 	job.Enabled = boolDefault(jobYAML.Enabled, true)
 
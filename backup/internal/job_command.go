@@ -1,6 +1,10 @@
 package internal
 
-import "log/slog"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
 
 // JobStatus represents the outcome of a job execution.
 type JobStatus string
@@ -12,12 +16,100 @@ const (
 	Failure JobStatus = "FAILURE"
 	// Skipped indicates the job was skipped (e.g., disabled).
 	Skipped JobStatus = "SKIPPED"
+	// Timeout indicates the job exceeded its configured timeout.
+	Timeout JobStatus = "TIMEOUT"
+	// Cancelled indicates the job was interrupted or never started because the run was cancelled.
+	Cancelled JobStatus = "CANCELLED"
+	// Warning indicates the job completed, but rsync reported a problem that is not
+	// a failure, such as files vanishing during the transfer.
+	Warning JobStatus = "WARNING"
+	// Refused indicates the job was not run because it would delete more than its max_delete allows,
+	// or, for a restore, because it would overwrite the live source without confirmation.
+	Refused JobStatus = "REFUSED"
+	// Mismatch indicates that verification found the target to differ from the source.
+	Mismatch JobStatus = "MISMATCH"
 )
 
+// JobStatuses are all job statuses.
+var JobStatuses = []JobStatus{Success, Failure, Skipped, Timeout, Cancelled, Warning, Refused, Mismatch}
+
+// JobResult is the outcome of a job execution.
+type JobResult struct {
+	Status JobStatus
+	// Reason explains a status other than success, e.g. why a job was refused.
+	Reason string
+	// ExitCode is the exit code of a failed rsync run, or zero.
+	ExitCode int
+	// Attempts is the number of times rsync ran, when the job was retried.
+	Attempts int
+	// Stats holds the parsed rsync --stats output, or nil when rsync printed none.
+	Stats *TransferStats
+	// Changes holds the itemized changes of a simulated or verified job.
+	Changes []Change
+	// Verification holds the differences found by verifying a job.
+	Verification *VerifyResult
+}
+
+// RunSummary aggregates the results of all jobs of a run.
+type RunSummary struct {
+	Counts map[JobStatus]int
+	// Totals sums the transfer statistics of all jobs that reported any.
+	Totals TransferStats
+}
+
+// Lines returns the summary as printed at the end of a run: the number of jobs per
+// status and, if any job reported transfer statistics, their totals.
+func (s RunSummary) Lines() []string {
+	counts := s.Counts
+	lines := []string{fmt.Sprintf("Summary: %d succeeded, %d failed, %d skipped",
+		counts[Success], counts[Failure], counts[Skipped])}
+
+	if counts[Timeout] > 0 || counts[Cancelled] > 0 {
+		lines[0] += fmt.Sprintf(", %d timed out, %d cancelled", counts[Timeout], counts[Cancelled])
+	}
+
+	if counts[Warning] > 0 {
+		lines[0] += fmt.Sprintf(", %d with warnings", counts[Warning])
+	}
+
+	if counts[Refused] > 0 {
+		lines[0] += fmt.Sprintf(", %d refused", counts[Refused])
+	}
+
+	if counts[Mismatch] > 0 {
+		lines[0] += fmt.Sprintf(", %d with differences", counts[Mismatch])
+	}
+
+	if s.Totals != (TransferStats{}) {
+		totals := s.Totals
+		lines = append(lines, fmt.Sprintf(
+			"Totals: %d files transferred, %s of %s transferred, %d created, %d deleted",
+			totals.RegularFilesTransferred, FormatBytes(totals.TotalTransferredSize),
+			FormatBytes(totals.TotalFileSize), totals.CreatedFiles, totals.DeletedFiles))
+	}
+
+	return lines
+}
+
+// NewRunSummary creates an empty RunSummary.
+func NewRunSummary() RunSummary {
+	return RunSummary{Counts: make(map[JobStatus]int)}
+}
+
+// Add records the result of one job.
+func (s *RunSummary) Add(result JobResult) {
+	s.Counts[result.Status]++
+
+	if result.Stats != nil {
+		s.Totals.Add(*result.Stats)
+	}
+}
+
 // JobCommand defines the interface for running backup jobs.
 type JobCommand interface {
-	Run(job Job) JobStatus
+	Run(ctx context.Context, job Job) JobResult
 	GetVersionInfo() (string, string, error)
-	ReportJobStatus(jobName string, status JobStatus, logger *slog.Logger)
-	ReportSummary(counts map[JobStatus]int, logger *slog.Logger)
+	JobLogPath(job Job) string
+	ReportJobStatus(jobName string, result JobResult, logger *slog.Logger)
+	ReportSummary(summary RunSummary, logger *slog.Logger)
 }
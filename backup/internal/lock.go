@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLocked          = errors.New("locked by another run")
+	ErrLockUnsupported = errors.New("locking is not supported on this platform")
+)
+
+// LockPollInterval is how often a run waiting for a lock tries to take it.
+const LockPollInterval = time.Second
+
+// LockInfo identifies the run holding a lock. It is recorded in the lock file.
+type LockInfo struct {
+	PID        int       `json:"pid"`
+	Command    string    `json:"command"`
+	ConfigPath string    `json:"config_path"`
+	StartTime  time.Time `json:"start_time"`
+}
+
+// NewLockInfo describes the current process running command with the config at configPath.
+func NewLockInfo(command, configPath string) LockInfo {
+	return LockInfo{PID: os.Getpid(), Command: command, ConfigPath: configPath, StartTime: time.Now()}
+}
+
+func (i LockInfo) String() string {
+	return fmt.Sprintf("PID %d (backup %s of %s, started %s)",
+		i.PID, i.Command, i.ConfigPath, i.StartTime.Format(time.DateTime))
+}
+
+// Lock is an exclusive lock on a file, held with flock until it is released or the
+// process exits. Released locks remove their file, so a lock file whose lock is free
+// was left behind by a run that crashed.
+type Lock struct {
+	Path string
+	// Stale describes the run that left the lock file behind, if any.
+	Stale *LockInfo
+	file  *os.File
+}
+
+// AcquireLock takes the lock at path for the run described by info. If another run
+// holds it, AcquireLock returns ErrLocked, or with wait, tries again every
+// LockPollInterval until the lock is free or ctx is done.
+func AcquireLock(ctx context.Context, path string, info LockInfo, wait bool, out io.Writer) (*Lock, error) {
+	waiting := false
+
+	for {
+		lock, holder, err := tryAcquireLock(path, info)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+
+		if !wait {
+			return nil, fmt.Errorf("%w: %s is held by %s", ErrLocked, path, holder)
+		}
+
+		if !waiting {
+			fmt.Fprintf(out, "Waiting for lock %s held by %s\n", path, holder)
+
+			waiting = true
+		}
+
+		err = waitContext(ctx, LockPollInterval)
+		if err != nil {
+			return nil, fmt.Errorf("waiting for lock %s: %w", path, err)
+		}
+	}
+}
+
+// tryAcquireLock takes the lock at path, or returns the holder of the lock if it is taken.
+func tryAcquireLock(path string, info LockInfo) (*Lock, LockInfo, error) {
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, LogFilePermission)
+		if err != nil {
+			return nil, LockInfo{}, fmt.Errorf("opening lock %s: %w", path, err)
+		}
+
+		locked, err := tryLock(file)
+		if err != nil || !locked {
+			holder := readLockInfo(file)
+			file.Close()
+
+			if err != nil {
+				return nil, LockInfo{}, fmt.Errorf("locking %s: %w", path, err)
+			}
+
+			return nil, holder, nil
+		}
+
+		// The previous holder may have removed the file after we opened it; lock the
+		// file now at path instead.
+		if !sameFile(file, path) {
+			file.Close()
+
+			continue
+		}
+
+		lock := &Lock{Path: path, file: file}
+		if stale := readLockInfo(file); stale.PID != 0 {
+			lock.Stale = &stale
+		}
+
+		err = writeLockInfo(file, info)
+		if err != nil {
+			_ = lock.Release()
+
+			return nil, LockInfo{}, fmt.Errorf("writing lock %s: %w", path, err)
+		}
+
+		return lock, LockInfo{}, nil
+	}
+}
+
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(path)
+
+	return err == nil && os.SameFile(opened, current)
+}
+
+func readLockInfo(file *os.File) LockInfo {
+	var info LockInfo
+
+	content, err := io.ReadAll(io.NewSectionReader(file, 0, 1<<16)) //nolint:mnd // lock files are tiny
+	if err == nil {
+		_ = json.Unmarshal(content, &info)
+	}
+
+	return info
+}
+
+func writeLockInfo(file *os.File, info LockInfo) error {
+	content, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encoding lock info: %w", err)
+	}
+
+	err = file.Truncate(0)
+	if err != nil {
+		return fmt.Errorf("truncating lock file: %w", err)
+	}
+
+	_, err = file.WriteAt(append(content, '\n'), 0)
+	if err != nil {
+		return fmt.Errorf("writing lock file: %w", err)
+	}
+
+	return nil
+}
+
+// Release removes the lock file and releases the lock.
+func (l *Lock) Release() error {
+	err := os.Remove(l.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.file.Close()
+
+		return fmt.Errorf("removing lock %s: %w", l.Path, err)
+	}
+
+	err = l.file.Close()
+	if err != nil {
+		return fmt.Errorf("releasing lock %s: %w", l.Path, err)
+	}
+
+	return nil
+}
+
+// AcquireLocks takes the locks at paths in order, waiting for each if wait is set.
+// If a lock cannot be taken, those already taken are released.
+func AcquireLocks(
+	ctx context.Context, paths []string, info LockInfo, wait bool, out io.Writer,
+) ([]*Lock, error) {
+	locks := make([]*Lock, 0, len(paths))
+
+	for path := range slices.Values(paths) {
+		lock, err := AcquireLock(ctx, path, info, wait, out)
+		if err != nil {
+			return nil, errors.Join(err, ReleaseLocks(locks))
+		}
+
+		locks = append(locks, lock)
+	}
+
+	return locks, nil
+}
+
+// ReleaseLocks releases locks in the reverse order of their acquisition.
+func ReleaseLocks(locks []*Lock) error {
+	var errs []error
+
+	for _, lock := range slices.Backward(locks) {
+		errs = append(errs, lock.Release())
+	}
+
+	return errors.Join(errs...)
+}
+
+// LockPaths returns the lock files of a run of the jobs of cfg, loaded from configPath:
+// the lock of the config and, with lock_targets, a lock per target of its enabled jobs.
+// Target locks are sorted, so that concurrent runs take them in the same order.
+func (cfg Config) LockPaths(configPath string) []string {
+	dir := cmp.Or(cfg.LockDir, os.TempDir())
+
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		absConfig = configPath
+	}
+
+	paths := []string{filepath.Join(dir, "backup-config-"+lockName(absConfig)+".lock")}
+
+	if !cfg.LockTargets {
+		return paths
+	}
+
+	var targets []string
+
+	for job := range slices.Values(cfg.AllJobs()) {
+		if job.Enabled {
+			targets = append(targets, filepath.Join(dir, "backup-target-"+lockName(job.TargetSpec())+".lock"))
+		}
+	}
+
+	slices.Sort(targets)
+
+	return append(paths, slices.Compact(targets)...)
+}
+
+// lockName turns a path into a file name. Distinct paths may share a name, which only
+// makes their runs exclude each other needlessly.
+func lockName(path string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+
+		return '_'
+	}, NormalizePath(path))
+
+	return strings.Trim(name, "_")
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package internal
+
+import "os"
+
+// tryLock is not supported on this platform.
+func tryLock(_ *os.File) (bool, error) {
+	return false, ErrLockUnsupported
+}
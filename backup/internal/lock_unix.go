@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive flock on file without blocking, and reports whether it did.
+func tryLock(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("flock: %w", err)
+	}
+
+	return true, nil
+}
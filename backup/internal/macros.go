@@ -215,13 +215,13 @@ func ValidateNoUnresolvedMacros(cfg Config) error {
 		for _, field := range []struct {
 			name, value string
 		}{
-			{"mapping source", mapping.Source},
-			{"mapping target", mapping.Target},
-			{"mapping name", mapping.Name},
+			{"source", mapping.Source},
+			{"target", mapping.Target},
+			{"name", mapping.Name},
 		} {
 			if strings.Contains(field.value, macroPrefix) {
-				errs = append(errs, fmt.Errorf(
-					"%w in mapping %q field %q: %s", ErrUnresolvedMacro, mapping.Name, field.name, field.value))
+				errs = append(errs, mappingDiagnostic(mapping, field.name,
+					fmt.Errorf("%w: %s", ErrUnresolvedMacro, field.value)))
 			}
 		}
 
@@ -234,8 +234,8 @@ func ValidateNoUnresolvedMacros(cfg Config) error {
 				{"name", job.Name},
 			} {
 				if strings.Contains(field.value, macroPrefix) {
-					errs = append(errs, fmt.Errorf(
-						"%w in job %q field %q: %s", ErrUnresolvedMacro, job.Name, field.name, field.value))
+					errs = append(errs, jobDiagnostic(job, field.name,
+						fmt.Errorf("%w: %s", ErrUnresolvedMacro, field.value)))
 				}
 			}
 		}
@@ -0,0 +1,327 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	ErrNoManifest     = errors.New("no manifest")
+	ErrInvalidWorkers = errors.New("invalid workers")
+)
+
+// ManifestFile is the name of the manifest in the target of a job, or in each snapshot
+// of a job in snapshot mode. rsync is told to leave it alone.
+const ManifestFile = ".backup-manifest.json"
+
+// ManifestEntry describes a regular file of a target.
+type ManifestEntry struct {
+	// Path is relative to the target, with forward slashes.
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Mode    string    `json:"mode"`
+	SHA256  string    `json:"sha256"`
+}
+
+// unchanged reports whether the file described by other has the size and modification
+// time of e, so that its hash can be taken from e.
+func (e ManifestEntry) unchanged(other ManifestEntry) bool {
+	return e.Size == other.Size && e.ModTime.Equal(other.ModTime)
+}
+
+// Manifest records the regular files of a target with their SHA-256 hashes, to check
+// the target for bit rot later without its source.
+type Manifest struct {
+	Job       string          `json:"job"`
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// ManifestStore reads, builds and checks the manifests of job targets.
+type ManifestStore struct {
+	Fs afero.Fs
+	// Workers is the number of files hashed concurrently. Zero or less uses one per CPU.
+	Workers int
+}
+
+// Read reads the manifest of the target at root. A missing manifest is ErrNoManifest.
+func (s ManifestStore) Read(root string) (Manifest, error) {
+	path := filepath.Join(root, ManifestFile)
+
+	content, err := afero.ReadFile(s.Fs, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, fmt.Errorf("%w in %s", ErrNoManifest, root)
+	}
+
+	if err != nil {
+		return Manifest{}, fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+
+	var manifest Manifest
+
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("decoding manifest %s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// Build creates the manifest of the target at root for job. Files whose size and
+// modification time match previous keep their hash; only the others are hashed.
+// It returns the manifest and the number of files hashed.
+func (s ManifestStore) Build(ctx context.Context, job, root string, previous Manifest) (Manifest, int, error) {
+	entries, err := s.scan(root)
+	if err != nil {
+		return Manifest{}, 0, err
+	}
+
+	known := make(map[string]ManifestEntry, len(previous.Files))
+	for entry := range slices.Values(previous.Files) {
+		known[entry.Path] = entry
+	}
+
+	var stale []int
+
+	for i, entry := range entries {
+		if old, ok := known[entry.Path]; ok && old.unchanged(entry) {
+			entries[i].SHA256 = old.SHA256
+		} else {
+			stale = append(stale, i)
+		}
+	}
+
+	err = s.hash(ctx, root, entries, stale)
+	if err != nil {
+		return Manifest{}, 0, err
+	}
+
+	return Manifest{Job: job, CreatedAt: time.Now(), Files: entries}, len(stale), nil
+}
+
+// Write writes manifest to the target at root, through a temporary file that is
+// renamed, so that an interrupted write leaves the previous manifest in place.
+func (s ManifestStore) Write(root string, manifest Manifest) error {
+	path := filepath.Join(root, ManifestFile)
+
+	file, err := afero.TempFile(s.Fs, root, ManifestFile+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating manifest: %w", err)
+	}
+
+	tmpPath := file.Name()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	err = errors.Join(encoder.Encode(manifest), file.Close())
+	if err == nil {
+		err = s.Fs.Chmod(tmpPath, LogFilePermission)
+	}
+
+	if err == nil {
+		err = s.Fs.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		_ = s.Fs.Remove(tmpPath)
+
+		return fmt.Errorf("writing manifest %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ManifestCheck is the outcome of checking a target against its manifest. The paths
+// are relative to the target.
+type ManifestCheck struct {
+	// Files is the number of files hashed.
+	Files int
+	// Corrupted files kept their size and modification time, but not their content.
+	Corrupted []string
+	// Modified files changed in size or modification time as well as in content.
+	Modified []string
+	// Missing files are in the manifest, but not in the target.
+	Missing []string
+	// Extra files are in the target, but not in the manifest.
+	Extra []string
+}
+
+// Differences returns the number of files that differ from the manifest.
+func (c ManifestCheck) Differences() int {
+	return len(c.Corrupted) + len(c.Modified) + len(c.Missing) + len(c.Extra)
+}
+
+// OK reports whether the target matches its manifest.
+func (c ManifestCheck) OK() bool {
+	return c.Differences() == 0
+}
+
+// Check hashes every file of the target at root and compares it with manifest.
+func (s ManifestStore) Check(ctx context.Context, root string, manifest Manifest) (ManifestCheck, error) {
+	entries, err := s.scan(root)
+	if err != nil {
+		return ManifestCheck{}, err
+	}
+
+	all := make([]int, len(entries))
+	for i := range entries {
+		all[i] = i
+	}
+
+	err = s.hash(ctx, root, entries, all)
+	if err != nil {
+		return ManifestCheck{}, err
+	}
+
+	current := make(map[string]ManifestEntry, len(entries))
+	for entry := range slices.Values(entries) {
+		current[entry.Path] = entry
+	}
+
+	check := ManifestCheck{Files: len(entries)}
+	recorded := make(map[string]bool, len(manifest.Files))
+
+	for old := range slices.Values(manifest.Files) {
+		recorded[old.Path] = true
+
+		entry, ok := current[old.Path]
+
+		switch {
+		case !ok:
+			check.Missing = append(check.Missing, old.Path)
+		case entry.SHA256 == old.SHA256:
+		case entry.unchanged(old):
+			check.Corrupted = append(check.Corrupted, old.Path)
+		default:
+			check.Modified = append(check.Modified, old.Path)
+		}
+	}
+
+	for entry := range slices.Values(entries) {
+		if !recorded[entry.Path] {
+			check.Extra = append(check.Extra, entry.Path)
+		}
+	}
+
+	return check, nil
+}
+
+// scan lists the regular files below root, sorted by path, without their hashes.
+// The manifest itself and its temporary files are left out.
+func (s ManifestStore) scan(root string) ([]ManifestEntry, error) {
+	entries := []ManifestEntry{}
+
+	err := afero.Walk(s.Fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("relative path of %s: %w", path, err)
+		}
+
+		if matched, _ := filepath.Match(ManifestFile+"*", rel); matched {
+			return nil
+		}
+
+		entries = append(entries, ManifestEntry{
+			Path:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    fmt.Sprintf("%04o", info.Mode().Perm()),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", root, err)
+	}
+
+	slices.SortFunc(entries, func(a, b ManifestEntry) int { return cmp.Compare(a.Path, b.Path) })
+
+	return entries, nil
+}
+
+// hash sets the hashes of the entries at indices, hashing Workers files at a time.
+func (s ManifestStore) hash(ctx context.Context, root string, entries []ManifestEntry, indices []int) error {
+	var (
+		workers sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+	)
+
+	next := make(chan int)
+
+	count := s.Workers
+	if count <= 0 {
+		count = runtime.NumCPU()
+	}
+
+	for range min(count, len(indices)) {
+		workers.Go(func() {
+			for i := range next {
+				sum, err := s.hashFile(filepath.Join(root, filepath.FromSlash(entries[i].Path)))
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+
+					continue
+				}
+
+				entries[i].SHA256 = sum
+			}
+		})
+	}
+
+send:
+	for i := range slices.Values(indices) {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break send
+		}
+	}
+
+	close(next)
+	workers.Wait()
+
+	return errors.Join(append(errs, ctx.Err())...)
+}
+
+func (s ManifestStore) hashFile(path string) (string, error) {
+	file, err := s.Fs.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Metrics are the Prometheus metrics of a run, written in the text exposition format
+// read by the textfile collector of node_exporter.
+type Metrics struct {
+	Report *RunReport
+	// Mappings are the names of the mappings of the jobs, by job name.
+	Mappings map[string]string
+	// LastSuccess is the start of the latest run each job succeeded in, by job name.
+	LastSuccess map[string]time.Time
+}
+
+// NewMetrics collects the metrics of the run recorded in report. The last successes
+// of the jobs of cfg are taken from the history entries.
+func NewMetrics(report *RunReport, cfg Config, entries []HistoryEntry) Metrics {
+	metrics := Metrics{
+		Report:      report,
+		Mappings:    make(map[string]string),
+		LastSuccess: make(map[string]time.Time),
+	}
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		for job := range slices.Values(mapping.Jobs) {
+			metrics.Mappings[job.Name] = mapping.Name
+		}
+	}
+
+	for entry := range slices.Values(entries) {
+		if entry.succeeded() && entry.Time.After(metrics.LastSuccess[entry.Job]) {
+			metrics.LastSuccess[entry.Job] = entry.Time
+		}
+	}
+
+	return metrics
+}
+
+// jobMetricPrefix is the prefix of the metrics with a sample per job.
+const jobMetricPrefix = "backup_job_"
+
+// metricFamily is a metric name with its help text and samples.
+type metricFamily struct {
+	name, help string
+	samples    []metricSample
+}
+
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+// families returns the metrics of the run, each with one sample per job or status.
+func (m Metrics) families() []metricFamily {
+	report := m.Report
+	// The mode keeps the series of simulations apart from those of runs.
+	config := metricLabels("config", report.ConfigPath, "mode", report.Command)
+
+	jobFamilies := []metricFamily{
+		{name: "backup_job_last_run_timestamp_seconds", help: "Start time of the last run of the job."},
+		{name: "backup_job_last_success_timestamp_seconds", help: "Start time of the last run the job succeeded in."},
+		{name: "backup_job_duration_seconds", help: "Duration of the last run of the job."},
+		{name: "backup_job_exit_code", help: "rsync exit code of the last run of the job."},
+		{name: "backup_job_transferred_bytes", help: "Bytes transferred by the last run of the job."},
+		{name: "backup_job_transferred_files", help: "Regular files transferred by the last run of the job."},
+	}
+
+	for job := range slices.Values(report.Jobs) {
+		// Jobs that did not run keep the series of their last run in the file.
+		if job.Status == Skipped || job.Status == Cancelled {
+			continue
+		}
+
+		labels := config + "," + metricLabels("mapping", m.Mappings[job.Name], "job", job.Name)
+
+		jobFamilies[0].add(labels, unixSeconds(report.StartTime))
+
+		if lastSuccess, ok := m.LastSuccess[job.Name]; ok {
+			jobFamilies[1].add(labels, unixSeconds(lastSuccess))
+		}
+
+		jobFamilies[2].add(labels, job.DurationSeconds)
+		jobFamilies[3].add(labels, float64(job.ExitCode))
+
+		if job.Stats != nil {
+			jobFamilies[4].add(labels, float64(job.Stats.TotalTransferredSize))
+			jobFamilies[5].add(labels, float64(job.Stats.RegularFilesTransferred))
+		}
+	}
+
+	runJobs := metricFamily{name: "backup_run_jobs", help: "Number of jobs of the last run, by status."}
+	for status := range slices.Values(JobStatuses) {
+		runJobs.add(config+","+metricLabels("status", string(status)), float64(report.Summary[status]))
+	}
+
+	return append(jobFamilies,
+		metricFamily{name: "backup_run_timestamp_seconds", help: "Start time of the last run.",
+			samples: []metricSample{{labels: config, value: unixSeconds(report.StartTime)}}},
+		metricFamily{name: "backup_run_duration_seconds", help: "Duration of the last run.",
+			samples: []metricSample{{labels: config, value: report.EndTime.Sub(report.StartTime).Seconds()}}},
+		runJobs)
+}
+
+func (f *metricFamily) add(labels string, value float64) {
+	f.samples = append(f.samples, metricSample{labels: labels, value: value})
+}
+
+// Write writes the metrics as gauges in the Prometheus text format.
+func (m Metrics) Write(w io.Writer) error {
+	return writeFamilies(w, m.families())
+}
+
+func writeFamilies(w io.Writer, families []metricFamily) error {
+	buffered := bufio.NewWriter(w)
+
+	for family := range slices.Values(families) {
+		if len(family.samples) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buffered, "# HELP %s %s\n# TYPE %s gauge\n", family.name, family.help, family.name)
+
+		for sample := range slices.Values(family.samples) {
+			fmt.Fprintf(buffered, "%s{%s} %s\n", family.name, sample.labels,
+				strconv.FormatFloat(sample.value, 'f', -1, 64))
+		}
+	}
+
+	err := buffered.Flush()
+	if err != nil {
+		return fmt.Errorf("writing metrics: %w", err)
+	}
+
+	return nil
+}
+
+// WriteFile writes the metrics to path atomically: to a temporary file in the same
+// directory, which is then renamed, so that the collector never reads a partial file.
+// The job series of an existing file at path are kept for the jobs that did not run
+// this time, e.g. because they were not selected, so that the file holds the last run
+// of every job.
+func (m Metrics) WriteFile(fs afero.Fs, path string) error {
+	families := m.families()
+
+	previous, err := afero.ReadFile(fs, path)
+	if err == nil {
+		families = mergeJobSeries(families, previous)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading metrics file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+
+	file, err := afero.TempFile(fs, dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating metrics file: %w", err)
+	}
+
+	tmpPath := file.Name()
+
+	err = errors.Join(writeFamilies(file, families), file.Close())
+	if err == nil {
+		err = fs.Chmod(tmpPath, LogFilePermission)
+	}
+
+	if err == nil {
+		err = fs.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		_ = fs.Remove(tmpPath)
+
+		return fmt.Errorf("writing metrics file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// mergeJobSeries adds the job samples of previous, the content of an earlier metrics
+// file, to families, except for the jobs that have samples in families. Lines that are
+// not job samples are ignored.
+func mergeJobSeries(families []metricFamily, previous []byte) []metricFamily {
+	replaced := make(map[string]bool)
+
+	for family := range slices.Values(families) {
+		if strings.HasPrefix(family.name, jobMetricPrefix) {
+			for sample := range slices.Values(family.samples) {
+				replaced[sample.labels] = true
+			}
+		}
+	}
+
+	for line := range strings.Lines(string(previous)) {
+		name, sample, ok := parseMetricSample(strings.TrimSpace(line))
+		if !ok || !strings.HasPrefix(name, jobMetricPrefix) || replaced[sample.labels] {
+			continue
+		}
+
+		index := slices.IndexFunc(families, func(family metricFamily) bool { return family.name == name })
+		if index >= 0 {
+			families[index].add(sample.labels, sample.value)
+		}
+	}
+
+	return families
+}
+
+// parseMetricSample parses a `name{labels} value` line of the Prometheus text format.
+func parseMetricSample(line string) (string, metricSample, bool) {
+	name, rest, ok := strings.Cut(line, "{")
+	if !ok || strings.HasPrefix(line, "#") {
+		return "", metricSample{}, false
+	}
+
+	index := strings.LastIndex(rest, "} ")
+	if index < 0 {
+		return "", metricSample{}, false
+	}
+
+	value, err := strconv.ParseFloat(rest[index+len("} "):], 64)
+	if err != nil {
+		return "", metricSample{}, false
+	}
+
+	return name, metricSample{labels: rest[:index], value: value}, true
+}
+
+// metricLabels formats name and value pairs as Prometheus labels, escaping the values.
+func metricLabels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := make([]string, 0, len(pairs)/2) //nolint:mnd // name and value per label
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+
+	return strings.Join(labels, ",")
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / float64(time.Second/time.Millisecond)
+}
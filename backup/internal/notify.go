@@ -0,0 +1,672 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	ErrInvalidNotification = errors.New("invalid notification")
+	ErrNotificationFailed  = errors.New("notification failed")
+	ErrWebhookResponse     = errors.New("unexpected webhook response")
+)
+
+const (
+	// NotifyTimeout bounds the time each notification may take to be sent.
+	NotifyTimeout = 30 * time.Second
+	// DefaultSMTPPort is the port of the mail server when an email notification sets none.
+	DefaultSMTPPort = 25
+)
+
+// NotifyTrigger is the outcome of a run a notification is sent for.
+type NotifyTrigger string
+
+const (
+	// NotifyOnFailure sends the notification when the run failed.
+	NotifyOnFailure NotifyTrigger = "failure"
+	// NotifyOnSuccess sends the notification when the run succeeded.
+	NotifyOnSuccess NotifyTrigger = "success"
+	// NotifyAlways sends the notification after every run.
+	NotifyAlways NotifyTrigger = "always"
+)
+
+// NotifyTriggers are the supported triggers.
+var NotifyTriggers = []NotifyTrigger{NotifyOnFailure, NotifyOnSuccess, NotifyAlways}
+
+// Notification is an entry of the notifications section: a notifier and the outcomes
+// of a run it is sent for. Exactly one of Email, Webhook and Command is set.
+type Notification struct {
+	// On are the outcomes the notification is sent for. It defaults to failure.
+	On      []NotifyTrigger  `yaml:"on,omitempty"`
+	Email   *EmailNotifier   `yaml:"email,omitempty"`
+	Webhook *WebhookNotifier `yaml:"webhook,omitempty"`
+	// Command is a shell command that receives the outcome of the run in its environment.
+	Command string `yaml:"command,omitempty"`
+}
+
+// Triggered reports whether the notification is sent for notification.
+func (n Notification) Triggered(notification RunNotification) bool {
+	on := n.On
+	if len(on) == 0 {
+		on = []NotifyTrigger{NotifyOnFailure}
+	}
+
+	return slices.Contains(on, NotifyAlways) || slices.Contains(on, NotifyTrigger(notification.Status))
+}
+
+// Validate checks that exactly one notifier is set and that it is complete.
+func (n Notification) Validate() error {
+	set := 0
+
+	for isSet := range slices.Values([]bool{n.Email != nil, n.Webhook != nil, n.Command != ""}) {
+		if isSet {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return fmt.Errorf("%w: exactly one of email, webhook or command is required", ErrInvalidNotification)
+	}
+
+	for trigger := range slices.Values(n.On) {
+		if !slices.Contains(NotifyTriggers, trigger) {
+			return fmt.Errorf("%w: unknown trigger %q (supported: failure, success, always)",
+				ErrInvalidNotification, trigger)
+		}
+	}
+
+	switch {
+	case n.Email != nil:
+		return n.Email.validate()
+	case n.Webhook != nil:
+		return n.Webhook.validate()
+	default:
+		return nil
+	}
+}
+
+// Notifier sends the notification of a run.
+type Notifier interface {
+	Notify(ctx context.Context, notification RunNotification) error
+	// String describes where the notification is sent.
+	String() string
+}
+
+// Notifier returns the notifier of n. Commands are run by shell.
+func (n Notification) Notifier(shell Exec) Notifier {
+	switch {
+	case n.Email != nil:
+		return n.Email
+	case n.Webhook != nil:
+		return n.Webhook
+	default:
+		return CommandNotifier{Command: n.Command, Shell: shell}
+	}
+}
+
+// RunNotification describes the outcome of a run to the notifiers.
+type RunNotification struct {
+	// Command is the command that ran the jobs, e.g. "run".
+	Command    string `json:"command"`
+	ConfigPath string `json:"config_path"`
+	Hostname   string `json:"hostname,omitempty"`
+	// Status is "success" or "failure".
+	Status string `json:"status"`
+	// Error is the error the run failed with.
+	Error string `json:"error,omitempty"`
+	// Summary are the summary lines printed at the end of the run.
+	Summary []string          `json:"summary"`
+	Counts  map[JobStatus]int `json:"counts"`
+	// FailedJobs are the names of the jobs that failed, timed out or were refused.
+	FailedJobs []string    `json:"failed_jobs"`
+	StartTime  time.Time   `json:"start_time"`
+	EndTime    time.Time   `json:"end_time"`
+	Jobs       []JobReport `json:"jobs"`
+}
+
+// NewRunNotification describes the run recorded in report, which failed with runErr if
+// it is not nil.
+func NewRunNotification(report *RunReport, runErr error) RunNotification {
+	notification := RunNotification{
+		Command:    report.Command,
+		ConfigPath: report.ConfigPath,
+		Status:     string(NotifyOnSuccess),
+		Summary:    RunSummary{Counts: report.Summary, Totals: report.Totals}.Lines(),
+		Counts:     report.Summary,
+		FailedJobs: []string{},
+		StartTime:  report.StartTime,
+		EndTime:    report.EndTime,
+		Jobs:       report.Jobs,
+	}
+
+	if runErr != nil {
+		notification.Status = string(NotifyOnFailure)
+		notification.Error = runErr.Error()
+	}
+
+	for job := range slices.Values(report.Jobs) {
+		if job.Status == Failure || job.Status == Timeout || job.Status == Refused {
+			notification.FailedJobs = append(notification.FailedJobs, job.Name)
+		}
+	}
+
+	return notification
+}
+
+// Subject is a one-line description of the outcome, e.g. for the subject of an email.
+func (n RunNotification) Subject() string {
+	subject := "backup " + n.Command
+	if n.Hostname != "" {
+		subject += " on " + n.Hostname
+	}
+
+	if n.Status == string(NotifyOnSuccess) {
+		return subject + " succeeded"
+	}
+
+	if len(n.FailedJobs) > 0 {
+		return subject + " failed: " + strings.Join(n.FailedJobs, ", ")
+	}
+
+	return subject + " failed"
+}
+
+// Text describes the outcome in plain text: the subject, the summary and the failed jobs.
+func (n RunNotification) Text() string {
+	var text strings.Builder
+
+	fmt.Fprintln(&text, n.Subject())
+
+	if n.Error != "" {
+		fmt.Fprintf(&text, "Error: %s\n", n.Error)
+	}
+
+	fmt.Fprintf(&text, "Config: %s\n", n.ConfigPath)
+	fmt.Fprintf(&text, "Started: %s, finished: %s\n\n",
+		n.StartTime.Format(time.DateTime), n.EndTime.Format(time.DateTime))
+
+	for line := range slices.Values(n.Summary) {
+		fmt.Fprintln(&text, line)
+	}
+
+	if len(n.FailedJobs) > 0 {
+		fmt.Fprintln(&text, "\nFailed jobs:")
+	}
+
+	for job := range slices.Values(n.Jobs) {
+		if slices.Contains(n.FailedJobs, job.Name) {
+			fmt.Fprintf(&text, "  %s: %s (%s)\n", job.Name, job.Status, job.Reason)
+		}
+	}
+
+	return text.String()
+}
+
+// environment returns the variables describing the outcome to a command notifier.
+func (n RunNotification) environment() []string {
+	env := []string{
+		"BACKUP_COMMAND=" + n.Command,
+		"BACKUP_CONFIG=" + n.ConfigPath,
+		"BACKUP_RUN_STATUS=" + n.Status,
+		"BACKUP_SUMMARY=" + strings.Join(n.Summary, "\n"),
+		"BACKUP_FAILED_JOBS=" + strings.Join(n.FailedJobs, " "),
+	}
+
+	if n.Error != "" {
+		env = append(env, "BACKUP_ERROR="+n.Error)
+	}
+
+	return env
+}
+
+// SendNotifications sends notification through each of notifications it triggers and
+// reports each one sent to out. A failing notifier does not keep the others from being
+// sent; their errors are returned together.
+func SendNotifications(
+	ctx context.Context, notifications []Notification, notification RunNotification, shell Exec, out io.Writer,
+) error {
+	var errs []error
+
+	for entry := range slices.Values(notifications) {
+		if !entry.Triggered(notification) {
+			continue
+		}
+
+		notifier := entry.Notifier(shell)
+
+		notifyCtx, cancel := context.WithTimeout(ctx, NotifyTimeout)
+		err := notifier.Notify(notifyCtx, notification)
+
+		cancel()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrNotificationFailed, notifier, err))
+
+			continue
+		}
+
+		fmt.Fprintf(out, "Sent notification: %s\n", notifier)
+	}
+
+	return errors.Join(errs...)
+}
+
+// EmailNotifier sends the notification as a plain text email. The connection is
+// upgraded with STARTTLS when the server supports it.
+type EmailNotifier struct {
+	Host string `yaml:"host"`
+	// Port defaults to DefaultSMTPPort.
+	Port int `yaml:"port,omitempty"`
+	// Username and Password authenticate with PLAIN auth, which requires TLS unless
+	// the server is on localhost.
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// redactedSecret replaces secrets in the printed configuration.
+const redactedSecret = "<redacted>"
+
+// MarshalYAML writes the notifier with its password redacted, so that printing the
+// configuration does not reveal it.
+func (e EmailNotifier) MarshalYAML() (any, error) {
+	type plain EmailNotifier
+
+	notifier := plain(e)
+	if notifier.Password != "" {
+		notifier.Password = redactedSecret
+	}
+
+	return notifier, nil
+}
+
+// redactVariables returns the variables with the values that are part of a secret of
+// the notifications redacted, since secrets are usually passed in variables.
+func redactVariables(variables map[string]string, notifications []Notification) map[string]string {
+	var secrets []string
+
+	for notification := range slices.Values(notifications) {
+		if notification.Email != nil && notification.Email.Password != "" {
+			secrets = append(secrets, notification.Email.Password)
+		}
+
+		if notification.Webhook != nil {
+			secrets = append(secrets, notification.Webhook.secrets()...)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return variables
+	}
+
+	redacted := maps.Clone(variables)
+
+	for name, value := range redacted {
+		if value != "" && slices.ContainsFunc(secrets, func(secret string) bool {
+			return strings.Contains(secret, value)
+		}) {
+			redacted[name] = redactedSecret
+		}
+	}
+
+	return redacted
+}
+
+func (e *EmailNotifier) validate() error {
+	switch {
+	case e.Host == "":
+		return fmt.Errorf("%w: email host is required", ErrInvalidNotification)
+	case e.Port < 0 || e.Port > 65535:
+		return fmt.Errorf("%w: email port %d is out of range", ErrInvalidNotification, e.Port)
+	case e.From == "":
+		return fmt.Errorf("%w: email from is required", ErrInvalidNotification)
+	case len(e.To) == 0:
+		return fmt.Errorf("%w: email to is required", ErrInvalidNotification)
+	default:
+		return nil
+	}
+}
+
+func (e *EmailNotifier) String() string {
+	return "email to " + strings.Join(e.To, ", ")
+}
+
+// Notify sends the email over SMTP.
+func (e *EmailNotifier) Notify(ctx context.Context, notification RunNotification) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(cmp.Or(e.Port, DefaultSMTPPort)))
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: e.Host, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+
+	if e.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host))
+		if err != nil {
+			return fmt.Errorf("authenticating as %s: %w", e.Username, err)
+		}
+	}
+
+	err = e.send(client, e.message(notification, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	err = client.Quit()
+	if err != nil {
+		return fmt.Errorf("closing SMTP session: %w", err)
+	}
+
+	return nil
+}
+
+func (e *EmailNotifier) send(client *smtp.Client, message []byte) error {
+	err := client.Mail(e.From)
+	if err != nil {
+		return fmt.Errorf("sender %s: %w", e.From, err)
+	}
+
+	for to := range slices.Values(e.To) {
+		err = client.Rcpt(to)
+		if err != nil {
+			return fmt.Errorf("recipient %s: %w", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	_, err = writer.Write(message)
+	if err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+
+	return nil
+}
+
+// message returns the email of notification. Lines end in "\n"; the SMTP client sends
+// them as "\r\n".
+func (e *EmailNotifier) message(notification RunNotification, now time.Time) []byte {
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\n", e.From)
+	fmt.Fprintf(&message, "To: %s\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\n", notification.Subject())
+	fmt.Fprintf(&message, "Date: %s\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\nContent-Type: text/plain; charset=utf-8\n\n")
+	message.WriteString(notification.Text())
+
+	return message.Bytes()
+}
+
+// WebhookNotifier sends the notification as an HTTP request.
+type WebhookNotifier struct {
+	URL string `yaml:"url"`
+	// Method defaults to POST.
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Body is a text/template of the request body, executed with the RunNotification.
+	// The json function encodes a value as JSON. The body defaults to the
+	// RunNotification as JSON.
+	Body string `yaml:"body,omitempty"`
+}
+
+// MarshalYAML writes the notifier with its header values and the credentials, path and
+// query of its URL redacted, since they often hold tokens.
+func (w WebhookNotifier) MarshalYAML() (any, error) {
+	type plain WebhookNotifier
+
+	notifier := plain(w)
+	if public, secret := splitURL(w.URL); secret != "" {
+		notifier.URL = public + "/" + redactedSecret
+	}
+
+	if len(w.Headers) > 0 {
+		notifier.Headers = make(map[string]string, len(w.Headers))
+		for name := range w.Headers {
+			notifier.Headers[name] = redactedSecret
+		}
+	}
+
+	return notifier, nil
+}
+
+// secrets returns the parts of the notifier that printing the configuration redacts.
+func (w WebhookNotifier) secrets() []string {
+	secrets := slices.Collect(maps.Values(w.Headers))
+
+	if _, secret := splitURL(w.URL); secret != "" {
+		secrets = append(secrets, secret)
+	}
+
+	return secrets
+}
+
+// splitURL splits raw into its scheme and host, and the rest of it with its credentials,
+// or "" when there is no more than a "/". A URL that does not parse is all secret.
+func splitURL(raw string) (string, string) {
+	target, err := url.Parse(raw)
+	if err != nil || target.Host == "" {
+		return "", raw
+	}
+
+	secret := raw[strings.Index(raw, target.Host)+len(target.Host):]
+	if secret == "/" {
+		secret = ""
+	}
+
+	if target.User != nil {
+		secret = target.User.String() + "@" + secret
+	}
+
+	return target.Scheme + "://" + target.Host, secret
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+
+		return string(encoded), err
+	},
+	"join": strings.Join,
+}
+
+func (w *WebhookNotifier) validate() error {
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: webhook url %q is not an http or https URL", ErrInvalidNotification, w.URL)
+	}
+
+	_, err = template.New("body").Funcs(webhookFuncs).Parse(w.Body)
+	if err != nil {
+		return fmt.Errorf("%w: webhook body: %w", ErrInvalidNotification, err)
+	}
+
+	return nil
+}
+
+// String names only the host of the URL, whose path often contains a secret token.
+func (w *WebhookNotifier) String() string {
+	target, err := url.Parse(w.URL)
+	if err != nil {
+		return "webhook"
+	}
+
+	return "webhook to " + target.Host
+}
+
+func (w *WebhookNotifier) body(notification RunNotification) ([]byte, error) {
+	if w.Body == "" {
+		encoded, err := json.Marshal(notification)
+		if err != nil {
+			return nil, fmt.Errorf("encoding webhook body: %w", err)
+		}
+
+		return encoded, nil
+	}
+
+	tmpl, err := template.New("body").Funcs(webhookFuncs).Parse(w.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook body: %w", err)
+	}
+
+	var body bytes.Buffer
+
+	err = tmpl.Execute(&body, notification)
+	if err != nil {
+		return nil, fmt.Errorf("executing webhook body: %w", err)
+	}
+
+	return body.Bytes(), nil
+}
+
+// Notify sends the request and expects a 2xx response.
+func (w *WebhookNotifier) Notify(ctx context.Context, notification RunNotification) error {
+	body, err := w.body(notification)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, cmp.Or(w.Method, http.MethodPost), w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	for name, value := range w.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("sending webhook request: %w", err)
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s", ErrWebhookResponse, response.Status)
+	}
+
+	return nil
+}
+
+// CommandNotifier runs a shell command with the outcome of the run in its environment.
+type CommandNotifier struct {
+	Command string
+	Shell   Exec
+}
+
+func (c CommandNotifier) String() string {
+	return fmt.Sprintf("command %q", c.Command)
+}
+
+// Notify runs the command through env, like a hook.
+func (c CommandNotifier) Notify(ctx context.Context, notification RunNotification) error {
+	args := append(notification.environment(), HookShell, "-c", c.Command)
+
+	_, err := c.Shell.Execute(ctx, "env", args...)
+	if err != nil {
+		return fmt.Errorf("running notification command: %w", err)
+	}
+
+	return nil
+}
+
+// resolveNotifications resolves variables and macros in the settings of notifications,
+// e.g. to keep a password out of the config file.
+func resolveNotifications(notifications []Notification, variables map[string]string) error {
+	for i := range notifications {
+		notification := &notifications[i]
+
+		fields := []*string{&notification.Command}
+
+		if email := notification.Email; email != nil {
+			fields = append(fields, &email.Host, &email.Username, &email.Password, &email.From)
+			for j := range email.To {
+				fields = append(fields, &email.To[j])
+			}
+		}
+
+		if webhook := notification.Webhook; webhook != nil {
+			fields = append(fields, &webhook.URL, &webhook.Body)
+
+			for name, value := range webhook.Headers {
+				resolved, err := resolveField(value, variables)
+				if err != nil {
+					return fmt.Errorf("resolving notification %d: header %s: %w", i+1, name, err)
+				}
+
+				webhook.Headers[name] = resolved
+			}
+		}
+
+		for field := range slices.Values(fields) {
+			resolved, err := resolveField(*field, variables)
+			if err != nil {
+				return fmt.Errorf("resolving notification %d: %w", i+1, err)
+			}
+
+			*field = resolved
+		}
+	}
+
+	return nil
+}
+
+func validateNotifications(notifications []Notification) error {
+	var errs []error
+
+	for i, notification := range notifications {
+		err := notification.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification %d: %w", i+1, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
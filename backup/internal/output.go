@@ -0,0 +1,26 @@
+package internal
+
+import (
+	"io"
+	"sync"
+)
+
+// SyncWriter serializes writes to an underlying writer so that concurrently
+// running jobs never interleave their output within a single Write call.
+// Callers are expected to write whole lines per call.
+type SyncWriter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewSyncWriter wraps w so it can be shared between goroutines.
+func NewSyncWriter(w io.Writer) *SyncWriter {
+	return &SyncWriter{writer: w}
+}
+
+func (s *SyncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writer.Write(p)
+}
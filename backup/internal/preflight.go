@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidPreflightPolicy = errors.New("invalid preflight on_failure")
+	ErrInvalidByteSize        = errors.New("invalid size")
+	ErrVolumeInfoUnsupported  = errors.New("volume information is not supported on this platform")
+)
+
+// PreflightPolicy decides how a job that fails a preflight check is reported.
+type PreflightPolicy string
+
+const (
+	// PreflightFail reports the job as FAILURE. This is the default.
+	PreflightFail PreflightPolicy = "fail"
+	// PreflightSkip reports the job as SKIPPED, e.g. for a disk that is not always attached.
+	PreflightSkip PreflightPolicy = "skip"
+)
+
+// UnmarshalYAML accepts "fail" or "skip".
+func (p *PreflightPolicy) UnmarshalYAML(node *yaml.Node) error {
+	switch policy := PreflightPolicy(node.Value); policy {
+	case PreflightFail, PreflightSkip:
+		*p = policy
+
+		return nil
+	default:
+		return fmt.Errorf("%w: line %d: %q (expected %q or %q)",
+			ErrInvalidPreflightPolicy, node.Line, node.Value, PreflightFail, PreflightSkip)
+	}
+}
+
+// Preflight lists conditions that must hold before the jobs of a mapping, or a single
+// job, may run. Paths are relative to the source and target of the mapping or job.
+type Preflight struct {
+	// RequireMountpoint requires the target to be a mount point, so that an unmounted
+	// disk is not mistaken for an empty one.
+	RequireMountpoint bool `yaml:"require_mountpoint,omitempty"`
+	// RequireFile is a sentinel file that must exist in the target.
+	RequireFile string `yaml:"require_file,omitempty"`
+	// MinFreeSpace is the free space required on the target filesystem.
+	MinFreeSpace ByteSize `yaml:"min_free_space,omitempty"`
+	// NonEmptySource requires the source directory to contain at least one entry.
+	NonEmptySource bool `yaml:"non_empty_source,omitempty"`
+	// OnFailure decides whether a failed check skips or fails the job.
+	OnFailure PreflightPolicy `yaml:"on_failure,omitempty"`
+}
+
+// VolumeInfo queries the filesystems paths live on.
+type VolumeInfo interface {
+	IsMountPoint(path string) (bool, error)
+	FreeSpace(path string) (int64, error)
+}
+
+// OsVolumeInfo implements VolumeInfo for the local operating system.
+type OsVolumeInfo struct{}
+
+// PreflightChecker verifies the preflight conditions of jobs before they run.
+type PreflightChecker struct {
+	Fs      afero.Fs
+	Volumes VolumeInfo
+}
+
+// Check verifies the preflight conditions of mapping and then of job. When a condition
+// does not hold, it returns false with the result to report for the job.
+func (c PreflightChecker) Check(mapping Mapping, job Job) (JobResult, bool) {
+	policy, reason := PreflightFail, ""
+
+	if mapping.Preflight != nil {
+		reason = c.check(*mapping.Preflight, mapping.Source, mapping.Target)
+		policy = preflightPolicy(mapping.Preflight, policy)
+	}
+
+	if job.Preflight != nil {
+		policy = preflightPolicy(job.Preflight, policy)
+
+		if reason == "" {
+			reason = c.check(*job.Preflight, job.Source, job.Target)
+		}
+	}
+
+	if reason == "" {
+		return JobResult{}, true
+	}
+
+	status := Failure
+	if policy == PreflightSkip {
+		status = Skipped
+	}
+
+	return JobResult{Status: status, Reason: "preflight: " + reason}, false
+}
+
+func preflightPolicy(preflight *Preflight, fallback PreflightPolicy) PreflightPolicy {
+	if preflight.OnFailure != "" {
+		return preflight.OnFailure
+	}
+
+	return fallback
+}
+
+// check returns why preflight does not hold for source and target, or "" if it does.
+func (c PreflightChecker) check(preflight Preflight, source, target string) string {
+	if preflight.RequireMountpoint {
+		mounted, err := c.Volumes.IsMountPoint(target)
+
+		switch {
+		case err != nil:
+			return fmt.Sprintf("checking mount point %s: %v", target, err)
+		case !mounted:
+			return target + " is not a mount point"
+		}
+	}
+
+	if preflight.RequireFile != "" {
+		sentinel := preflight.RequireFile
+		if !filepath.IsAbs(sentinel) {
+			sentinel = filepath.Join(target, sentinel)
+		}
+
+		if _, err := c.Fs.Stat(sentinel); err != nil {
+			return "required file " + sentinel + " not found"
+		}
+	}
+
+	if preflight.MinFreeSpace > 0 {
+		free, err := c.Volumes.FreeSpace(existingAncestor(c.Fs, target))
+
+		switch {
+		case err != nil:
+			return fmt.Sprintf("checking free space of %s: %v", target, err)
+		case free < int64(preflight.MinFreeSpace):
+			return fmt.Sprintf("%s free on %s, %s required", FormatBytes(free), target, preflight.MinFreeSpace)
+		}
+	}
+
+	if preflight.NonEmptySource {
+		if empty, err := isEmptyDir(c.Fs, source); err != nil || empty {
+			return "source " + source + " is empty or missing"
+		}
+	}
+
+	return ""
+}
+
+// existingAncestor returns path or its closest existing parent. Job targets
+// are created by rsync, so they may not exist before the first run.
+func existingAncestor(fs afero.Fs, path string) string {
+	for {
+		if _, err := fs.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+
+		path = parent
+	}
+}
+
+func isEmptyDir(fs afero.Fs, path string) (bool, error) {
+	dir, err := fs.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer dir.Close()
+
+	_, err = dir.Readdirnames(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	return false, nil
+}
+
+// ByteSize is a size in bytes configured as a number with an optional unit,
+// e.g. "500M", "10GiB" or "1.5 TB". Units are binary: 1K = 1KB = 1KiB = 1024 bytes.
+type ByteSize int64
+
+var byteSizeUnits = map[string]int64{
+	"": 1, "B": 1,
+	"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+}
+
+// ParseByteSize parses a size such as "10GiB".
+func ParseByteSize(value string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(value)
+	number := strings.TrimRightFunc(trimmed, func(r rune) bool { return r < '0' || r > '9' })
+	unit := strings.ToUpper(strings.TrimSpace(trimmed[len(number):]))
+
+	parsed, err := strconv.ParseFloat(number, 64)
+	multiplier, known := byteSizeUnits[unit]
+
+	if err != nil || !known || parsed < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidByteSize, value)
+	}
+
+	return ByteSize(parsed * float64(multiplier)), nil
+}
+
+func (s ByteSize) String() string {
+	return FormatBytes(int64(s))
+}
+
+// UnmarshalYAML parses the size from a scalar.
+func (s *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	size, err := ParseByteSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	*s = size
+
+	return nil
+}
+
+// MarshalYAML writes the size with a unit.
+func (s ByteSize) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidRemote  = errors.New("invalid remote")
+	ErrRemoteToRemote = errors.New("source and target cannot both be remote")
+	ErrRemoteSource   = errors.New("remote sources are not supported")
+)
+
+const maxPort = 65535
+
+// Remote is a host reached over SSH that holds the source or the target of a mapping.
+type Remote struct {
+	Host   string `yaml:"host"`
+	User   string `yaml:"user,omitempty"`
+	Port   int    `yaml:"port,omitempty"`
+	SSHKey string `yaml:"ssh_key,omitempty"`
+}
+
+// SplitRemotePath splits an rsync location such as "backup@nas:/volume1" into its
+// remote and path. Like rsync, it treats a path as remote when it contains a colon
+// that is not preceded by a slash. Single letters before the colon are taken as
+// Windows drives and rsync daemon locations ("host::module") are not recognized.
+func SplitRemotePath(path string) (*Remote, string, bool) {
+	userHost, remotePath, found := strings.Cut(path, ":")
+	if !found || len(userHost) < 2 || strings.Contains(userHost, "/") || strings.HasPrefix(remotePath, ":") {
+		return nil, path, false
+	}
+
+	remote := &Remote{Host: userHost}
+	if user, host, hasUser := strings.Cut(userHost, "@"); hasUser {
+		remote.User, remote.Host = user, host
+	}
+
+	return remote, remotePath, true
+}
+
+// Spec returns the rsync location of path on the remote, e.g. "backup@nas:/volume1".
+// On a nil remote, it returns path unchanged.
+func (r *Remote) Spec(path string) string {
+	if r == nil {
+		return path
+	}
+
+	if r.User != "" {
+		return r.User + "@" + r.Host + ":" + path
+	}
+
+	return r.Host + ":" + path
+}
+
+// SSHCommand returns the remote shell rsync uses to reach the remote. BatchMode
+// makes ssh fail instead of prompting for a password when run unattended. rsync
+// splits the command itself, so the key is quoted the way rsync reads it: in single
+// quotes, with a doubled quote standing for a quote in the path.
+func (r *Remote) SSHCommand() string {
+	parts := []string{"ssh", "-o", "BatchMode=yes"}
+
+	if r.Port > 0 {
+		parts = append(parts, "-p", strconv.Itoa(r.Port))
+	}
+
+	if r.SSHKey != "" {
+		key := r.SSHKey
+		if strings.ContainsAny(key, " \t'\"") {
+			key = "'" + strings.ReplaceAll(key, "'", "''") + "'"
+		}
+
+		parts = append(parts, "-i", key)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (r *Remote) validate() error {
+	if r.Host == "" {
+		return fmt.Errorf("%w: host is required", ErrInvalidRemote)
+	}
+
+	if r.Port < 0 || r.Port > maxPort {
+		return fmt.Errorf("%w: port %d out of range", ErrInvalidRemote, r.Port)
+	}
+
+	return nil
+}
+
+// resolveRemote resolves variables and macros in the fields of remote.
+func resolveRemote(remote *Remote, variables map[string]string) error {
+	if remote == nil {
+		return nil
+	}
+
+	var err error
+
+	for field := range slices.Values([]*string{&remote.Host, &remote.User, &remote.SSHKey}) {
+		*field, err = resolveField(*field, variables)
+		if err != nil {
+			return fmt.Errorf("resolving remote %q: %w", *field, err)
+		}
+	}
+
+	return nil
+}
+
+// resolveLocation resolves remote and moves a remote given inline in path, such as
+// "backup@nas:/volume1", into it. It returns the remote and the path on it.
+func resolveLocation(remote *Remote, path string, variables map[string]string) (*Remote, string, error) {
+	err := resolveRemote(remote, variables)
+	if err != nil {
+		return nil, "", err
+	}
+
+	inline, remotePath, isRemote := SplitRemotePath(path)
+
+	switch {
+	case !isRemote:
+		return remote, path, nil
+	case remote != nil:
+		return nil, "", fmt.Errorf("%w: %q is remote and has a remote block as well", ErrInvalidRemote, path)
+	default:
+		return inline, remotePath, nil
+	}
+}
+
+// validateRemotes checks the remotes of each mapping and its jobs: only one side of
+// a job may be remote, and its target can neither be a snapshot nor be checked by
+// preflight checks, which both need the local filesystem.
+func validateRemotes(mappings []Mapping) error {
+	var errs []error
+
+	for mapping := range slices.Values(mappings) {
+		err := validateRemoteSides(mapping.SourceRemote, mapping.TargetRemote, mapping.Preflight, "")
+		if err != nil {
+			errs = append(errs, mappingDiagnostic(mapping, "", err))
+		}
+
+		for job := range slices.Values(mapping.Jobs) {
+			err = validateRemoteSides(job.SourceRemote, job.TargetRemote, job.Preflight, job.Mode)
+			if err == nil && job.Manifest && job.TargetRemote != nil {
+				err = fmt.Errorf("%w: manifest requires a local target", ErrInvalidRemote)
+			}
+
+			if err != nil {
+				errs = append(errs, jobDiagnostic(job, "", err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateRemoteSides(source, target *Remote, preflight *Preflight, mode Mode) error {
+	if source != nil && target != nil {
+		return ErrRemoteToRemote
+	}
+
+	for remote := range slices.Values([]*Remote{source, target}) {
+		if remote == nil {
+			continue
+		}
+
+		err := remote.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if target == nil {
+		if source != nil && preflight != nil && preflight.NonEmptySource {
+			return fmt.Errorf("%w: non_empty_source cannot check a remote source", ErrInvalidRemote)
+		}
+
+		return nil
+	}
+
+	if mode == SnapshotMode {
+		return fmt.Errorf("%w: snapshot mode requires a local target", ErrInvalidRemote)
+	}
+
+	if preflight != nil && (preflight.RequireMountpoint || preflight.RequireFile != "" || preflight.MinFreeSpace > 0) {
+		return fmt.Errorf("%w: preflight checks of the target require a local target", ErrInvalidRemote)
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrUnknownReportFormat = errors.New("unknown report format")
+
+// ReportFormat is the file format of a RunReport.
+type ReportFormat string
+
+const (
+	// ReportJSON writes the report as JSON.
+	ReportJSON ReportFormat = "json"
+	// ReportJUnit writes the report as JUnit XML, with a test case per job.
+	ReportJUnit ReportFormat = "junit"
+)
+
+// ReportFormats are the supported report formats.
+var ReportFormats = []ReportFormat{ReportJSON, ReportJUnit}
+
+// ParseReportFormat checks that format is a supported report format.
+func ParseReportFormat(format string) (ReportFormat, error) {
+	if !slices.Contains(ReportFormats, ReportFormat(format)) {
+		return "", fmt.Errorf("%w: %q (supported: %s)", ErrUnknownReportFormat, format, joinFormats())
+	}
+
+	return ReportFormat(format), nil
+}
+
+func joinFormats() string {
+	names := make([]string, 0, len(ReportFormats))
+	for format := range slices.Values(ReportFormats) {
+		names = append(names, string(format))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// FileName returns the name of the report file in the log directory.
+func (f ReportFormat) FileName() string {
+	if f == ReportJUnit {
+		return "report.xml"
+	}
+
+	return "report.json"
+}
+
+// RunReport is the machine-readable record of a run, for monitoring and CI systems.
+type RunReport struct {
+	// Command is the command that ran the jobs, e.g. "run" or "simulate".
+	Command    string `json:"command"`
+	ConfigPath string `json:"config_path"`
+	// RsyncVersion is the first line of rsync --version, if it could be determined.
+	RsyncVersion string            `json:"rsync_version,omitempty"`
+	StartTime    time.Time         `json:"start_time"`
+	EndTime      time.Time         `json:"end_time"`
+	Summary      map[JobStatus]int `json:"summary"`
+	Totals       TransferStats     `json:"totals"`
+	Jobs         []JobReport       `json:"jobs"`
+}
+
+// JobReport is the outcome of one job in a RunReport.
+type JobReport struct {
+	Name            string         `json:"name"`
+	Status          JobStatus      `json:"status"`
+	Reason          string         `json:"reason,omitempty"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	ExitCode        int            `json:"exit_code"`
+	Attempts        int            `json:"attempts,omitempty"`
+	Stats           *TransferStats `json:"stats,omitempty"`
+	LogPath         string         `json:"log_path,omitempty"`
+	// Verification holds the differences found by verify.
+	Verification *VerifyResult `json:"verification,omitempty"`
+}
+
+// NewRunReport creates an empty report of command run with the config at configPath.
+func NewRunReport(command, configPath string) *RunReport {
+	return &RunReport{Command: command, ConfigPath: configPath, Jobs: []JobReport{}}
+}
+
+func (r *RunReport) start(versionInfo string, now time.Time) {
+	r.RsyncVersion, _, _ = strings.Cut(strings.TrimSpace(versionInfo), "\n")
+	r.StartTime = now
+}
+
+func (r *RunReport) add(job Job, result JobResult, duration time.Duration, logPath string) {
+	r.Jobs = append(r.Jobs, JobReport{
+		Name:            job.Name,
+		Status:          result.Status,
+		Reason:          result.Reason,
+		DurationSeconds: duration.Seconds(),
+		ExitCode:        result.ExitCode,
+		Attempts:        result.Attempts,
+		Stats:           result.Stats,
+		LogPath:         logPath,
+		Verification:    result.Verification,
+	})
+}
+
+func (r *RunReport) finish(summary RunSummary, now time.Time) {
+	r.Summary = summary.Counts
+	r.Totals = summary.Totals
+	r.EndTime = now
+}
+
+// Write writes the report in format.
+func (r *RunReport) Write(w io.Writer, format ReportFormat) error {
+	switch format {
+	case ReportJSON:
+		return r.WriteJSON(w)
+	case ReportJUnit:
+		return r.WriteJUnit(w)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownReportFormat, format)
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("encoding run report: %w", err)
+	}
+
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// WriteJUnit writes the report as JUnit XML: a test suite for the run and a test case
+// per job. Failed, timed out, refused and mismatched jobs are failures; skipped and
+// cancelled jobs are skipped.
+func (r *RunReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      "backup " + r.Command,
+		Tests:     len(r.Jobs),
+		Time:      formatSeconds(r.EndTime.Sub(r.StartTime).Seconds()),
+		Timestamp: r.StartTime.Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "config_path", Value: r.ConfigPath},
+			{Name: "rsync_version", Value: r.RsyncVersion},
+		},
+	}
+
+	for job := range slices.Values(r.Jobs) {
+		testCase := junitTestCase{
+			Name:      job.Name,
+			ClassName: "backup." + r.Command,
+			Time:      formatSeconds(job.DurationSeconds),
+		}
+
+		message := &junitMessage{Message: job.Reason, Type: string(job.Status)}
+
+		switch job.Status {
+		case Failure, Timeout, Refused, Mismatch:
+			testCase.Failure = message
+			suite.Failures++
+		case Skipped, Cancelled:
+			testCase.Skipped = message
+			suite.Skipped++
+		default:
+			testCase.SystemOut = job.Reason
+		}
+
+		if job.LogPath != "" {
+			testCase.SystemOut = strings.TrimSpace(testCase.SystemOut + "\nLog: " + job.LogPath)
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return fmt.Errorf("encoding run report: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}})
+	if err != nil {
+		return fmt.Errorf("encoding run report: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("encoding run report: %w", err)
+	}
+
+	return nil
+}
+
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidRestore = errors.New("invalid restore")
+	ErrRestoreRefused = errors.New("restore refused")
+)
+
+// RestoreOptions control how RestoreCommand copies the backup of a job back.
+type RestoreOptions struct {
+	// To is the directory to restore into. It defaults to the job's source.
+	To string
+	// Path restricts the restore to a file or directory, relative to the job's source.
+	Path string
+	// At chooses the snapshot of a job in snapshot mode, see SnapshotStore.Find.
+	// It defaults to the latest snapshot.
+	At string
+	// Delete removes the files that are not in the backup from the destination.
+	Delete bool
+	// Apply performs the restore. Without it, rsync only previews it with --dry-run.
+	Apply bool
+	// OverwriteSource confirms restoring into the job's live source.
+	OverwriteSource bool
+}
+
+// RestoreCommand copies the backup of a job from its target back to its source, or
+// to another directory.
+type RestoreCommand struct {
+	SharedCommand
+
+	Options RestoreOptions
+}
+
+// NewRestoreCommand creates a RestoreCommand with the given dependencies.
+func NewRestoreCommand(binPath string, shell Exec, output io.Writer, options RestoreOptions) RestoreCommand {
+	return RestoreCommand{SharedCommand: NewSharedCommand(binPath, "", shell, output), Options: options}
+}
+
+// overwritesSource reports whether the restore writes into the live source of job.
+func (o RestoreOptions) overwritesSource(job Job) bool {
+	if o.To == "" {
+		return true
+	}
+
+	if job.SourceRemote != nil {
+		return false
+	}
+
+	to, err := filepath.Abs(o.To)
+	if err != nil {
+		to = o.To
+	}
+
+	to, source := NormalizePath(to), NormalizePath(job.Source)
+
+	return to == source || strings.HasPrefix(to, source+"/")
+}
+
+// from returns the directory the backup of job is restored from: its target, or the
+// snapshot chosen by At in snapshot mode.
+func (c RestoreCommand) from(job Job) (string, error) {
+	if job.Mode != SnapshotMode {
+		if c.Options.At != "" {
+			return "", fmt.Errorf("%w: job '%s' is not in snapshot mode", ErrInvalidRestore, job.Name)
+		}
+
+		return job.Target, nil
+	}
+
+	snapshot, err := c.Snapshots.Find(job.Target, c.Options.At)
+	if err != nil {
+		return "", err
+	}
+
+	return snapshot.Path, nil
+}
+
+// Arguments builds the rsync argument list that restores job. The filter rules and rsync
+// options of the job apply, except that nothing is deleted without Delete.
+func (c RestoreCommand) Arguments(job Job) ([]string, error) {
+	opts := c.Options
+
+	if opts.Path != "" && !filepath.IsLocal(opts.Path) {
+		return nil, fmt.Errorf("%w: path %q must be relative to the source of job '%s'",
+			ErrInvalidRestore, opts.Path, job.Name)
+	}
+
+	if opts.Apply && !opts.OverwriteSource && opts.overwritesSource(job) {
+		return nil, fmt.Errorf("%w: restoring into the live source %s of job '%s' requires --overwrite-source",
+			ErrRestoreRefused, job.SourceSpec(), job.Name)
+	}
+
+	from, err := c.from(job)
+	if err != nil {
+		return nil, err
+	}
+
+	to := job.SourceSpec()
+	if opts.To != "" {
+		to = opts.To
+	}
+
+	args := []string{"-aiv", "--stats"}
+
+	if opts.Delete {
+		args = append(args, "--delete")
+	}
+
+	if job.Manifest {
+		args = append(args, "--exclude=/"+ManifestFile)
+	}
+
+	for rule := range slices.Values(job.FilterRules()) {
+		args = append(args, rule.Argument())
+	}
+
+	for arg := range slices.Values(job.Rsync.Arguments()) {
+		if opts.Delete || !strings.HasPrefix(arg, "--delete") {
+			args = append(args, arg)
+		}
+	}
+
+	// With a path, --relative recreates it below the destination from the part after "/./".
+	from = strings.TrimSuffix(from, "/") + "/"
+	if opts.Path != "" {
+		args = append(args, "--relative")
+		from += "./" + filepath.ToSlash(opts.Path)
+	}
+
+	remote := job.TargetRemote
+	if remote == nil && opts.To == "" {
+		remote = job.SourceRemote
+	}
+
+	if remote != nil {
+		args = append(args, "-e", remote.SSHCommand())
+	}
+
+	args = append(args, job.TargetRemote.Spec(from), strings.TrimSuffix(to, "/")+"/")
+
+	if !opts.Apply {
+		args = append([]string{"--dry-run"}, args...)
+	}
+
+	return args, nil
+}
+
+// Run restores job, streaming the rsync output. A restore into the live source without
+// OverwriteSource is refused.
+func (c RestoreCommand) Run(ctx context.Context, job Job) JobResult {
+	args, err := c.Arguments(job)
+	if errors.Is(err, ErrRestoreRefused) {
+		return JobResult{Status: Refused, Reason: err.Error()}
+	}
+
+	if err != nil {
+		return JobResult{Status: Failure, Reason: err.Error()}
+	}
+
+	c.PrintArgs(job, args)
+
+	return c.stream(ctx, args, c.streamOutput(true, nil))
+}
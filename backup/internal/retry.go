@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy")
+
+// DefaultRetryBackoff is the delay before the first retry when retry_backoff is not set.
+const DefaultRetryBackoff = 30 * time.Second
+
+// maxBackoffDoublings caps the growth of the delay between retries.
+const maxBackoffDoublings = 6
+
+// DefaultRetryOn are the exit codes retried when retry_on is not set: those of
+// failures that are likely to go away on their own.
+var DefaultRetryOn = []int{ExitPartialTransfer, ExitTimeout, ExitDaemonTimeout}
+
+// RetryPolicy decides whether a job whose rsync run failed is run again, and which
+// exit codes are not failures at all.
+type RetryPolicy struct {
+	// Retries is the number of times a failed run is repeated.
+	Retries int `yaml:"retries,omitempty"`
+	// RetryBackoff is the delay before the first retry. It doubles with every further retry.
+	RetryBackoff time.Duration `yaml:"retry_backoff,omitempty"`
+	// RetryOn are the rsync exit codes that are retried.
+	RetryOn []int `yaml:"retry_on,omitempty"`
+	// AllowVanished reports exit code 24, files vanishing during the transfer,
+	// as a warning instead of a failure.
+	AllowVanished bool `yaml:"allow_vanished,omitempty"`
+}
+
+// Validate checks that the policy's numbers are in range.
+func (p RetryPolicy) Validate() error {
+	if p.Retries < 0 {
+		return fmt.Errorf("%w: retries must not be negative", ErrInvalidRetryPolicy)
+	}
+
+	if p.RetryBackoff < 0 {
+		return fmt.Errorf("%w: retry_backoff must not be negative", ErrInvalidRetryPolicy)
+	}
+
+	for code := range slices.Values(p.RetryOn) {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("%w: retry_on %d is not an exit code", ErrInvalidRetryPolicy, code)
+		}
+	}
+
+	return nil
+}
+
+// apply maps the exit code of result to its status under the policy.
+func (p RetryPolicy) apply(result JobResult) JobResult {
+	if result.Status == Failure && result.ExitCode == ExitVanished && p.AllowVanished {
+		result.Status = Warning
+	}
+
+	return result
+}
+
+// shouldRetry reports whether result, after attempt runs, warrants another one.
+func (p RetryPolicy) shouldRetry(result JobResult, attempt int) bool {
+	if result.Status != Failure || result.ExitCode == 0 || attempt > p.Retries {
+		return false
+	}
+
+	retryOn := p.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+
+	return slices.Contains(retryOn, result.ExitCode)
+}
+
+// backoff returns the delay before the run following attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.RetryBackoff
+	if delay == 0 {
+		delay = DefaultRetryBackoff
+	}
+
+	return delay << min(attempt-1, maxBackoffDoublings)
+}
+
+// waitContext waits for delay, or until ctx is done.
+func waitContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting to retry: %w", ctx.Err())
+	}
+}
+
+func validateRetryPolicies(jobs []Job) error {
+	var errs []error
+
+	for job := range slices.Values(jobs) {
+		err := job.RetryPolicy.Validate()
+		if err != nil {
+			errs = append(errs, jobDiagnostic(job, "", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
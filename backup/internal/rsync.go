@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,9 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
+
+	"github.com/spf13/afero"
 )
 
 var ErrInvalidRsyncVersion = errors.New("invalid rsync version output")
@@ -23,6 +27,13 @@ type SharedCommand struct {
 
 	Shell  Exec
 	Output io.Writer
+
+	// Snapshots manages the targets of jobs in snapshot mode.
+	Snapshots SnapshotStore
+	// Manifests manages the manifests of jobs with manifest enabled.
+	Manifests ManifestStore
+	// Now names new snapshots.
+	Now func() time.Time
 }
 
 // NewSharedCommand creates a SharedCommand with the given dependencies.
@@ -32,6 +43,9 @@ func NewSharedCommand(binPath string, logPath string, shell Exec, output io.Writ
 		BaseLogPath: logPath,
 		Shell:       shell,
 		Output:      output,
+		Snapshots:   SnapshotStore{Fs: afero.NewOsFs()},
+		Manifests:   ManifestStore{Fs: afero.NewOsFs()},
+		Now:         time.Now,
 	}
 }
 
@@ -39,54 +53,135 @@ func (c SharedCommand) JobLogPath(job Job) string {
 	return fmt.Sprintf("%s/job-%s.log", c.BaseLogPath, job.Name)
 }
 
+// snapshotArguments builds the rsync arguments that write a job in snapshot mode into
+// a new partial snapshot, linked against the latest one, and returns the snapshot name.
+func (c SharedCommand) snapshotArguments(job Job, logPath string, simulate bool) ([]string, string) {
+	name := c.Now().Format(SnapshotTimeFormat)
+
+	linkDest := ""
+	if c.Snapshots.HasLatest(job.Target) {
+		linkDest = filepath.Join(job.Target, LatestSnapshot)
+	}
+
+	return ArgumentsForSnapshot(job, PartialPath(job.Target, name), linkDest, logPath, simulate), name
+}
+
 func (c SharedCommand) PrintArgs(job Job, args []string) {
 	fmt.Fprintf(c.Output, "Job: %s\n", job.Name)
 	fmt.Fprintf(c.Output, "Command: %s %s\n", c.BinPath, strings.Join(args, " "))
 }
 
-func (c SharedCommand) ReportJobStatus(jobName string, status JobStatus, logger *slog.Logger) {
-	logger.Info(fmt.Sprintf("STATUS [%s]: %s", jobName, status))
-	fmt.Fprintf(c.Output, "Status [%s]: %s\n", jobName, status)
+func (c SharedCommand) ReportJobStatus(jobName string, result JobResult, logger *slog.Logger) {
+	details := ""
+
+	switch {
+	case result.Reason != "":
+		details = fmt.Sprintf(" (%s)", result.Reason)
+	case result.Stats != nil:
+		details = fmt.Sprintf(" (%d files, %s transferred, %d deleted)", result.Stats.RegularFilesTransferred,
+			FormatBytes(result.Stats.TotalTransferredSize), result.Stats.DeletedFiles)
+	}
+
+	logger.Info(fmt.Sprintf("STATUS [%s]: %s%s", jobName, result.Status, details))
+	fmt.Fprintf(c.Output, "Status [%s]: %s%s\n", jobName, result.Status, details)
 }
 
-func (c SharedCommand) ReportSummary(counts map[JobStatus]int, logger *slog.Logger) {
-	summary := fmt.Sprintf("Summary: %d succeeded, %d failed, %d skipped",
-		counts[Success], counts[Failure], counts[Skipped])
-	logger.Info(summary)
-	fmt.Fprintln(c.Output, summary)
+func (c SharedCommand) ReportSummary(summary RunSummary, logger *slog.Logger) {
+	for line := range slices.Values(summary.Lines()) {
+		logger.Info(line)
+		fmt.Fprintln(c.Output, line)
+	}
 }
 
-func (c SharedCommand) RunWithArgs(job Job, args []string) JobStatus {
-	c.PrintArgs(job, args)
+// streamOutput returns a LineHandler that writes rsync output to logFile (when not nil)
+// and, if echo is set, to c.Output. Stderr lines are prefixed with "stderr: " and always
+// shown on c.Output.
+func (c SharedCommand) streamOutput(echo bool, logFile io.Writer) LineHandler {
+	return func(stream OutputStream, line string) {
+		if stream == Stderr {
+			line = "stderr: " + line
+		}
 
-	out, err := c.Shell.Execute(c.BinPath, args...)
-	fmt.Fprintf(c.Output, "Output:\n%s\n", string(out))
+		if echo || stream == Stderr {
+			fmt.Fprintln(c.Output, line)
+		}
 
-	if err != nil {
-		return Failure
+		if logFile != nil {
+			fmt.Fprintln(logFile, line)
+		}
 	}
+}
 
-	return Success
+func (c SharedCommand) RunWithArgs(ctx context.Context, job Job, args []string) JobResult {
+	c.PrintArgs(job, args)
+	fmt.Fprintln(c.Output, "Output:")
+
+	return job.RetryPolicy.apply(c.stream(ctx, args, c.streamOutput(true, nil)))
 }
 
-func (c SharedCommand) RunWithArgsAndCaptureOutput(job Job, args []string, logPath string) JobStatus {
+// RunWithArgsAndCaptureOutput runs rsync and streams its output to the file at logPath
+// instead of c.Output. Errors reported by rsync on stderr are shown on c.Output as well.
+// The itemized changes in the output are collected into the result.
+func (c SharedCommand) RunWithArgsAndCaptureOutput(
+	ctx context.Context, job Job, args []string, logPath string,
+) JobResult {
 	c.PrintArgs(job, args)
 
-	out, err := c.Shell.Execute(c.BinPath, args...)
+	var logFile io.Writer
 
-	// Write output to log file for simulate commands
 	if logPath != "" {
-		writeErr := os.WriteFile(logPath, out, LogFilePermission)
-		if writeErr != nil {
-			fmt.Fprintf(c.Output, "Warning: Failed to write output to log file %s: %v\n", logPath, writeErr)
+		file, openErr := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, LogFilePermission)
+		if openErr != nil {
+			fmt.Fprintf(c.Output, "Warning: Failed to write output to log file %s: %v\n", logPath, openErr)
+		} else {
+			defer file.Close()
+
+			logFile = file
 		}
 	}
 
+	return job.RetryPolicy.apply(c.streamChanges(ctx, args, c.streamOutput(false, logFile)))
+}
+
+// streamChanges runs rsync like stream and also collects the itemized changes.
+func (c SharedCommand) streamChanges(ctx context.Context, args []string, output LineHandler) JobResult {
+	var changes ChangeParser
+
+	result := c.stream(ctx, args, func(stream OutputStream, line string) {
+		output(stream, line)
+
+		if stream == Stdout {
+			changes.Feed(line)
+		}
+	})
+	result.Changes = changes.Changes()
+
+	return result
+}
+
+// stream runs rsync with args, passing its output to output while collecting
+// the --stats block into the returned result. A failure is described by its exit code.
+func (c SharedCommand) stream(ctx context.Context, args []string, output LineHandler) JobResult {
+	var parser StatsParser
+
+	err := c.Shell.Stream(ctx, func(stream OutputStream, line string) {
+		output(stream, line)
+
+		if stream == Stdout {
+			parser.Feed(line)
+		}
+	}, c.BinPath, args...)
+
+	result := JobResult{Status: Success, Stats: parser.Stats()}
 	if err != nil {
-		return Failure
+		result.Status = statusForError(ctx)
+
+		if rsyncErr := NewRsyncError(err); rsyncErr != nil && result.Status == Failure {
+			result.ExitCode, result.Reason = rsyncErr.ExitCode, rsyncErr.Error()
+		}
 	}
 
-	return Success
+	return result
 }
 
 func (c SharedCommand) GetVersionInfo() (string, string, error) {
@@ -96,7 +191,7 @@ func (c SharedCommand) GetVersionInfo() (string, string, error) {
 		return "", "", fmt.Errorf("%w: \"%s\"", ErrInvalidRsyncPath, rsyncPath)
 	}
 
-	output, err := c.Shell.Execute(c.BinPath, RsyncVersionFlag)
+	output, err := c.Shell.Execute(context.Background(), c.BinPath, RsyncVersionFlag)
 	if err != nil {
 		return "", "", fmt.Errorf("error fetching rsync version: %w", err)
 	}
@@ -121,11 +216,22 @@ func ArgumentsForJob(job Job, logPath string, simulate bool) []string {
 		args = append(args, "--log-file="+logPath)
 	}
 
-	for excl := range slices.Values(job.Exclusions) {
-		args = append(args, "--exclude="+excl)
+	// The protect rule comes first, so that no exclude rule lets --delete-excluded remove the manifest.
+	if job.Manifest {
+		args = append(args, "--filter=protect /"+ManifestFile)
+	}
+
+	for rule := range slices.Values(job.FilterRules()) {
+		args = append(args, rule.Argument())
+	}
+
+	args = append(args, job.Rsync.Arguments()...)
+
+	if remote := job.Remote(); remote != nil {
+		args = append(args, "-e", remote.SSHCommand())
 	}
 
-	args = append(args, job.Source, job.Target)
+	args = append(args, job.SourceSpec(), job.TargetSpec())
 	if simulate {
 		args = append([]string{"--dry-run"}, args...)
 	}
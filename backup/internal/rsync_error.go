@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+)
+
+// Exit codes of rsync that need special handling.
+const (
+	// ExitPartialTransfer means some files could not be transferred.
+	ExitPartialTransfer = 23
+	// ExitVanished means some source files vanished before they could be transferred.
+	ExitVanished = 24
+	// ExitTimeout means no data was sent or received within --timeout.
+	ExitTimeout = 30
+	// ExitDaemonTimeout means the connection to an rsync daemon timed out.
+	ExitDaemonTimeout = 35
+)
+
+// rsyncExitCodes describes the exit codes of rsync, as documented in rsync(1).
+var rsyncExitCodes = map[int]string{
+	1:   "syntax or usage error",
+	2:   "protocol incompatibility",
+	3:   "errors selecting input/output files, dirs",
+	4:   "requested action not supported",
+	5:   "error starting client-server protocol",
+	6:   "daemon unable to append to log-file",
+	10:  "error in socket I/O",
+	11:  "error in file I/O",
+	12:  "error in rsync protocol data stream",
+	13:  "errors with program diagnostics",
+	14:  "error in IPC code",
+	20:  "received SIGUSR1 or SIGINT",
+	21:  "some error returned by waitpid()",
+	22:  "error allocating core memory buffers",
+	23:  "partial transfer due to error",
+	24:  "partial transfer due to vanished source files",
+	25:  "the --max-delete limit stopped deletions",
+	30:  "timeout in data send/receive",
+	35:  "timeout waiting for daemon connection",
+	255: "ssh connection failed",
+}
+
+// RsyncError is a run of rsync that exited with a non-zero exit code.
+type RsyncError struct {
+	ExitCode int
+	Err      error
+}
+
+// NewRsyncError returns the RsyncError of a failed rsync run, or nil if err
+// does not carry an exit code, e.g. because rsync could not be started.
+func NewRsyncError(err error) *RsyncError {
+	code, exited := ExitCode(err)
+	if !exited {
+		return nil
+	}
+
+	return &RsyncError{ExitCode: code, Err: err}
+}
+
+// Description returns what the exit code means.
+func (e *RsyncError) Description() string {
+	if description, known := rsyncExitCodes[e.ExitCode]; known {
+		return description
+	}
+
+	return "unknown error"
+}
+
+func (e *RsyncError) Error() string {
+	return fmt.Sprintf("rsync exit code %d: %s", e.ExitCode, e.Description())
+}
+
+func (e *RsyncError) Unwrap() error {
+	return e.Err
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"io"
 	"log/slog"
 )
@@ -17,15 +18,19 @@ func NewListCommand(binPath string, shell Exec, output io.Writer) ListCommand {
 	}
 }
 
-func (ListCommand) ReportJobStatus(_ string, _ JobStatus, _ *slog.Logger) {}
+func (ListCommand) ReportJobStatus(_ string, _ JobResult, _ *slog.Logger) {}
 
-func (ListCommand) ReportSummary(_ map[JobStatus]int, _ *slog.Logger) {}
+func (ListCommand) ReportSummary(_ RunSummary, _ *slog.Logger) {}
 
-func (c ListCommand) Run(job Job) JobStatus {
+func (c ListCommand) Run(_ context.Context, job Job) JobResult {
 	logPath := c.JobLogPath(job)
 	args := ArgumentsForJob(job, logPath, false)
+	if job.Mode == SnapshotMode {
+		args, _ = c.snapshotArguments(job, logPath, false)
+	}
 
 	c.PrintArgs(job, args)
+	c.PrintHooks(job)
 
-	return Success
+	return JobResult{Status: Success}
 }
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRsyncOption = errors.New("invalid rsync option")
+
+// RsyncOptions are additional rsync options of the jobs of a config, a mapping or a
+// single job. Each unset field inherits the value of the enclosing level.
+type RsyncOptions struct {
+	HardLinks  *bool `yaml:"hard_links,omitempty"`
+	ACLs       *bool `yaml:"acls,omitempty"`
+	Xattrs     *bool `yaml:"xattrs,omitempty"`
+	NumericIDs *bool `yaml:"numeric_ids,omitempty"`
+	Checksum   *bool `yaml:"checksum,omitempty"`
+	Compress   *bool `yaml:"compress,omitempty"`
+	// BwLimit limits the transfer rate, e.g. "5M" for 5 MiB/s. "0" disables the limit.
+	BwLimit string `yaml:"bwlimit,omitempty"`
+	// ExtraArgs are passed to rsync as they are. They replace, rather than extend,
+	// the extra arguments of the enclosing level.
+	ExtraArgs []string `yaml:"extra_args,omitempty"`
+}
+
+// managedRsyncOptions are set by the tool itself and map to the setting that controls them.
+// All the options that make rsync delete files belong to delete, so that a job without
+// delete never deletes and the max_delete check sees every job that does.
+var managedRsyncOptions = map[string]string{
+	"-n":                "simulate",
+	"--dry-run":         "simulate",
+	"--log-file":        "the log directory",
+	"-i":                "the tool",
+	"--itemize-changes": "the tool",
+	"--stats":           "the tool",
+	"-q":                "the tool",
+	"--quiet":           "the tool",
+	"--delete":          "delete",
+	"--del":             "delete",
+	"--delete-before":   "delete",
+	"--delete-during":   "delete",
+	"--delete-delay":    "delete",
+	"--delete-after":    "delete",
+	"--delete-excluded": "delete",
+	"--exclude":         "exclusions",
+	"--exclude-from":    "exclude_from",
+	"--include":         "filters",
+	"--include-from":    "filters",
+	"--filter":          "filters",
+	"-f":                "filters",
+	"-F":                "filters",
+	"--link-dest":       "mode: snapshot",
+	"-e":                "source_remote and target_remote",
+	"--rsh":             "source_remote and target_remote",
+}
+
+// knownRsyncOptions are the options accepted in extra_args. Options that take a value
+// must be given as "--option=value".
+var knownRsyncOptions = []string{
+	"--acls", "--append-verify", "--atimes", "--backup", "--backup-dir", "--block-size", "--checksum",
+	"--chmod", "--chown", "--compress", "--compress-level", "--contimeout", "--copy-links",
+	"--copy-unsafe-links", "--crtimes", "--delay-updates", "--devices", "--executability",
+	"--fake-super", "--force", "--fuzzy", "--groupmap", "--hard-links", "--iconv", "--ignore-errors",
+	"--ignore-existing", "--inplace", "--ipv4", "--ipv6", "--keep-dirlinks", "--max-size", "--min-size",
+	"--mkpath", "--modify-window", "--no-group", "--no-owner", "--no-perms", "--no-times",
+	"--no-whole-file", "--numeric-ids", "--omit-dir-times", "--one-file-system", "--open-noatime",
+	"--partial", "--partial-dir", "--preallocate", "--protect-args", "--prune-empty-dirs",
+	"--rsync-path", "--safe-links", "--size-only", "--sparse", "--specials", "--suffix", "--super",
+	"--temp-dir", "--timeout", "--update", "--usermap", "--whole-file", "--xattrs",
+}
+
+// knownShortRsyncOptions are the single-letter flags accepted in extra_args, alone or combined as in "-HAX".
+const knownShortRsyncOptions = "AEHKLSUWXbcmuxyz"
+
+// inherit returns the options with each unset field taken from parent.
+func (o *RsyncOptions) inherit(parent *RsyncOptions) *RsyncOptions {
+	if o == nil {
+		return parent
+	}
+
+	if parent == nil {
+		return o
+	}
+
+	merged := *o
+
+	for _, field := range []struct{ own, parent **bool }{
+		{&merged.HardLinks, &parent.HardLinks},
+		{&merged.ACLs, &parent.ACLs},
+		{&merged.Xattrs, &parent.Xattrs},
+		{&merged.NumericIDs, &parent.NumericIDs},
+		{&merged.Checksum, &parent.Checksum},
+		{&merged.Compress, &parent.Compress},
+	} {
+		if *field.own == nil {
+			*field.own = *field.parent
+		}
+	}
+
+	if merged.BwLimit == "" {
+		merged.BwLimit = parent.BwLimit
+	}
+
+	if merged.ExtraArgs == nil {
+		merged.ExtraArgs = parent.ExtraArgs
+	}
+
+	return &merged
+}
+
+// Arguments returns the rsync arguments for the options.
+func (o *RsyncOptions) Arguments() []string {
+	if o == nil {
+		return nil
+	}
+
+	var args []string
+
+	for _, flag := range []struct {
+		enabled *bool
+		name    string
+	}{
+		{o.HardLinks, "--hard-links"},
+		{o.ACLs, "--acls"},
+		{o.Xattrs, "--xattrs"},
+		{o.NumericIDs, "--numeric-ids"},
+		{o.Checksum, "--checksum"},
+		{o.Compress, "--compress"},
+	} {
+		if flag.enabled != nil && *flag.enabled {
+			args = append(args, flag.name)
+		}
+	}
+
+	if o.BwLimit != "" {
+		args = append(args, "--bwlimit="+o.BwLimit)
+	}
+
+	return append(args, o.ExtraArgs...)
+}
+
+// Validate checks the bandwidth limit and that every extra argument is a known
+// option that the tool does not set itself, joining the problems found.
+func (o *RsyncOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	var errs []error
+
+	if o.BwLimit != "" && !isBandwidth(o.BwLimit) {
+		errs = append(errs,
+			fmt.Errorf("%w: bwlimit %q must be a rate such as 500K or 5M", ErrInvalidRsyncOption, o.BwLimit))
+	}
+
+	for arg := range slices.Values(o.ExtraArgs) {
+		errs = append(errs, validateRsyncArg(arg))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateRsyncArg(arg string) error {
+	name, _, _ := strings.Cut(arg, "=")
+
+	if setting, managed := managedRsyncOptions[name]; managed {
+		return fmt.Errorf("%w: %q is set by %s", ErrInvalidRsyncOption, arg, setting)
+	}
+
+	if strings.HasPrefix(name, "--") {
+		if !slices.Contains(knownRsyncOptions, name) {
+			return fmt.Errorf("%w: unknown option %q", ErrInvalidRsyncOption, arg)
+		}
+
+		return nil
+	}
+
+	flags, isShort := strings.CutPrefix(arg, "-")
+	if !isShort || flags == "" {
+		return fmt.Errorf("%w: %q is not an option", ErrInvalidRsyncOption, arg)
+	}
+
+	for _, flag := range flags {
+		if setting, managed := managedRsyncOptions["-"+string(flag)]; managed {
+			return fmt.Errorf("%w: -%c in %q is set by %s", ErrInvalidRsyncOption, flag, arg, setting)
+		}
+
+		if !strings.ContainsRune(knownShortRsyncOptions, flag) {
+			return fmt.Errorf("%w: unknown option -%c in %q", ErrInvalidRsyncOption, flag, arg)
+		}
+	}
+
+	return nil
+}
+
+// isBandwidth reports whether value is an rsync --bwlimit rate: a number with an
+// optional K, M or G suffix.
+func isBandwidth(value string) bool {
+	number := strings.TrimRight(value, "KMGkmg")
+	if len(value)-len(number) > 1 {
+		return false
+	}
+
+	rate, err := strconv.ParseFloat(number, 64)
+
+	return err == nil && rate >= 0
+}
+
+// resolveRsyncOptions resolves variables and macros in the extra arguments of options.
+func resolveRsyncOptions(options *RsyncOptions, variables map[string]string) error {
+	if options == nil {
+		return nil
+	}
+
+	for i, arg := range options.ExtraArgs {
+		resolved, err := resolveField(arg, variables)
+		if err != nil {
+			return fmt.Errorf("resolving rsync argument %q: %w", arg, err)
+		}
+
+		options.ExtraArgs[i] = resolved
+	}
+
+	return nil
+}
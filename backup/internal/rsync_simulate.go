@@ -1,23 +1,77 @@
 package internal
 
-import "io"
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+// DefaultTopDeletions is the number of deletions listed per job after a simulation.
+const DefaultTopDeletions = 10
 
 // SimulateCommand runs rsync in dry-run mode and captures output.
 type SimulateCommand struct {
 	SharedCommand
+
+	// TopDeletions limits the deletions listed per job; zero lists none.
+	TopDeletions int
+	// Report collects the changes of every job when not nil.
+	Report *ChangeReport
 }
 
 // NewSimulateCommand creates a SimulateCommand with the given dependencies.
 func NewSimulateCommand(binPath string, logPath string, shell Exec, output io.Writer) SimulateCommand {
 	return SimulateCommand{
 		SharedCommand: NewSharedCommand(binPath, logPath, shell, output),
+		TopDeletions:  DefaultTopDeletions,
 	}
 }
 
-func (c SimulateCommand) Run(job Job) JobStatus {
-	logPath := c.JobLogPath(job)
-	// Don't use --log-file in simulate mode as rsync doesn't log file changes to it in dry-run
-	args := ArgumentsForJob(job, "", true)
+// Run dry-runs the job. Only the hooks marked safe run around it.
+func (c SimulateCommand) Run(ctx context.Context, job Job) JobResult {
+	return c.withHooks(ctx, job, true, func(ctx context.Context) JobResult {
+		logPath := c.JobLogPath(job)
+		// Don't use --log-file in simulate mode as rsync doesn't log file changes to it in dry-run
+		args := ArgumentsForJob(job, "", true)
+		if job.Mode == SnapshotMode {
+			args, _ = c.snapshotArguments(job, "", true)
+		}
+
+		return c.RunWithArgsAndCaptureOutput(ctx, job, args, logPath)
+	})
+}
+
+// ReportJobStatus reports the status of a job followed by a breakdown of the changes
+// it would make and its largest deletions.
+func (c SimulateCommand) ReportJobStatus(jobName string, result JobResult, logger *slog.Logger) {
+	c.SharedCommand.ReportJobStatus(jobName, result, logger)
+
+	if result.Status == Skipped || result.Status == Cancelled {
+		return
+	}
+
+	if c.Report != nil {
+		c.Report.Add(jobName, result)
+	}
+
+	counts := fmt.Sprintf("Changes [%s]: %s", jobName, FormatChangeCounts(result.Changes))
+	logger.Info(counts)
+	fmt.Fprintln(c.Output, counts)
 
-	return c.RunWithArgsAndCaptureOutput(job, args, logPath)
+	deletions := TopDeletions(result.Changes, c.TopDeletions)
+	if len(deletions) == 0 {
+		return
+	}
+
+	fmt.Fprintf(c.Output, "Top deletions [%s]:\n", jobName)
+
+	for group := range slices.Values(deletions) {
+		if group.Entries > 1 {
+			fmt.Fprintf(c.Output, "  %s (%d entries)\n", group.Path, group.Entries)
+		} else {
+			fmt.Fprintf(c.Output, "  %s\n", group.Path)
+		}
+	}
 }
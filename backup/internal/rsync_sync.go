@@ -1,22 +1,211 @@
 package internal
 
-import "io"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+)
 
 // SyncCommand runs rsync to perform the actual backup.
 type SyncCommand struct {
 	SharedCommand
+
+	// Force skips the max_delete safety check.
+	Force bool
+	// Wait waits before a failed job is retried.
+	Wait func(ctx context.Context, delay time.Duration) error
 }
 
 // NewSyncCommand creates a SyncCommand with the given dependencies.
 func NewSyncCommand(binPath string, logPath string, shell Exec, output io.Writer) SyncCommand {
 	return SyncCommand{
 		SharedCommand: NewSharedCommand(binPath, logPath, shell, output),
+		Wait:          waitContext,
+	}
+}
+
+// Run runs the job between its before and after hooks, retrying it as its
+// retry policy allows.
+func (c SyncCommand) Run(ctx context.Context, job Job) JobResult {
+	return c.withHooks(ctx, job, false, func(ctx context.Context) JobResult {
+		return c.retry(ctx, job)
+	})
+}
+
+// retry syncs the job until it succeeds or its retry policy gives up.
+func (c SyncCommand) retry(ctx context.Context, job Job) JobResult {
+	for attempt := 1; ; attempt++ {
+		result := c.sync(ctx, job)
+		if attempt > 1 {
+			result.Attempts = attempt
+		}
+
+		if !job.RetryPolicy.shouldRetry(result, attempt) {
+			return result
+		}
+
+		delay := job.RetryPolicy.backoff(attempt)
+		fmt.Fprintf(c.Output, "Retrying job %s in %s (%s, retry %d of %d)\n",
+			job.Name, delay, result.Reason, attempt, job.Retries)
+
+		err := c.Wait(ctx, delay)
+		if err != nil {
+			result.Status = statusForError(ctx)
+
+			return result
+		}
 	}
 }
 
-func (c SyncCommand) Run(job Job) JobStatus {
+func (c SyncCommand) sync(ctx context.Context, job Job) JobResult {
+	if job.Mode == SnapshotMode {
+		return c.runSnapshot(ctx, job)
+	}
+
+	if job.Delete && job.MaxDelete != nil && !job.MaxDelete.Unlimited && !c.Force {
+		result, safe := c.checkDeletions(ctx, job)
+		if !safe {
+			return result
+		}
+	}
+
 	logPath := c.JobLogPath(job)
 	args := ArgumentsForJob(job, logPath, false)
 
-	return c.RunWithArgs(job, args)
+	result := c.RunWithArgs(ctx, job, args)
+	if job.Manifest && (result.Status == Success || result.Status == Warning) {
+		c.writeManifest(ctx, job, job.Target, job.Target)
+	}
+
+	return result
+}
+
+// checkDeletions dry-runs the job and counts the deletions it would make. It reports
+// false with the result to return when the job must not run: because the deletions
+// exceed job.MaxDelete, or because the dry run itself did not succeed.
+func (c SyncCommand) checkDeletions(ctx context.Context, job Job) (JobResult, bool) {
+	limit := *job.MaxDelete
+	args := ArgumentsForJob(job, "", true)
+
+	fmt.Fprintf(c.Output, "Checking deletions of job %s (max_delete: %s)\n", job.Name, limit)
+
+	result := job.RetryPolicy.apply(c.streamChanges(ctx, args, c.streamOutput(false, nil)))
+	if result.Status != Success && result.Status != Warning {
+		return JobResult{Status: result.Status, Reason: "deletion check failed"}, false
+	}
+
+	deletions := int64(CountChanges(result.Changes)[ChangeDeleted])
+	// Entries in the target: those kept or updated plus those deleted.
+	total := deletions
+
+	if stats := result.Stats; stats != nil {
+		total = stats.Files - stats.CreatedFiles + deletions
+	}
+
+	if !limit.Exceeded(deletions, total) {
+		return JobResult{}, true
+	}
+
+	return JobResult{
+		Status: Refused,
+		Reason: fmt.Sprintf("%d of %d entries would be deleted, max_delete is %s", deletions, total, limit),
+	}, false
+}
+
+// runSnapshot writes the job into a new snapshot of its target. Only a successful run
+// becomes the latest snapshot, after which snapshots beyond the retention are pruned.
+// The deletion check is not needed: earlier snapshots are never modified.
+func (c SyncCommand) runSnapshot(ctx context.Context, job Job) JobResult {
+	err := c.Snapshots.Fs.MkdirAll(job.Target, LogDirPermission)
+	if err != nil {
+		return JobResult{Status: Failure, Reason: fmt.Sprintf("creating snapshot target: %v", err)}
+	}
+
+	// Partial snapshots of earlier runs that failed to remove them would never be pruned.
+	_, err = c.Snapshots.RemovePartials(job.Target, false)
+	if err != nil {
+		fmt.Fprintf(c.Output, "Warning: Failed to remove partial snapshots of job %s: %v\n", job.Name, err)
+	}
+
+	// The previous snapshot, if any, provides the hashes of unchanged files for the manifest.
+	previous, _ := c.Snapshots.Find(job.Target, "")
+	args, name := c.snapshotArguments(job, c.JobLogPath(job), false)
+
+	result := c.RunWithArgs(ctx, job, args)
+	if result.Status != Success && result.Status != Warning {
+		c.removePartial(job, name)
+
+		return result
+	}
+
+	err = c.Snapshots.Complete(job.Target, name)
+	if err != nil {
+		c.removePartial(job, name)
+
+		result.Status = Failure
+		result.Reason = err.Error()
+
+		return result
+	}
+
+	fmt.Fprintf(c.Output, "Snapshot: %s\n", filepath.Join(job.Target, name))
+
+	if job.Manifest {
+		c.writeManifest(ctx, job, filepath.Join(job.Target, name), previous.Path)
+	}
+
+	if job.Retention != nil {
+		pruned, pruneErr := c.Snapshots.Prune(job.Target, *job.Retention, false)
+		if pruneErr != nil {
+			fmt.Fprintf(c.Output, "Warning: Failed to prune snapshots of job %s: %v\n", job.Name, pruneErr)
+		}
+
+		for snapshot := range slices.Values(pruned) {
+			fmt.Fprintf(c.Output, "Pruned snapshot: %s\n", snapshot.Path)
+		}
+	}
+
+	return result
+}
+
+// removePartial removes the partial snapshot name of a run that did not complete it.
+func (c SyncCommand) removePartial(job Job, name string) {
+	err := c.Snapshots.Fs.RemoveAll(PartialPath(job.Target, name))
+	if err != nil {
+		fmt.Fprintf(c.Output, "Warning: Failed to remove partial snapshot of job %s: %v\n", job.Name, err)
+	}
+}
+
+// writeManifest records the files of the target at root in its manifest, hashing only
+// those that changed since the manifest at previous, if any. Failures are reported as
+// warnings: the files themselves were synced.
+func (c SyncCommand) writeManifest(ctx context.Context, job Job, root, previous string) {
+	var old Manifest
+
+	if previous != "" {
+		manifest, err := c.Manifests.Read(previous)
+		if err != nil && !errors.Is(err, ErrNoManifest) {
+			fmt.Fprintf(c.Output, "Warning: %v, hashing all files\n", err)
+		}
+
+		old = manifest
+	}
+
+	manifest, hashed, err := c.Manifests.Build(ctx, job.Name, root, old)
+	if err == nil {
+		err = c.Manifests.Write(root, manifest)
+	}
+
+	if err != nil {
+		fmt.Fprintf(c.Output, "Warning: Failed to write the manifest of job %s: %v\n", job.Name, err)
+
+		return
+	}
+
+	fmt.Fprintf(c.Output, "Manifest: %s (%d files, %d hashed)\n",
+		filepath.Join(root, ManifestFile), len(manifest.Files), hashed)
 }
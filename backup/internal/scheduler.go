@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// ApplyOptions controls how Config.Apply runs jobs.
+type ApplyOptions struct {
+	// Parallel is the maximum number of jobs running at the same time.
+	// Values below 2 run the jobs one after another.
+	Parallel int
+	// PerTarget caps the number of concurrent jobs sharing a mapping target,
+	// so that two jobs never compete for the same disk. Zero means no cap.
+	PerTarget int
+	// Preflight, when set, checks the preflight conditions of each job before it runs.
+	Preflight *PreflightChecker
+	// Report, when set, collects the results of the run.
+	Report *RunReport
+}
+
+type scheduledJob struct {
+	index   int
+	job     Job
+	mapping *Mapping
+	group   string
+}
+
+type jobOutcome struct {
+	index    int
+	result   JobResult
+	duration time.Duration
+}
+
+// scheduledJobs returns all jobs in configuration order, each tagged with
+// the mapping target used to limit per-target concurrency.
+func (cfg Config) scheduledJobs() []scheduledJob {
+	var jobs []scheduledJob
+
+	for mIdx := range cfg.Mappings {
+		mapping := &cfg.Mappings[mIdx]
+		group := NormalizePath(mapping.TargetRemote.Spec(mapping.Target))
+
+		for job := range slices.Values(mapping.Jobs) {
+			jobs = append(jobs, scheduledJob{
+				index: len(jobs), job: job, mapping: mapping, group: group,
+			})
+		}
+	}
+
+	return jobs
+}
+
+// scheduler runs jobs on a bounded number of goroutines. All bookkeeping
+// happens on the goroutine calling run, so reporting needs no locking.
+type scheduler struct {
+	rsync   JobCommand
+	opts    ApplyOptions
+	queue   []scheduledJob
+	running map[string]int
+	active  int
+	done    chan jobOutcome
+}
+
+// runScheduled executes jobs in configuration order, starting each one as soon
+// as a worker and its target allow it, and calls report for every outcome in
+// configuration order from the calling goroutine, with the time the job took.
+func runScheduled(
+	ctx context.Context, rsync JobCommand, jobs []scheduledJob, opts ApplyOptions,
+	report func(Job, JobResult, time.Duration),
+) {
+	sched := &scheduler{
+		rsync:   rsync,
+		opts:    opts,
+		queue:   slices.Clone(jobs),
+		running: make(map[string]int),
+		done:    make(chan jobOutcome),
+	}
+
+	pending := make(map[int]jobOutcome)
+	next := 0
+
+	for sched.startReady(ctx) {
+		outcome := <-sched.done
+		sched.active--
+		sched.running[jobs[outcome.index].group]--
+		pending[outcome.index] = outcome
+
+		for done, ok := pending[next]; ok; done, ok = pending[next] {
+			report(jobs[next].job, done.result, done.duration)
+			delete(pending, next)
+			next++
+		}
+	}
+}
+
+// startReady launches queued jobs while capacity allows and reports whether
+// any job is still running.
+func (s *scheduler) startReady(ctx context.Context) bool {
+	for s.active < max(s.opts.Parallel, 1) {
+		idx := slices.IndexFunc(s.queue, func(sj scheduledJob) bool {
+			return s.opts.PerTarget <= 0 || s.running[sj.group] < s.opts.PerTarget
+		})
+		if idx < 0 {
+			break
+		}
+
+		sj := s.queue[idx]
+		s.queue = slices.Delete(s.queue, idx, idx+1)
+		s.active++
+		s.running[sj.group]++
+
+		go func() {
+			start := time.Now()
+			result := s.apply(ctx, sj)
+			s.done <- jobOutcome{index: sj.index, result: result, duration: time.Since(start)}
+		}()
+	}
+
+	return s.active > 0
+}
+
+// apply runs the job, with its preflight checks after its before hooks.
+func (s *scheduler) apply(ctx context.Context, sj scheduledJob) JobResult {
+	job := sj.job
+
+	if s.opts.Preflight != nil {
+		checker, mapping := *s.opts.Preflight, *sj.mapping
+		job.preflight = func() (JobResult, bool) { return checker.Check(mapping, sj.job) }
+	}
+
+	return job.Apply(ctx, s.rsync)
+}
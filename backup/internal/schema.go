@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownField = errors.New("unknown field")
+
+// JSONSchema is a JSON Schema (draft-07) describing a value of the configuration.
+type JSONSchema struct {
+	Schema      string   `json:"$schema,omitempty"`
+	Ref         string   `json:"$ref,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Minimum     *int     `json:"minimum,omitempty"`
+	// Properties lists the keys of an object. Keys not listed are described by
+	// AdditionalProperties: false rejects them, a *JSONSchema describes their values.
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	MinProperties        *int                   `json:"minProperties,omitempty"`
+	MaxProperties        *int                   `json:"maxProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+}
+
+const definitionsRef = "#/definitions/"
+
+// ConfigSchema returns the JSON Schema of configuration files, derived from the yaml
+// tags of Config and the types it contains. Each struct is a definition named after
+// its type; the root describes Config.
+func ConfigSchema() *JSONSchema {
+	gen := schemaGenerator{definitions: make(map[string]*JSONSchema)}
+	gen.schemaOf(reflect.TypeFor[Config]())
+
+	root := *gen.definitions["Config"]
+	delete(gen.definitions, "Config")
+
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = "backup-rsync configuration"
+	root.Definitions = gen.definitions
+
+	return &root
+}
+
+type schemaGenerator struct {
+	definitions map[string]*JSONSchema
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+// schemaOf returns the schema of values of type t.
+func (g *schemaGenerator) schemaOf(t reflect.Type) *JSONSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if schema, ok := g.customSchema(t); ok {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.define(t.Name(), t)
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// customSchema returns the schema of the types decoded by their own UnmarshalYAML,
+// whose form in the configuration differs from their fields.
+func (g *schemaGenerator) customSchema(t reflect.Type) (*JSONSchema, bool) {
+	switch t {
+	case reflect.TypeFor[Job]():
+		// Job decodes through JobYAML to tell unset flags from false ones.
+		return g.define("Job", reflect.TypeFor[JobYAML]()), true
+	case reflect.TypeFor[Hook]():
+		return &JSONSchema{OneOf: []*JSONSchema{
+			{Type: "string", Description: "Shell command"},
+			g.define("Hook", t),
+		}}, true
+	case reflect.TypeFor[FilterRule]():
+		properties := make(map[string]*JSONSchema, len(filterActions))
+		for action := range slices.Values(filterActions) {
+			properties[string(action)] = &JSONSchema{Type: "string"}
+		}
+
+		return &JSONSchema{
+			Type: "object", Description: "A filter rule, e.g. {exclude: \"*.tmp\"}",
+			Properties: properties, AdditionalProperties: false, MinProperties: intPtr(1), MaxProperties: intPtr(1),
+		}, true
+	case reflect.TypeFor[DeleteLimit]():
+		return &JSONSchema{Description: "Number or percentage of deletions, or \"none\"", OneOf: []*JSONSchema{
+			{Type: "integer", Minimum: intPtr(0)},
+			{Type: "string", Pattern: `^\s*([0-9]+(\.[0-9]+)?%?|none)\s*$`},
+		}}, true
+	case reflect.TypeFor[ByteSize]():
+		return &JSONSchema{Description: "Size such as 10GiB", OneOf: []*JSONSchema{
+			{Type: "integer", Minimum: intPtr(0)},
+			{Type: "string"},
+		}}, true
+	case reflect.TypeFor[time.Duration]():
+		return &JSONSchema{Type: "string", Description: "Duration such as 90m or 2h"}, true
+	case reflect.TypeFor[Mode]():
+		return &JSONSchema{Type: "string", Enum: []string{string(MirrorMode), string(SnapshotMode)}}, true
+	case reflect.TypeFor[PreflightPolicy]():
+		return &JSONSchema{Type: "string", Enum: []string{string(PreflightFail), string(PreflightSkip)}}, true
+	case reflect.TypeFor[NotifyTrigger]():
+		enum := make([]string, 0, len(NotifyTriggers))
+		for trigger := range slices.Values(NotifyTriggers) {
+			enum = append(enum, string(trigger))
+		}
+
+		return &JSONSchema{Type: "string", Enum: enum}, true
+	default:
+		return nil, false
+	}
+}
+
+// define adds the object schema of the struct t to the definitions under name and
+// returns a reference to it.
+func (g *schemaGenerator) define(name string, t reflect.Type) *JSONSchema {
+	ref := &JSONSchema{Ref: definitionsRef + name}
+	if _, ok := g.definitions[name]; ok {
+		return ref
+	}
+
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema), AdditionalProperties: false}
+	g.definitions[name] = schema
+	g.addFields(schema, t)
+
+	return ref
+}
+
+// addFields adds the fields of the struct t to schema under their yaml names,
+// including the fields of inlined structs.
+func (g *schemaGenerator) addFields(schema *JSONSchema, t reflect.Type) {
+	for field := range slices.Values(reflect.VisibleFields(t)) {
+		if !field.IsExported() || len(field.Index) > 1 {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if strings.Contains(options, "inline") {
+			g.addFields(schema, field.Type)
+
+			continue
+		}
+
+		// Without a name in its tag, yaml uses the lowercased field name.
+		schema.Properties[cmp.Or(name, strings.ToLower(field.Name))] = g.schemaOf(field.Type)
+	}
+}
+
+// knownFieldsChecker reports the keys of a configuration file that its schema does
+// not allow, which yaml would otherwise ignore.
+type knownFieldsChecker struct {
+	file        string
+	definitions map[string]*JSONSchema
+}
+
+// check checks node against schema, which describes the type called name.
+func (c knownFieldsChecker) check(node *yaml.Node, schema *JSONSchema, name string) Diagnostics {
+	node = valueNode(node)
+	if node == nil {
+		return nil
+	}
+
+	if ref, ok := strings.CutPrefix(schema.Ref, definitionsRef); ok {
+		name, schema = ref, c.definitions[ref]
+	}
+
+	for option := range slices.Values(schema.OneOf) {
+		if c.matchesKind(node, option) {
+			return c.check(node, option, name)
+		}
+	}
+
+	var diagnostics Diagnostics
+
+	switch {
+	case node.Kind == yaml.SequenceNode && schema.Items != nil:
+		for item := range slices.Values(node.Content) {
+			diagnostics = append(diagnostics, c.check(item, schema.Items, name)...)
+		}
+	case node.Kind == yaml.MappingNode && schema.Type == "object":
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			if key.Tag == "!!merge" {
+				diagnostics = append(diagnostics, c.check(value, schema, name)...)
+
+				continue
+			}
+
+			if property, ok := schema.Properties[key.Value]; ok {
+				diagnostics = append(diagnostics, c.check(value, property, name)...)
+			} else if additional, ok := schema.AdditionalProperties.(*JSONSchema); ok {
+				diagnostics = append(diagnostics, c.check(value, additional, name)...)
+			} else {
+				diagnostic := newDiagnostic(SeverityError, fmt.Errorf("%w %q in %s", ErrUnknownField, key.Value, name))
+				diagnostic.Position = Position{File: c.file, Line: key.Line, Column: key.Column}
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// matchesKind reports whether node has the kind of value described by schema.
+func (c knownFieldsChecker) matchesKind(node *yaml.Node, schema *JSONSchema) bool {
+	if ref, ok := strings.CutPrefix(schema.Ref, definitionsRef); ok {
+		schema = c.definitions[ref]
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return schema.Type == "object"
+	case yaml.SequenceNode:
+		return schema.Type == "array"
+	default:
+		return schema.Type != "object" && schema.Type != "array"
+	}
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Static errors for job selection.
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrUnknownMapping = errors.New("unknown mapping")
+	ErrUnknownTag     = errors.New("unknown tag")
+)
+
+// JobSelector narrows a configuration down to the jobs requested on the command line.
+// Names and Exclude accept exact job names or glob patterns. A job is selected when it
+// matches every non-empty criterion (Names, Mappings, Tags) and no Exclude entry.
+type JobSelector struct {
+	Names    []string
+	Mappings []string
+	Tags     []string
+	Exclude  []string
+}
+
+// Select returns a copy of cfg that only contains the selected jobs.
+// Names, mappings or tags that do not match anything in cfg are reported as errors.
+func (s JobSelector) Select(cfg Config) (Config, error) {
+	err := s.validate(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+
+	selected := cfg
+	selected.Mappings = make([]Mapping, 0, len(cfg.Mappings))
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		kept := mapping
+		kept.Jobs = nil
+
+		for job := range slices.Values(mapping.Jobs) {
+			if s.matches(mapping, job) {
+				kept.Jobs = append(kept.Jobs, job)
+			}
+		}
+
+		if len(kept.Jobs) > 0 {
+			selected.Mappings = append(selected.Mappings, kept)
+		}
+	}
+
+	return selected, nil
+}
+
+func (s JobSelector) matches(mapping Mapping, job Job) bool {
+	if len(s.Names) > 0 && !slices.ContainsFunc(s.Names, func(p string) bool { return matchName(p, job.Name) }) {
+		return false
+	}
+
+	if len(s.Mappings) > 0 && !slices.ContainsFunc(s.Mappings, func(p string) bool { return matchName(p, mapping.Name) }) {
+		return false
+	}
+
+	if len(s.Tags) > 0 && !slices.ContainsFunc(s.Tags, func(tag string) bool { return slices.Contains(job.Tags, tag) }) {
+		return false
+	}
+
+	return !slices.ContainsFunc(s.Exclude, func(p string) bool { return matchName(p, job.Name) })
+}
+
+func (s JobSelector) validate(cfg Config) error {
+	var jobNames, mappingNames, tags []string
+
+	for mapping := range slices.Values(cfg.Mappings) {
+		mappingNames = append(mappingNames, mapping.Name)
+
+		for job := range slices.Values(mapping.Jobs) {
+			jobNames = append(jobNames, job.Name)
+			tags = append(tags, job.Tags...)
+		}
+	}
+
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+
+	var errs []error
+
+	for pattern := range slices.Values(slices.Concat(s.Names, s.Exclude)) {
+		errs = append(errs, checkPattern(ErrUnknownJob, "jobs", pattern, jobNames))
+	}
+
+	for pattern := range slices.Values(s.Mappings) {
+		errs = append(errs, checkPattern(ErrUnknownMapping, "mappings", pattern, mappingNames))
+	}
+
+	for tag := range slices.Values(s.Tags) {
+		if !slices.Contains(tags, tag) {
+			errs = append(errs, fmt.Errorf("%w %q (valid tags: %s)", ErrUnknownTag, tag, strings.Join(tags, ", ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkPattern returns an error when pattern is malformed or matches none of the valid names.
+func checkPattern(sentinel error, kind, pattern string, valid []string) error {
+	_, err := filepath.Match(pattern, "")
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", sentinel, pattern, err)
+	}
+
+	if slices.ContainsFunc(valid, func(name string) bool { return matchName(pattern, name) }) {
+		return nil
+	}
+
+	return fmt.Errorf("%w %q (valid %s: %s)", sentinel, pattern, kind, strings.Join(valid, ", "))
+}
+
+// matchName matches a name against an exact name or a glob pattern.
+func matchName(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+
+	matched, err := filepath.Match(pattern, name)
+
+	return err == nil && matched
+}
@@ -0,0 +1,317 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidMode         = errors.New("invalid mode")
+	ErrSymlinksUnsupported = errors.New("filesystem does not support symlinks")
+	ErrNoSnapshot          = errors.New("no snapshot")
+)
+
+// Mode selects how a job stores its backup in the target.
+type Mode string
+
+const (
+	// MirrorMode keeps a single copy of the source in the target. This is the default.
+	MirrorMode Mode = "mirror"
+	// SnapshotMode writes each run into a new timestamped directory of the target,
+	// hard-linking unchanged files to the previous snapshot.
+	SnapshotMode Mode = "snapshot"
+)
+
+// UnmarshalYAML accepts "mirror" or "snapshot".
+func (m *Mode) UnmarshalYAML(node *yaml.Node) error {
+	switch mode := Mode(node.Value); mode {
+	case MirrorMode, SnapshotMode:
+		*m = mode
+
+		return nil
+	default:
+		return fmt.Errorf("%w: line %d: %q (expected %q or %q)", ErrInvalidMode, node.Line, node.Value,
+			MirrorMode, SnapshotMode)
+	}
+}
+
+const (
+	// SnapshotTimeFormat names snapshot directories, e.g. "2026-10-17T02-00-00".
+	SnapshotTimeFormat = "2006-01-02T15-04-05"
+	// LatestSnapshot is the symlink in a snapshot target pointing to the newest complete snapshot.
+	LatestSnapshot = "latest"
+	// partialSuffix marks a snapshot that is still being written or whose run failed.
+	partialSuffix = ".partial"
+)
+
+// Retention decides which snapshots survive a prune: the newest snapshot of each of
+// the last Daily days, Weekly ISO weeks and Monthly months that have snapshots.
+type Retention struct {
+	Daily   int `yaml:"daily,omitempty"`
+	Weekly  int `yaml:"weekly,omitempty"`
+	Monthly int `yaml:"monthly,omitempty"`
+}
+
+// IsZero reports whether the retention keeps everything.
+func (r Retention) IsZero() bool {
+	return r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0
+}
+
+// Keep returns the names of the snapshots to keep. The newest snapshot is always kept.
+func (r Retention) Keep(snapshots []Snapshot) map[string]bool {
+	newestFirst := slices.Clone(snapshots)
+	slices.SortFunc(newestFirst, func(a, b Snapshot) int { return b.Time.Compare(a.Time) })
+
+	keep := make(map[string]bool)
+	if len(newestFirst) > 0 {
+		keep[newestFirst[0].Name] = true
+	}
+
+	buckets := []struct {
+		count int
+		key   func(time.Time) string
+	}{
+		{r.Daily, func(t time.Time) string { return t.Format(time.DateOnly) }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{r.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	for bucket := range slices.Values(buckets) {
+		seen := make(map[string]bool)
+
+		for snapshot := range slices.Values(newestFirst) {
+			key := bucket.key(snapshot.Time)
+			if len(seen) >= bucket.count || seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			keep[snapshot.Name] = true
+		}
+	}
+
+	return keep
+}
+
+// Snapshot is a complete snapshot directory of a job target.
+type Snapshot struct {
+	Name string
+	Path string
+	Time time.Time
+}
+
+// SnapshotStore manages the snapshot directories of job targets.
+type SnapshotStore struct {
+	Fs afero.Fs
+}
+
+// List returns the complete snapshots in target, oldest first. A missing target has none.
+func (s SnapshotStore) List(target string) ([]Snapshot, error) {
+	return s.scan(target, "")
+}
+
+// partials returns the partial snapshots in target, left behind by runs that were
+// interrupted before they could remove them, oldest first.
+func (s SnapshotStore) partials(target string) ([]Snapshot, error) {
+	return s.scan(target, partialSuffix)
+}
+
+// scan returns the directories in target named by a snapshot time followed by suffix.
+func (s SnapshotStore) scan(target, suffix string) ([]Snapshot, error) {
+	entries, err := afero.ReadDir(s.Fs, target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots in %s: %w", target, err)
+	}
+
+	var snapshots []Snapshot
+
+	for entry := range slices.Values(entries) {
+		stamp, hasSuffix := strings.CutSuffix(entry.Name(), suffix)
+		created, parseErr := time.ParseInLocation(SnapshotTimeFormat, stamp, time.Local)
+
+		if !entry.IsDir() || !hasSuffix || parseErr != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, Snapshot{
+			Name: entry.Name(), Path: filepath.Join(target, entry.Name()), Time: created,
+		})
+	}
+
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return a.Time.Compare(b.Time) })
+
+	return snapshots, nil
+}
+
+// snapshotTimeFormats are the formats a point in time to find a snapshot for can be given in.
+var snapshotTimeFormats = []string{SnapshotTimeFormat, time.RFC3339, time.DateTime, "2006-01-02T15:04", time.DateOnly}
+
+// Find returns the snapshot in target to restore for at: the newest snapshot taken at or
+// before at, which is a snapshot name or a time such as "2026-10-16 12:00". A date alone
+// stands for the end of that day. Without at, Find returns the latest snapshot.
+func (s SnapshotStore) Find(target, at string) (Snapshot, error) {
+	snapshots, err := s.List(target)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if at == "" {
+		if len(snapshots) == 0 {
+			return Snapshot{}, fmt.Errorf("%w in %s", ErrNoSnapshot, target)
+		}
+
+		return snapshots[len(snapshots)-1], nil
+	}
+
+	until, err := parseSnapshotTime(at)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	for _, snapshot := range slices.Backward(snapshots) {
+		if !snapshot.Time.After(until) {
+			return snapshot, nil
+		}
+	}
+
+	return Snapshot{}, fmt.Errorf("%w in %s at or before %s", ErrNoSnapshot, target, at)
+}
+
+func parseSnapshotTime(at string) (time.Time, error) {
+	for format := range slices.Values(snapshotTimeFormats) {
+		parsed, err := time.ParseInLocation(format, at, time.Local)
+		if err != nil {
+			continue
+		}
+
+		if format == time.DateOnly {
+			parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+
+		return parsed, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q is neither a snapshot name nor a time", ErrNoSnapshot, at)
+}
+
+// HasLatest reports whether target has a latest snapshot to link against.
+func (s SnapshotStore) HasLatest(target string) bool {
+	_, err := s.Fs.Stat(filepath.Join(target, LatestSnapshot))
+
+	return err == nil
+}
+
+// PartialPath returns the directory a snapshot is written to until it is complete.
+func PartialPath(target, name string) string {
+	return filepath.Join(target, name+partialSuffix)
+}
+
+// Complete renames the partial snapshot name in target to its final name and
+// points the latest symlink at it.
+func (s SnapshotStore) Complete(target, name string) error {
+	linker, ok := s.Fs.(afero.Linker)
+	if !ok {
+		return ErrSymlinksUnsupported
+	}
+
+	err := s.Fs.Rename(PartialPath(target, name), filepath.Join(target, name))
+	if err != nil {
+		return fmt.Errorf("completing snapshot %s: %w", name, err)
+	}
+
+	// Replace the link atomically so that latest is never missing.
+	tmpLink := filepath.Join(target, LatestSnapshot+".tmp")
+	_ = s.Fs.Remove(tmpLink)
+
+	err = linker.SymlinkIfPossible(name, tmpLink)
+	if err != nil {
+		return fmt.Errorf("linking latest snapshot: %w", err)
+	}
+
+	err = s.Fs.Rename(tmpLink, filepath.Join(target, LatestSnapshot))
+	if err != nil {
+		return fmt.Errorf("linking latest snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// RemovePartials removes the partial snapshots in target, which no run is writing
+// anymore, and returns them. With dryRun set, nothing is removed.
+func (s SnapshotStore) RemovePartials(target string, dryRun bool) ([]Snapshot, error) {
+	partials, err := s.partials(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.remove(partials, dryRun)
+}
+
+// Prune removes the partial snapshots in target and the snapshots that retention does
+// not keep, and returns them. With dryRun set, nothing is removed.
+func (s SnapshotStore) Prune(target string, retention Retention, dryRun bool) ([]Snapshot, error) {
+	pruned, err := s.RemovePartials(target, dryRun)
+	if err != nil || retention.IsZero() {
+		return pruned, err
+	}
+
+	snapshots, err := s.List(target)
+	if err != nil {
+		return pruned, err
+	}
+
+	keep := retention.Keep(snapshots)
+	snapshots = slices.DeleteFunc(snapshots, func(snapshot Snapshot) bool { return keep[snapshot.Name] })
+
+	removed, err := s.remove(snapshots, dryRun)
+
+	return append(pruned, removed...), err
+}
+
+// remove removes the snapshot directories and returns those it removed. With dryRun
+// set, nothing is removed.
+func (s SnapshotStore) remove(snapshots []Snapshot, dryRun bool) ([]Snapshot, error) {
+	var removed []Snapshot
+
+	for snapshot := range slices.Values(snapshots) {
+		if !dryRun {
+			err := s.Fs.RemoveAll(snapshot.Path)
+			if err != nil {
+				return removed, fmt.Errorf("removing snapshot %s: %w", snapshot.Name, err)
+			}
+		}
+
+		removed = append(removed, snapshot)
+	}
+
+	return removed, nil
+}
+
+// ArgumentsForSnapshot builds the rsync argument list that writes job into the snapshot
+// directory dir. Unchanged files are hard-linked to those in linkDest when it is set.
+func ArgumentsForSnapshot(job Job, dir, linkDest, logPath string, simulate bool) []string {
+	job.Target = dir
+
+	args := ArgumentsForJob(job, logPath, simulate)
+	if linkDest != "" {
+		args = slices.Insert(args, len(args)-2, "--link-dest="+linkDest)
+	}
+
+	return args
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TransferStats holds the statistics printed by rsync --stats.
+type TransferStats struct {
+	Files                   int64   `json:"files"`
+	CreatedFiles            int64   `json:"created_files"`
+	DeletedFiles            int64   `json:"deleted_files"`
+	RegularFilesTransferred int64   `json:"regular_files_transferred"`
+	TotalFileSize           int64   `json:"total_file_size"`
+	TotalTransferredSize    int64   `json:"total_transferred_size"`
+	LiteralData             int64   `json:"literal_data"`
+	MatchedData             int64   `json:"matched_data"`
+	TotalBytesSent          int64   `json:"total_bytes_sent"`
+	TotalBytesReceived      int64   `json:"total_bytes_received"`
+	Speedup                 float64 `json:"speedup"`
+}
+
+// Add accumulates other into s. Speedup cannot be summed, so it is recomputed the way
+// rsync computes it: the total file size divided by the bytes sent and received.
+func (s *TransferStats) Add(other TransferStats) {
+	s.Files += other.Files
+	s.CreatedFiles += other.CreatedFiles
+	s.DeletedFiles += other.DeletedFiles
+	s.RegularFilesTransferred += other.RegularFilesTransferred
+	s.TotalFileSize += other.TotalFileSize
+	s.TotalTransferredSize += other.TotalTransferredSize
+	s.LiteralData += other.LiteralData
+	s.MatchedData += other.MatchedData
+	s.TotalBytesSent += other.TotalBytesSent
+	s.TotalBytesReceived += other.TotalBytesReceived
+
+	if traffic := s.TotalBytesSent + s.TotalBytesReceived; traffic > 0 {
+		s.Speedup = float64(s.TotalFileSize) / float64(traffic)
+	}
+}
+
+// statsFields maps the labels of the rsync --stats block to the fields they fill.
+// "Number of files transferred" is the label used by rsync releases before 3.1.
+func statsFields(s *TransferStats) map[string]*int64 {
+	return map[string]*int64{
+		"Number of files":                     &s.Files,
+		"Number of created files":             &s.CreatedFiles,
+		"Number of deleted files":             &s.DeletedFiles,
+		"Number of regular files transferred": &s.RegularFilesTransferred,
+		"Number of files transferred":         &s.RegularFilesTransferred,
+		"Total file size":                     &s.TotalFileSize,
+		"Total transferred file size":         &s.TotalTransferredSize,
+		"Literal data":                        &s.LiteralData,
+		"Matched data":                        &s.MatchedData,
+		"Total bytes sent":                    &s.TotalBytesSent,
+		"Total bytes received":                &s.TotalBytesReceived,
+	}
+}
+
+// StatsParser extracts TransferStats from rsync output fed to it line by line.
+// Lines that are not part of the statistics block are ignored.
+type StatsParser struct {
+	stats TransferStats
+	found bool
+}
+
+// Feed parses a single line of rsync output.
+func (p *StatsParser) Feed(line string) {
+	if _, rest, ok := strings.Cut(line, "speedup is "); ok {
+		value, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+
+		speedup, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err == nil {
+			p.stats.Speedup = speedup
+			p.found = true
+		}
+
+		return
+	}
+
+	label, value, ok := strings.Cut(line, ": ")
+	if !ok {
+		return
+	}
+
+	field, known := statsFields(&p.stats)[label]
+	if !known {
+		return
+	}
+
+	number, err := parseStatsNumber(value)
+	if err == nil {
+		*field = number
+		p.found = true
+	}
+}
+
+// Stats returns the parsed statistics, or nil when no statistics were seen.
+func (p *StatsParser) Stats() *TransferStats {
+	if !p.found {
+		return nil
+	}
+
+	stats := p.stats
+
+	return &stats
+}
+
+// ParseStats parses the statistics block of a complete rsync output.
+func ParseStats(output string) *TransferStats {
+	var parser StatsParser
+
+	for line := range strings.Lines(output) {
+		parser.Feed(strings.TrimRight(line, "\r\n"))
+	}
+
+	return parser.Stats()
+}
+
+// parseStatsNumber parses values such as "1,234 bytes" or "1,234 (reg: 1,000, dir: 234)".
+func parseStatsNumber(value string) (int64, error) {
+	number, _, _ := strings.Cut(strings.TrimSpace(value), " ")
+
+	parsed, err := strconv.ParseInt(strings.ReplaceAll(number, ",", ""), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing rsync statistic %q: %w", value, err)
+	}
+
+	return parsed, nil
+}
+
+// FormatBytes renders a byte count using binary units, e.g. "1.5 MiB".
+func FormatBytes(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
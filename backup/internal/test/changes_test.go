@@ -0,0 +1,129 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   Change
+		wantOK bool
+	}{
+		{"NewFile", ">f+++++++++ docs/a.txt",
+			Change{Type: ChangeNew, Path: "docs/a.txt", Itemize: ">f+++++++++"}, true},
+		{"UpdatedFile", ">f.st...... docs/b.txt",
+			Change{Type: ChangeUpdated, Path: "docs/b.txt", Itemize: ">f.st......"}, true},
+		{"PathWithSpaces", ">f..t...... my docs/c d.txt",
+			Change{Type: ChangeUpdated, Path: "my docs/c d.txt", Itemize: ">f..t......"}, true},
+		{"AttributesOnly", ".f...p..... docs/c.txt",
+			Change{Type: ChangeAttributes, Path: "docs/c.txt", Itemize: ".f...p....."}, true},
+		{"Deleted", "*deleting   docs/old.txt",
+			Change{Type: ChangeDeleted, Path: "docs/old.txt", Itemize: "*deleting"}, true},
+		{"DeletedDirectory", "*deleting   docs/old/",
+			Change{Type: ChangeDeleted, Path: "docs/old/", Itemize: "*deleting"}, true},
+		{"NewDirectory", "cd+++++++++ docs/new/",
+			Change{Type: ChangeDirectory, Path: "docs/new/", Itemize: "cd+++++++++"}, true},
+		{"DirectoryTime", ".d..t...... docs/",
+			Change{Type: ChangeDirectory, Path: "docs/", Itemize: ".d..t......"}, true},
+		{"Symlink", "cL+++++++++ docs/latest -> v2",
+			Change{Type: ChangeSymlink, Path: "docs/latest", Itemize: "cL+++++++++", LinkTarget: "v2"}, true},
+		{"LegacyCode", ">f+++++++ a.txt",
+			Change{Type: ChangeNew, Path: "a.txt", Itemize: ">f+++++++"}, true},
+		{"Unchanged", ".f          docs/same.txt", Change{}, false},
+		{"FileList", "sending incremental file list", Change{}, false},
+		{"Statistics", "Number of files: 1,234 (reg: 1,000, dir: 234)", Change{}, false},
+		{"Summary", "sent 2,200,000 bytes  received 1,024 bytes  4,402,048.00 bytes/sec", Change{}, false},
+		{"Empty", "", Change{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			change, ok := ParseChange(test.line)
+
+			assert.Equal(t, test.wantOK, ok)
+			assert.Equal(t, test.want, change)
+		})
+	}
+}
+
+func TestChangeParser_Feed(t *testing.T) {
+	var parser ChangeParser
+
+	for _, line := range []string{"sending incremental file list", ">f+++++++++ a.txt", "", "*deleting   b.txt"} {
+		parser.Feed(line)
+	}
+
+	assert.Equal(t, []Change{
+		{Type: ChangeNew, Path: "a.txt", Itemize: ">f+++++++++"},
+		{Type: ChangeDeleted, Path: "b.txt", Itemize: "*deleting"},
+	}, parser.Changes())
+}
+
+func deletions(paths ...string) []Change {
+	changes := make([]Change, 0, len(paths))
+	for _, path := range paths {
+		changes = append(changes, Change{Type: ChangeDeleted, Path: path, Itemize: "*deleting"})
+	}
+
+	return changes
+}
+
+func TestTopDeletions(t *testing.T) {
+	changes := append(deletions(
+		"old/sub/a.txt", "old/sub/", "old/b.txt", "old/",
+		"tmp/x.log", "tmp/y.log",
+		"single.txt",
+	), Change{Type: ChangeNew, Path: "new.txt"})
+
+	assert.Equal(t, []DeletionGroup{{Path: "old/", Entries: 4}}, TopDeletions(changes, 1))
+
+	assert.Equal(t, []DeletionGroup{
+		{Path: "old/", Entries: 4},
+		{Path: "single.txt", Entries: 1},
+		{Path: "tmp/x.log", Entries: 1},
+		{Path: "tmp/y.log", Entries: 1},
+	}, TopDeletions(changes, 10))
+
+	assert.Empty(t, TopDeletions(changes, 0))
+	assert.Empty(t, TopDeletions(changes, -1))
+	assert.Empty(t, TopDeletions(nil, 10))
+}
+
+func TestFormatChangeCounts(t *testing.T) {
+	changes := []Change{
+		{Type: ChangeNew}, {Type: ChangeNew}, {Type: ChangeUpdated},
+		{Type: ChangeDeleted}, {Type: ChangeDirectory},
+	}
+
+	assert.Equal(t, "2 new, 1 updated, 1 deleted, 0 attribute-only, 0 symlink, 1 directory",
+		FormatChangeCounts(changes))
+	assert.Equal(t, "0 new, 0 updated, 0 deleted, 0 attribute-only, 0 symlink, 0 directory",
+		FormatChangeCounts(nil))
+}
+
+func TestChangeReport_WriteJSON(t *testing.T) {
+	var report ChangeReport
+
+	report.Add("docs", JobResult{Status: Success, Changes: []Change{
+		{Type: ChangeSymlink, Path: "latest", Itemize: "cL+++++++++", LinkTarget: "v2"},
+	}})
+	report.Add("music", JobResult{Status: Failure})
+
+	var buf bytes.Buffer
+
+	require.NoError(t, report.WriteJSON(&buf))
+
+	assert.JSONEq(t, `{"jobs": [
+		{"job": "docs", "status": "SUCCESS", "changes": [
+			{"type": "symlink", "path": "latest", "itemize": "cL+++++++++", "link_target": "v2"}
+		]},
+		{"job": "music", "status": "FAILURE", "changes": []}
+	]}`, buf.String())
+}
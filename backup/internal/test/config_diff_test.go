@@ -0,0 +1,135 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func diffConfig(jobs ...Job) Config {
+	return Config{Mappings: []Mapping{{Name: "home", Jobs: jobs}}}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	docs := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Enabled: true,
+		Exclusions: []string{"*.tmp"}}
+	music := Job{Name: "music", Source: "/home/music/", Target: "/backup/music", Enabled: true}
+
+	tests := []struct {
+		name   string
+		before Config
+		after  Config
+		want   []JobDiff
+	}{
+		{
+			name:   "Unchanged",
+			before: diffConfig(docs, music),
+			after:  diffConfig(docs, music),
+		},
+		{
+			name:   "Added and removed",
+			before: diffConfig(docs),
+			after: diffConfig(Job{Name: "music", Source: "/home/music/", Target: "/backup/music",
+				Delete: true, Enabled: true}),
+			want: []JobDiff{
+				{Job: "docs", Kind: JobRemoved},
+				{Job: "music", Kind: JobAdded, Changes: []FieldChange{
+					{Field: "source", After: "/home/music/"},
+					{Field: "target", After: "/backup/music"},
+					{Field: "delete", After: "true", Risky: true},
+				}},
+			},
+		},
+		{
+			name:   "Added disabled",
+			before: diffConfig(),
+			after:  diffConfig(Job{Name: "music", Source: "/home/music/", Target: "/backup/music", Delete: true}),
+			want: []JobDiff{
+				{Job: "music", Kind: JobAdded, Changes: []FieldChange{
+					{Field: "source", After: "/home/music/"},
+					{Field: "target", After: "/backup/music"},
+					{Field: "delete", After: "true"},
+					{Field: "enabled", After: "false"},
+				}},
+			},
+		},
+		{
+			name:   "Paths and filters",
+			before: diffConfig(docs),
+			after: diffConfig(Job{Name: "docs", Source: "/home/alice/docs/", Target: "/backup/docs",
+				Enabled: true, Exclusions: []string{"*.bak"}}),
+			want: []JobDiff{
+				{Job: "docs", Kind: JobChanged, Changes: []FieldChange{
+					{Field: "source", Before: "/home/docs/", After: "/home/alice/docs/"},
+					{Field: "filter", Before: "--exclude=*.tmp"},
+					{Field: "filter", After: "--exclude=*.bak"},
+				}},
+			},
+		},
+		{
+			name:   "Delete turned on",
+			before: diffConfig(docs),
+			after: diffConfig(Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs2",
+				Delete: true, Enabled: true, Exclusions: []string{"*.tmp"}}),
+			want: []JobDiff{
+				{Job: "docs", Kind: JobChanged, Changes: []FieldChange{
+					{Field: "target", Before: "/backup/docs", After: "/backup/docs2", Risky: true},
+					{Field: "delete", Before: "false", After: "true", Risky: true},
+				}},
+			},
+		},
+		{
+			name: "Source of a job that deletes",
+			before: diffConfig(Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Delete: true,
+				Enabled: true}),
+			after: diffConfig(Job{Name: "docs", Source: "/tmp/empty/", Target: "/backup/docs", Delete: true,
+				Enabled: true}),
+			want: []JobDiff{
+				{Job: "docs", Kind: JobChanged, Changes: []FieldChange{
+					{Field: "source", Before: "/home/docs/", After: "/tmp/empty/", Risky: true},
+				}},
+			},
+		},
+		{
+			name: "Enabled with delete",
+			before: diffConfig(Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Delete: true,
+				Exclusions: []string{"*.tmp"}}),
+			after: diffConfig(Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Delete: true,
+				Enabled: true, Exclusions: []string{"*.tmp"}}),
+			want: []JobDiff{
+				{Job: "docs", Kind: JobChanged, Changes: []FieldChange{
+					{Field: "enabled", Before: "false", After: "true", Risky: true},
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, DiffConfigs(test.before, test.after).Jobs)
+		})
+	}
+}
+
+func TestConfigDiff_Lines(t *testing.T) {
+	diff := ConfigDiff{Jobs: []JobDiff{
+		{Job: "music", Kind: JobRemoved},
+		{Job: "docs", Kind: JobChanged, Changes: []FieldChange{
+			{Field: "target", Before: "/backup/docs", After: "/backup/docs2", Risky: true},
+			{Field: "filter", After: "--exclude=*.bak"},
+			{Field: "filter", Before: "--exclude=*.tmp"},
+		}},
+	}}
+
+	assert.Equal(t, []string{
+		"Removed music",
+		"Changed docs",
+		"! target: /backup/docs -> /backup/docs2",
+		"  filter: added --exclude=*.bak",
+		"  filter: removed --exclude=*.tmp",
+		"Summary: 0 added, 1 removed, 1 changed, 1 risky changes",
+	}, diff.Lines())
+
+	assert.Equal(t, []string{"No changes to jobs."}, ConfigDiff{}.Lines())
+}
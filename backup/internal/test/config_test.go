@@ -3,6 +3,7 @@ package internal_test
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -108,6 +109,32 @@ enabled: false
 				Delete: false, Enabled: false,
 			},
 		},
+		{
+			name: "Tags",
+			yaml: `
+name: "test_job"
+source: "/source"
+target: "/target"
+tags: ["daily", "important"]
+`,
+			expected: Job{
+				Name: "test_job", Source: "/source", Target: "/target",
+				Delete: true, Enabled: true, Tags: []string{"daily", "important"},
+			},
+		},
+		{
+			name: "Timeout",
+			yaml: `
+name: "test_job"
+source: "/source"
+target: "/target"
+timeout: 1h30m
+`,
+			expected: Job{
+				Name: "test_job", Source: "/source", Target: "/target",
+				Delete: true, Enabled: true, Timeout: 90 * time.Minute,
+			},
+		},
 		{
 			name: "MixedValues",
 			yaml: `
@@ -220,7 +247,7 @@ func TestLoadResolvedConfig(t *testing.T) {
 			config: testutil.NewConfigBuilder().
 				AddMapping("m", "/src", "/tgt").
 				AddJobToMapping("dup", "a", "a").AddJobToMapping("dup", "b", "b").Build()},
-		{name: "OverlappingSourcePaths", wantErr: "job source path validation failed",
+		{name: "OverlappingSourcePaths", wantErr: "has a source path overlapping with job 'parent'",
 			config: testutil.NewConfigBuilder().
 				AddMapping("m", "/home", "/backup").
 				AddJobToMapping("parent", "user", "user").
@@ -230,7 +257,7 @@ func TestLoadResolvedConfig(t *testing.T) {
 				AddMapping("m", "/home", "/backup").
 				AddJobToMapping("parent", "user", "user", testutil.Exclusions("docs")).
 				AddJobToMapping("child", "user/docs", "docs").Build()},
-		{name: "OverlappingTargetPaths", wantErr: "job target path validation failed",
+		{name: "OverlappingTargetPaths", wantErr: "has a target path overlapping with job 'job1'",
 			config: testutil.NewConfigBuilder().
 				AddMapping("m", "/home", "/backup").
 				AddJobToMapping("job1", "docs", "all").
@@ -271,6 +298,88 @@ func TestLoadResolvedConfig(t *testing.T) {
 	}
 }
 
+func TestLoadResolvedConfig_MaxDeleteInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		MaxDelete("10%").
+		AddMapping("defaults", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").
+		AddJobToMapping("music", "music", "music", testutil.MaxDelete("none")).
+		AddMapping("media", "/media", "/backup/media").
+		MappingMaxDelete("500").
+		AddJobToMapping("photos", "photos", "photos").
+		AddJobToMapping("videos", "videos", "videos", testutil.MaxDelete("5")).
+		Build())
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	limits := make(map[string]string)
+	for _, job := range cfg.AllJobs() {
+		require.NotNil(t, job.MaxDelete, job.Name)
+		limits[job.Name] = job.MaxDelete.String()
+	}
+
+	assert.Equal(t, map[string]string{
+		"docs": "10%", "music": "none", "photos": "500", "videos": "5",
+	}, limits)
+}
+
+func TestLoadResolvedConfig_NoMaxDelete(t *testing.T) {
+	path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("m", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").Build())
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+	assert.Nil(t, cfg.AllJobs()[0].MaxDelete)
+}
+
+func TestLoadResolvedConfig_ModeInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("home", "/home", "/backup").
+		MappingMode("snapshot").
+		AddJobToMapping("docs", "docs", "docs").
+		AddJobToMapping("music", "music", "music", testutil.Mode("mirror")).
+		AddMapping("media", "/media", "/backup/media").
+		AddJobToMapping("photos", "photos", "photos").
+		Build())
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	modes := make(map[string]Mode)
+	for _, job := range cfg.AllJobs() {
+		modes[job.Name] = job.Mode
+	}
+
+	assert.Equal(t, map[string]Mode{"docs": SnapshotMode, "music": MirrorMode, "photos": ""}, modes)
+}
+
+func TestLoadResolvedConfig_RetentionInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `mappings:
+  - name: home
+    source: /home
+    target: /backup
+    mode: snapshot
+    retention: {daily: 7, weekly: 4}
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+      - name: music
+        source: music
+        target: music
+        retention: {monthly: 12}
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	jobs := cfg.AllJobs()
+	assert.Equal(t, &Retention{Daily: 7, Weekly: 4}, jobs[0].Retention)
+	assert.Equal(t, &Retention{Monthly: 12}, jobs[1].Retention)
+}
+
 func TestConfigApply_VersionInfoSuccess(t *testing.T) {
 	mockCmd := NewMockJobCommand(t)
 
@@ -293,12 +402,12 @@ func TestConfigApply_VersionInfoSuccess(t *testing.T) {
 	}
 
 	mockCmd.EXPECT().GetVersionInfo().Return("rsync version 3.2.3", "/usr/bin/rsync", nil).Once()
-	mockCmd.EXPECT().Run(mock.AnythingOfType("internal.Job")).Return(Success).Once()
-	mockCmd.EXPECT().ReportJobStatus("job1", Success, logger).Once()
-	mockCmd.EXPECT().ReportJobStatus("job2", Skipped, logger).Once()
-	mockCmd.EXPECT().ReportSummary(map[JobStatus]int{Success: 1, Skipped: 1}, logger).Once()
+	mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).Return(JobResult{Status: Success}).Once()
+	mockCmd.EXPECT().ReportJobStatus("job1", JobResult{Status: Success}, logger).Once()
+	mockCmd.EXPECT().ReportJobStatus("job2", JobResult{Status: Skipped}, logger).Once()
+	mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{Success: 1, Skipped: 1}}, logger).Once()
 
-	err := cfg.Apply(mockCmd, logger)
+	err := cfg.Apply(t.Context(), mockCmd, logger, ApplyOptions{})
 
 	require.NoError(t, err)
 	assert.Contains(t, logBuf.String(), "Rsync Binary Path: /usr/bin/rsync")
@@ -326,11 +435,11 @@ func TestConfigApply_VersionInfoError(t *testing.T) {
 	}
 
 	mockCmd.EXPECT().GetVersionInfo().Return("", "", errCommandNotFound).Once()
-	mockCmd.EXPECT().Run(mock.AnythingOfType("internal.Job")).Return(Failure).Once()
-	mockCmd.EXPECT().ReportJobStatus("backup", Failure, logger).Once()
-	mockCmd.EXPECT().ReportSummary(map[JobStatus]int{Failure: 1}, logger).Once()
+	mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).Return(JobResult{Status: Failure}).Once()
+	mockCmd.EXPECT().ReportJobStatus("backup", JobResult{Status: Failure}, logger).Once()
+	mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{Failure: 1}}, logger).Once()
 
-	err := cfg.Apply(mockCmd, logger)
+	err := cfg.Apply(t.Context(), mockCmd, logger, ApplyOptions{})
 
 	require.Error(t, err)
 	require.ErrorIs(t, err, ErrJobFailure)
@@ -471,7 +580,7 @@ func TestResolveConfig_MappingSourceMacroError(t *testing.T) {
 
 	_, err := ResolveConfig(cfg)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "resolving mapping source")
+	assert.Contains(t, err.Error(), "mapping 'test' source: resolving")
 }
 
 func TestResolveConfig_MappingTargetMacroError(t *testing.T) {
@@ -488,7 +597,7 @@ func TestResolveConfig_MappingTargetMacroError(t *testing.T) {
 
 	_, err := ResolveConfig(cfg)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "resolving mapping target")
+	assert.Contains(t, err.Error(), "mapping 'test' target: resolving")
 }
 
 func TestResolveConfig_MappingNameMacroError(t *testing.T) {
@@ -505,7 +614,7 @@ func TestResolveConfig_MappingNameMacroError(t *testing.T) {
 
 	_, err := ResolveConfig(cfg)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "resolving mapping name")
+	assert.Contains(t, err.Error(), "mapping '@{bogus:val}' name: resolving")
 }
 
 func TestLoadResolvedConfig_WithOverrides(t *testing.T) {
@@ -623,7 +732,7 @@ func TestLoadResolvedConfig_TemplateVarsMissing(t *testing.T) {
 	_, err := LoadResolvedConfig(path)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "template validation failed")
+	assert.Contains(t, err.Error(), "variables: missing required template variables")
 	assert.Contains(t, err.Error(), "user_cap")
 }
 
@@ -731,7 +840,7 @@ func TestLoadResolvedConfig_IncludeMissingTemplateVars(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 	assert.Contains(t, err.Error(), "missing required template variables")
 	assert.Contains(t, err.Error(), "user_cap")
 }
@@ -747,7 +856,7 @@ func TestLoadResolvedConfig_IncludeFileNotFound(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "nonexistent.yaml": `)
 	assert.Contains(t, err.Error(), "failed to open")
 }
 
@@ -770,7 +879,7 @@ func TestLoadResolvedConfig_NestedIncludesRejected(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "inner.yaml": `)
 	assert.Contains(t, err.Error(), "nested includes are not supported")
 }
 
@@ -787,7 +896,7 @@ func TestLoadResolvedConfig_IncludeInvalidYAML(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "bad.yaml": `)
 	assert.Contains(t, err.Error(), "failed to parse")
 }
 
@@ -886,7 +995,7 @@ func TestLoadResolvedConfig_IncludeMacroError(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestLoadResolvedConfig_IncludeTemplateMappingSourceMacroError(t *testing.T) {
@@ -907,7 +1016,7 @@ func TestLoadResolvedConfig_IncludeTemplateMappingSourceMacroError(t *testing.T)
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestLoadResolvedConfig_IncludeTemplateMappingTargetMacroError(t *testing.T) {
@@ -928,7 +1037,7 @@ func TestLoadResolvedConfig_IncludeTemplateMappingTargetMacroError(t *testing.T)
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestLoadResolvedConfig_IncludeTemplateMappingNameMacroError(t *testing.T) {
@@ -949,7 +1058,7 @@ func TestLoadResolvedConfig_IncludeTemplateMappingNameMacroError(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestConfigBuilder_AddMappingWithExclusions(t *testing.T) {
@@ -981,7 +1090,7 @@ func TestLoadResolvedConfig_IncludeTemplateJobNameMacroError(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestLoadResolvedConfig_IncludeTemplateJobTargetMacroError(t *testing.T) {
@@ -1002,7 +1111,7 @@ func TestLoadResolvedConfig_IncludeTemplateJobTargetMacroError(t *testing.T) {
 	_, err := LoadResolvedConfig(mainPath)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "expanding includes")
+	assert.Contains(t, err.Error(), `include "template.yaml": `)
 }
 
 func TestResolveConfig_JobNameMacroError(t *testing.T) {
@@ -1014,7 +1123,7 @@ func TestResolveConfig_JobNameMacroError(t *testing.T) {
 	_, err := ResolveConfig(cfg)
 
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "resolving job")
+	assert.Contains(t, err.Error(), "job '@{bogus:val}' name: resolving")
 }
 
 // --- AllJobs helper ---
@@ -0,0 +1,93 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDeleteLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    DeleteLimit
+		wantErr bool
+	}{
+		{"500", DeleteLimit{Value: 500}, false},
+		{"0", DeleteLimit{Value: 0}, false},
+		{"10%", DeleteLimit{Value: 10, Percent: true}, false},
+		{"2.5 %", DeleteLimit{Value: 2.5, Percent: true}, false},
+		{"100%", DeleteLimit{Value: 100, Percent: true}, false},
+		{"none", DeleteLimit{Unlimited: true}, false},
+		{"-1", DeleteLimit{}, true},
+		{"150%", DeleteLimit{}, true},
+		{"ten", DeleteLimit{}, true},
+		{"", DeleteLimit{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			limit, err := ParseDeleteLimit(test.value)
+
+			if test.wantErr {
+				require.ErrorIs(t, err, ErrInvalidDeleteLimit)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, limit)
+		})
+	}
+}
+
+func TestDeleteLimit_Exceeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     string
+		deletions int64
+		total     int64
+		want      bool
+	}{
+		{"CountBelow", "10", 9, 1000, false},
+		{"CountAtLimit", "10", 10, 1000, false},
+		{"CountAbove", "10", 11, 1000, true},
+		{"ZeroAllowsNone", "0", 1, 1000, true},
+		{"PercentBelow", "10%", 99, 1000, false},
+		{"PercentAtLimit", "10%", 100, 1000, false},
+		{"PercentAbove", "10%", 101, 1000, true},
+		{"PercentEmptyTarget", "10%", 0, 0, false},
+		{"EverythingDeleted", "50%", 20, 20, true},
+		{"Unlimited", "none", 1000, 1000, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit, err := ParseDeleteLimit(test.limit)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, limit.Exceeded(test.deletions, test.total))
+		})
+	}
+}
+
+func TestDeleteLimit_YAML(t *testing.T) {
+	var job Job
+
+	require.NoError(t, yaml.Unmarshal([]byte("name: docs\nmax_delete: 5%\n"), &job))
+	require.NotNil(t, job.MaxDelete)
+	assert.Equal(t, DeleteLimit{Value: 5, Percent: true}, *job.MaxDelete)
+
+	out, err := yaml.Marshal(job)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "max_delete: 5%\n")
+
+	err = yaml.Unmarshal([]byte("name: docs\nmax_delete: [1]\n"), &job)
+	require.ErrorIs(t, err, ErrInvalidDeleteLimit)
+
+	err = yaml.Unmarshal([]byte("name: docs\nmax_delete: lots\n"), &job)
+	require.ErrorIs(t, err, ErrInvalidDeleteLimit)
+	assert.Contains(t, err.Error(), "line 2")
+}
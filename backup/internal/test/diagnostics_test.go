@@ -0,0 +1,226 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfigFile_ReportsAllProblems(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `rsync:
+  bwlimit: fast
+mappings:
+  - name: home
+    source: /home
+    target: /backup
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+        retries: -1
+      - name: docs
+        source: music
+        target: music
+notifications:
+  - on: [always]
+`)
+
+	_, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 4)
+	assert.Equal(t, 4, diagnostics.Count(SeverityError))
+
+	assert.Equal(t, Diagnostic{
+		Severity: SeverityError, Mapping: "home", Job: "docs", Field: "name",
+		Position: Position{File: path, Line: 12, Column: 9},
+		Message:  "job validation failed: duplicate job name: docs",
+		Err:      diagnostics[0].Err,
+	}, diagnostics[0])
+	require.ErrorIs(t, diagnostics[0], ErrJobValidation)
+
+	assert.Equal(t, "rsync", diagnostics[1].Field)
+	require.ErrorIs(t, diagnostics[1], ErrInvalidRsyncOption)
+
+	assert.Equal(t, Position{File: path, Line: 8, Column: 9}, diagnostics[2].Position)
+	require.ErrorIs(t, diagnostics[2], ErrInvalidRetryPolicy)
+
+	assert.Equal(t, "notifications", diagnostics[3].Field)
+	require.ErrorIs(t, diagnostics[3], ErrInvalidNotification)
+
+	err := diagnostics.Err()
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, diagnostics, validationErr.Diagnostics)
+	require.ErrorIs(t, err, ErrInvalidNotification)
+}
+
+func TestValidateConfigFile_ReportsProblemsOfAllStages(t *testing.T) {
+	path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		TemplateVar("user").
+		AddInclude("missing.yaml", nil).
+		AddMapping("broken", "/srv/@{bogus:a}", "/backup/srv").
+		AddJobToMapping("data", "data", "data").
+		AddMapping("home", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "@{bogus:b}").
+		AddJobToMapping("music", "music", "music").
+		AddJobToMapping("music", "photos", "photos").
+		Build()+"notifications:\n  - on: [always]\n")
+
+	_, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 6)
+	assert.Contains(t, diagnostics[0].Message, `include "missing.yaml": failed to open`)
+	require.ErrorIs(t, diagnostics[1], ErrMissingTemplateVars)
+	assert.Equal(t, "broken", diagnostics[2].Mapping)
+	assert.Equal(t, "source", diagnostics[2].Field)
+	require.ErrorIs(t, diagnostics[2], ErrUnresolvedMacro)
+	assert.Equal(t, "docs", diagnostics[3].Job)
+	assert.Equal(t, "target", diagnostics[3].Field)
+	require.ErrorIs(t, diagnostics[3], ErrUnresolvedMacro)
+	assert.Equal(t, "music", diagnostics[4].Job)
+	require.ErrorIs(t, diagnostics[4], ErrJobValidation)
+	require.ErrorIs(t, diagnostics[5], ErrInvalidNotification)
+}
+
+func TestValidateConfigFile_ChecksPastDecodeErrors(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `mappings:
+  - name: home
+    source: /home
+    target: /backup
+    colour: blue
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+        max_delete: abc
+        timeout: soon
+      - name: docs
+        source: docs/old
+        target: old
+`)
+
+	cfg, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 5)
+	assert.Equal(t, Position{File: path, Line: 5, Column: 5}, diagnostics[0].Position)
+	assert.Contains(t, diagnostics[0].Message, `unknown field "colour"`)
+	assert.Equal(t, Position{File: path, Line: 10}, diagnostics[1].Position)
+	require.ErrorIs(t, diagnostics[1], ErrInvalidDeleteLimit)
+	assert.Equal(t, Position{File: path, Line: 11}, diagnostics[2].Position)
+	assert.Contains(t, diagnostics[2].Message, "cannot unmarshal !!str `soon`")
+	assert.Equal(t, Position{File: path, Line: 12, Column: 9}, diagnostics[3].Position)
+	require.ErrorIs(t, diagnostics[3], ErrJobValidation)
+	assert.Equal(t, "docs", diagnostics[4].Job)
+	require.ErrorIs(t, diagnostics[4], ErrOverlappingPath)
+
+	assert.Len(t, cfg.AllJobs(), 2)
+}
+
+func TestValidateConfigFile_TypeErrors(t *testing.T) {
+	path := testutil.WriteConfigFile(t, "mappings:\n  - name: m\n    jobs:\n      - name: a\n"+
+		"        timeout: soon\n        retries: many\n")
+
+	_, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, Position{File: path, Line: 5}, diagnostics[0].Position)
+	assert.Contains(t, diagnostics[0].Message, "failed to parse YAML: cannot unmarshal !!str `soon`")
+	assert.Equal(t, Position{File: path, Line: 6}, diagnostics[1].Position)
+}
+
+func TestValidateConfigFile_MissingFilterFileWarns(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteConfigFileInDir(t, dir, "common.rules", "- *.tmp\n")
+	path := testutil.WriteConfigFileInDir(t, dir, "test.yaml", `mappings:
+  - name: home
+    source: /home
+    target: /backup
+    exclude_from: [missing.txt]
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+        filters:
+          - merge: common.rules
+          - merge: gone.rules
+`)
+
+	cfg, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, 2, diagnostics.Count(SeverityWarning))
+	assert.Equal(t, "home", diagnostics[0].Mapping)
+	assert.Equal(t, "exclude_from", diagnostics[0].Field)
+	assert.Equal(t, "docs", diagnostics[1].Job)
+	assert.Equal(t, "filters", diagnostics[1].Field)
+	require.ErrorIs(t, diagnostics[1], ErrMissingFilterFile)
+	assert.Contains(t, diagnostics[1].Message, filepath.Join(dir, "gone.rules"))
+
+	require.NoError(t, diagnostics.Err())
+	assert.Len(t, cfg.AllJobs(), 1)
+}
+
+func TestValidateConfigFile_IncludedTemplate(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteConfigFileInDir(t, dir, "template.yaml", testutil.NewConfigBuilder().
+		TemplateVar("user").
+		AddMapping("home", "/home/${user}", "/backup/${user}").
+		AddJobToMapping("${user}_docs", "docs", "@{bogus:val}").
+		Build())
+	path := testutil.WriteConfigFileInDir(t, dir, "main.yaml", testutil.NewConfigBuilder().
+		AddInclude("template.yaml", map[string]string{"user": "alice"}).
+		AddInclude("missing.yaml", nil).
+		Build())
+
+	_, diagnostics := ValidateConfigFile(path)
+
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, "alice_docs", diagnostics[0].Job)
+	assert.Equal(t, filepath.Join(dir, "template.yaml"), diagnostics[0].File)
+	assert.Contains(t, diagnostics[0].Message, `include "template.yaml": resolving "@{bogus:val}"`)
+	assert.Equal(t, path, diagnostics[1].File)
+	assert.Contains(t, diagnostics[1].Message, `include "missing.yaml": failed to open`)
+}
+
+func TestDiagnostics_Report(t *testing.T) {
+	diagnostics := Diagnostics{
+		{Severity: SeverityError, Mapping: "home", Job: "docs", Field: "name",
+			Position: Position{File: "backup.yaml", Line: 9, Column: 9}, Message: "duplicate job name: docs"},
+		{Severity: SeverityError, Field: "rsync", Position: Position{File: "backup.yaml"}, Message: "invalid bwlimit"},
+		{Severity: SeverityWarning, Mapping: "home", Field: "exclude_from",
+			Position: Position{File: "backup.yaml", Line: 2, Column: 5}, Message: "filter file not found"},
+		{Severity: SeverityError, Mapping: "home", Job: "docs", Message: "retries must not be negative"},
+	}
+
+	assert.Equal(t, []string{
+		"Job docs",
+		"  backup.yaml:9:9: error: name: duplicate job name: docs",
+		"  error: retries must not be negative",
+		"Configuration",
+		"  backup.yaml: error: rsync: invalid bwlimit",
+		"Mapping home",
+		"  backup.yaml:2:5: warning: exclude_from: filter file not found",
+		"Summary: 3 errors, 1 warnings",
+	}, diagnostics.Report())
+
+	assert.Equal(t, "backup.yaml:9:9: job 'docs' name: duplicate job name: docs", diagnostics[0].Error())
+	assert.Equal(t, "backup.yaml:2:5: mapping 'home' exclude_from: filter file not found", diagnostics[2].Error())
+}
+
+func TestDiagnostics_Err(t *testing.T) {
+	warning := Diagnostic{Severity: SeverityWarning, Message: "filter file not found", Err: ErrMissingFilterFile}
+	failure := Diagnostic{Severity: SeverityError, Message: "duplicate job name", Err: ErrJobValidation}
+
+	require.NoError(t, Diagnostics{}.Err())
+	require.NoError(t, Diagnostics{warning}.Err())
+
+	err := Diagnostics{warning, failure}.Err()
+	require.ErrorIs(t, err, ErrJobValidation)
+	assert.False(t, errors.Is(err, ErrMissingFilterFile), "warnings are not part of the error")
+}
@@ -0,0 +1,98 @@
+package internal_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shellPath = "/bin/sh"
+
+func requireShell(t *testing.T) {
+	t.Helper()
+
+	_, err := os.Stat(shellPath)
+	if os.IsNotExist(err) {
+		t.Skip("sh not available")
+	}
+}
+
+type recordedLine struct {
+	stream OutputStream
+	text   string
+}
+
+func TestOsExec_Stream_SeparatesStreams(t *testing.T) {
+	requireShell(t)
+
+	var lines []recordedLine
+
+	err := (&OsExec{}).Stream(t.Context(), func(stream OutputStream, line string) {
+		lines = append(lines, recordedLine{stream, line})
+	}, shellPath, "-c", "echo one; echo oops >&2; printf 'two'")
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []recordedLine{{Stdout, "one"}, {Stderr, "oops"}, {Stdout, "two"}}, lines)
+}
+
+func TestOsExec_Stream_Failure(t *testing.T) {
+	requireShell(t)
+
+	err := (&OsExec{}).Stream(t.Context(), func(OutputStream, string) {}, shellPath, "-c", "exit 3")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exit status 3")
+
+	code, exited := ExitCode(err)
+	assert.True(t, exited)
+	assert.Equal(t, 3, code)
+}
+
+func TestOsExec_Stream_StartError(t *testing.T) {
+	err := (&OsExec{}).Stream(t.Context(), func(OutputStream, string) {}, "/nonexistent/rsync")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to start command")
+
+	_, exited := ExitCode(err)
+	assert.False(t, exited)
+}
+
+func TestOsExec_Stream_CancelSendsSigterm(t *testing.T) {
+	requireShell(t)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	var lines []string
+
+	start := time.Now()
+	err := (&OsExec{}).Stream(ctx, func(_ OutputStream, line string) {
+		lines = append(lines, line)
+	}, shellPath, "-c", "trap 'echo terminated; exit 143' TERM; echo started; while :; do sleep 0.01; done")
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), TerminateGracePeriod)
+	assert.Equal(t, []string{"started", "terminated"}, lines)
+}
+
+func TestOsExec_Stream_ChildKeepsOutputOpen(t *testing.T) {
+	requireShell(t)
+
+	var lines []string
+
+	start := time.Now()
+	err := (&OsExec{}).Stream(t.Context(), func(_ OutputStream, line string) {
+		lines = append(lines, line)
+	}, shellPath, "-c", "echo started; sleep 30 &")
+
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*TerminateGracePeriod)
+	assert.Equal(t, []string{"started"}, lines)
+}
@@ -0,0 +1,235 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFilterRule_YAML(t *testing.T) {
+	var rules []FilterRule
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- include: "/Documents/keep/"
+- exclude: "/Documents/*"
+- merge: "rules.txt"
+- dir_merge: ".rsync-filter"
+- exclude_from: "excludes.txt"
+`), &rules))
+
+	assert.Equal(t, []FilterRule{
+		{Action: FilterInclude, Pattern: "/Documents/keep/"},
+		{Action: FilterExclude, Pattern: "/Documents/*"},
+		{Action: FilterMerge, Pattern: "rules.txt"},
+		{Action: FilterDirMerge, Pattern: ".rsync-filter"},
+		{Action: FilterExcludeFrom, Pattern: "excludes.txt"},
+	}, rules)
+
+	out, err := yaml.Marshal(rules[:1])
+	require.NoError(t, err)
+	assert.Equal(t, "- include: /Documents/keep/\n", string(out))
+
+	for _, invalid := range []string{`- delete: "x"`, `- {include: "a", exclude: "b"}`, `- exclude: ""`, `- "*.tmp"`} {
+		require.ErrorIs(t, yaml.Unmarshal([]byte(invalid), &rules), ErrInvalidFilterRule, invalid)
+	}
+}
+
+func TestFilterRule_Argument(t *testing.T) {
+	tests := []struct {
+		rule FilterRule
+		want string
+	}{
+		{FilterRule{Action: FilterInclude, Pattern: "/a/"}, "--include=/a/"},
+		{FilterRule{Action: FilterExclude, Pattern: "*.tmp"}, "--exclude=*.tmp"},
+		{FilterRule{Action: FilterMerge, Pattern: "/etc/rules"}, "--filter=merge /etc/rules"},
+		{FilterRule{Action: FilterDirMerge, Pattern: ".rsync-filter"}, "--filter=dir-merge .rsync-filter"},
+		{FilterRule{Action: FilterExcludeFrom, Pattern: "/etc/excludes"}, "--exclude-from=/etc/excludes"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, test.rule.Argument())
+	}
+}
+
+func excludeRule(pattern string) FilterRule {
+	return FilterRule{Action: FilterExclude, Pattern: pattern}
+}
+
+func TestFilterMatcher_Patterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"cache", "/data/cache", true},
+		{"cache", "/data/a/b/cache", true},
+		{"cache", "/data/caches", false},
+		{"cache/", "/data/a/cache", true},
+		{"/cache", "/data/cache", true},
+		{"/cache", "/data/a/cache", false},
+		{"*.tmp", "/data/a/x.tmp", true},
+		{"*.tmp", "/data/a.tmp/b", true},
+		{"/a/*/c", "/data/a/b/c", true},
+		{"/a/*/c", "/data/a/b/x/c", false},
+		{"/a/**/c", "/data/a/b/x/c", true},
+		{"a/b", "/data/x/a/b", true},
+		{"a/b", "/data/xa/b", false},
+		{"**/node_modules", "/data/x/y/node_modules", true},
+		{"/build/***", "/data/build", true},
+		{"/build/***", "/data/build/out", true},
+		{"ca?he", "/data/cache", true},
+		{"[ct]ache", "/data/tache", true},
+		{"[!ct]ache", "/data/cache", false},
+		{"[a-c]ache", "/data/bache", true},
+		{`\*`, "/data/*", true},
+		{`\*`, "/data/x", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			_, excluded := FilterMatcher{}.Excluded([]FilterRule{excludeRule(test.pattern)}, "/data/", test.path)
+
+			assert.Equal(t, test.want, excluded)
+		})
+	}
+}
+
+func TestFilterMatcher_Order(t *testing.T) {
+	rules := []FilterRule{
+		{Action: FilterInclude, Pattern: "/docs/keep/"},
+		excludeRule("/docs/*"),
+	}
+
+	matcher := FilterMatcher{}
+
+	_, excluded := matcher.Excluded(rules, "/data", "/data/docs/keep")
+	assert.False(t, excluded)
+
+	rule, excluded := matcher.Excluded(rules, "/data", "/data/docs/drop/deeper")
+	assert.True(t, excluded)
+	assert.Equal(t, "/docs/*", rule.Pattern)
+
+	_, excluded = matcher.Excluded(rules, "/data", "/data")
+	assert.False(t, excluded, "the root itself is never excluded")
+
+	_, excluded = matcher.Excluded(rules, "/data", "/other/docs/drop")
+	assert.False(t, excluded, "paths outside the root are not excluded")
+}
+
+func TestFilterMatcher_MergeFiles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/etc/backup/rules", []byte("# comment\n+ /cache/keep/\n- /cache/*\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/etc/backup/excludes", []byte("; comment\n*.iso\n\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/data/.rsync-filter", []byte("- /projects/old\n"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/data/projects/.rsync-filter", []byte("+ old\n- scratch\n"), 0644))
+
+	rules := []FilterRule{
+		{Action: FilterMerge, Pattern: "/etc/backup/rules"},
+		{Action: FilterExcludeFrom, Pattern: "/etc/backup/excludes"},
+		{Action: FilterDirMerge, Pattern: ".rsync-filter"},
+		{Action: FilterMerge, Pattern: "/etc/backup/missing"},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/cache/keep", false},
+		{"/data/cache/drop", true},
+		{"/data/images/disk.iso", true},
+		{"/data/projects/scratch", true},
+		// The rules of /data/projects/.rsync-filter take precedence over those of /data.
+		{"/data/projects/old", false},
+		{"/data/projects/new", false},
+	}
+
+	matcher := FilterMatcher{Fs: fs}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			_, excluded := matcher.Excluded(rules, "/data/", test.path)
+
+			assert.Equal(t, test.want, excluded)
+		})
+	}
+
+	_, excluded := FilterMatcher{}.Excluded(rules, "/data/", "/data/cache/drop")
+	assert.False(t, excluded, "merge files are not read without a filesystem")
+}
+
+func TestLoadResolvedConfig_FilterRules(t *testing.T) {
+	dir := t.TempDir()
+	path := testutil.WriteConfigFileInDir(t, dir, "sync.yaml", `
+variables:
+  scratch: tmp
+mappings:
+  - name: home
+    source: /home/user
+    target: /backup/user
+    exclusions:
+      - "/Documents/cache/"
+      - "/Music/"
+      - "*.bak"
+    filters:
+      - merge: "rules/home.rules"
+    jobs:
+      - name: docs
+        source: Documents
+        target: docs
+        exclusions: ["drafts/"]
+        filters:
+          - include: "/${scratch}/keep/"
+          - exclude: "/${scratch}/"
+        exclude_from: ["/etc/backup/excludes"]
+      - name: music
+        source: Music
+        target: music
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	jobs := cfg.AllJobs()
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "--delete",
+		"--include=/tmp/keep/", "--exclude=/tmp/", "--exclude=drafts/", "--exclude-from=/etc/backup/excludes",
+		"--filter=merge " + filepath.Join(dir, "rules/home.rules"), "--exclude=/cache/", "--exclude=*.bak",
+		"/home/user/Documents/", "/backup/user/docs",
+	}, ArgumentsForJob(jobs[0], "", false))
+
+	// Anchored exclusions outside of the job's source or excluding it as a whole are dropped.
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "--delete",
+		"--filter=merge " + filepath.Join(dir, "rules/home.rules"), "--exclude=*.bak",
+		"/home/user/Music/", "/backup/user/music",
+	}, ArgumentsForJob(jobs[1], "", false))
+}
+
+func TestListUncoveredPaths_GlobExclusions(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, dir := range []string{"/data/docs", "/data/a/node_modules/x", "/data/b/build.cache", "/data/c/old"} {
+		require.NoError(t, fs.MkdirAll(dir, 0755))
+	}
+
+	require.NoError(t, afero.WriteFile(fs, "/data/c/.rsync-filter", []byte("- old\n"), 0644))
+
+	cfg := Config{Mappings: []Mapping{
+		{Name: "data", Source: "/data", Target: "/bak/data", Exclusions: []string{"node_modules"}, Jobs: []Job{
+			{Name: "docs", Source: "/data/docs/"},
+			{Name: "a", Source: "/data/a/"},
+			{Name: "b", Source: "/data/b/", Filters: []FilterRule{excludeRule("*.cache")}},
+		}},
+	}}
+
+	// Only /data/c/old is neither covered nor excluded.
+	assert.Equal(t, []string{"/data"}, newSilentChecker(fs).ListUncoveredPaths(cfg))
+
+	cfg.Mappings[0].Filters = []FilterRule{{Action: FilterDirMerge, Pattern: ".rsync-filter"}}
+	assert.Empty(t, newSilentChecker(fs).ListUncoveredPaths(cfg))
+}
@@ -22,6 +22,9 @@ func TestNormalizePath(t *testing.T) {
 		{"/path//with//double/slashes/", "/path/with/double/slashes"},
 		{"/trailing/slash/", "/trailing/slash"},
 		{"/no/trailing/slash", "/no/trailing/slash"},
+		{"backup@nas:/volume1//docs/", "nas:/volume1/docs"},
+		{"nas:/volume1", "nas:/volume1"},
+		{"C:/Users/", "C:/Users"},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,140 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var historyNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func TestHistoryPath(t *testing.T) {
+	assert.Equal(t, "logs/history-sync.jsonl", HistoryPath("/etc/backup/sync.yaml"))
+}
+
+func TestHistory_AppendAndRead(t *testing.T) {
+	history := History{Fs: afero.NewMemMapFs(), Path: "logs/history-sync.jsonl"}
+
+	entries, err := history.Read()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	for i, status := range []JobStatus{Failure, Success} {
+		report := testRunReport()
+		report.StartTime = historyNow.Add(time.Duration(i) * time.Hour)
+		report.Jobs = []JobReport{{Name: "docs", Status: status, DurationSeconds: 1.5, ExitCode: 23 * (1 - i)}}
+
+		require.NoError(t, history.Append(report))
+	}
+
+	entries, err = history.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []HistoryEntry{
+		{Time: historyNow, Job: "docs", Status: Failure, DurationSeconds: 1.5, ExitCode: 23},
+		{Time: historyNow.Add(time.Hour), Job: "docs", Status: Success, DurationSeconds: 1.5},
+	}, entries)
+}
+
+func TestHistory_ReadInvalid(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "history.jsonl", []byte("{\"job\":\"docs\"}\nnot json\n"), 0o644))
+
+	_, err := History{Fs: fs, Path: "history.jsonl"}.Read()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestJobHealths(t *testing.T) {
+	entry := func(hoursAgo int, job string, status JobStatus) HistoryEntry {
+		return HistoryEntry{Time: historyNow.Add(-time.Duration(hoursAgo) * time.Hour), Job: job, Status: status}
+	}
+
+	entries := []HistoryEntry{
+		entry(72, "docs", Success),
+		entry(50, "music", Success),
+		entry(48, "docs", Success),
+		entry(30, "music", Failure),
+		entry(26, "docs", Skipped),
+		entry(24, "docs", Failure),
+		entry(24, "photos", Warning),
+		entry(2, "docs", Timeout),
+		entry(1, "music", Failure),
+	}
+
+	tests := []struct {
+		name         string
+		job          Job
+		wantState    HealthState
+		wantFailures int
+		wantAge      time.Duration
+	}{
+		{"OK", Job{Name: "photos", Enabled: true, MaxAge: 25 * time.Hour}, HealthOK, 0, 24 * time.Hour},
+		{"Failing", Job{Name: "docs", Enabled: true}, HealthWarning, 2, 48 * time.Hour},
+		{"Stale", Job{Name: "music", Enabled: true, MaxAge: 26 * time.Hour}, HealthCritical, 2, 50 * time.Hour},
+		{"NeverRun", Job{Name: "videos", Enabled: true}, HealthUnknown, 0, 0},
+		{"NeverRunWithMaxAge", Job{Name: "videos", Enabled: true, MaxAge: time.Hour}, HealthCritical, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			healths := JobHealths([]Job{test.job}, entries, historyNow)
+
+			require.Len(t, healths, 1)
+			assert.Equal(t, test.wantState, healths[0].State)
+			assert.Equal(t, test.wantFailures, healths[0].ConsecutiveFailures)
+			assert.Equal(t, test.wantAge, healths[0].Age)
+		})
+	}
+
+	assert.Empty(t, JobHealths([]Job{{Name: "docs"}}, entries, historyNow), "disabled jobs are not reported")
+}
+
+func TestHealthState(t *testing.T) {
+	assert.Equal(t, HealthWarning, HealthOK.Worse(HealthWarning))
+	assert.Equal(t, HealthUnknown, HealthUnknown.Worse(HealthWarning))
+	assert.Equal(t, HealthCritical, HealthUnknown.Worse(HealthCritical))
+
+	for state, code := range map[HealthState]int{HealthOK: 0, HealthWarning: 1, HealthCritical: 2, HealthUnknown: 3} {
+		assert.Equal(t, code, state.ExitCode(), state)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "0m", FormatAge(30*time.Second))
+	assert.Equal(t, "45m", FormatAge(45*time.Minute))
+	assert.Equal(t, "2h5m", FormatAge(2*time.Hour+5*time.Minute))
+	assert.Equal(t, "3h", FormatAge(3*time.Hour))
+	assert.Equal(t, "3d4h", FormatAge(76*time.Hour+30*time.Minute))
+	assert.Equal(t, "1d", FormatAge(24*time.Hour+30*time.Minute))
+}
+
+func TestLoadResolvedConfig_MaxAgeInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `max_age: 26h
+mappings:
+  - name: home
+    source: /home
+    target: /backup
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+      - name: music
+        source: music
+        target: music
+        max_age: 168h
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	jobs := cfg.AllJobs()
+	assert.Equal(t, 26*time.Hour, jobs[0].MaxAge)
+	assert.Equal(t, 168*time.Hour, jobs[1].MaxAge)
+}
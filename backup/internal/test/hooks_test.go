@@ -0,0 +1,241 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var errHookFailed = errors.New("exit status 1")
+
+// hookCall is a hook command run through the mock shell, with its environment.
+type hookCall struct {
+	command string
+	env     []string
+}
+
+// expectHooks makes mockExec run hooks by recording them into calls. Hooks whose
+// command is in failing fail.
+func expectHooks(mockExec *MockExec, calls *[]hookCall, failing ...string) {
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, "env", mock.Anything).
+		RunAndReturn(func(_ context.Context, _ LineHandler, _ string, args ...string) error {
+			command := args[len(args)-1]
+			*calls = append(*calls, hookCall{command: command, env: args[:len(args)-3]})
+
+			if slices.Contains(failing, command) {
+				return errHookFailed
+			}
+
+			return nil
+		}).Maybe()
+}
+
+func commands(calls []hookCall) []string {
+	var names []string
+	for call := range slices.Values(calls) {
+		names = append(names, call.command)
+	}
+
+	return names
+}
+
+func hookedJob() Job {
+	job := testutil.NewTestJob()
+	job.Hooks = &Hooks{
+		Before:    []Hook{{Command: "stop-db"}},
+		After:     []Hook{{Command: "start-db"}, {Command: "notify", Safe: true}},
+		OnFailure: []Hook{{Command: "alert"}},
+	}
+
+	return job
+}
+
+func TestHook_YAML(t *testing.T) {
+	var hooks Hooks
+
+	err := yaml.Unmarshal([]byte("before: [stop-db]\nafter:\n  - {command: notify, safe: true}\n"), &hooks)
+	require.NoError(t, err)
+	assert.Equal(t, Hooks{Before: []Hook{{Command: "stop-db"}}, After: []Hook{{Command: "notify", Safe: true}}}, hooks)
+
+	out, err := yaml.Marshal(hooks)
+	require.NoError(t, err)
+	assert.Equal(t, "before:\n    - stop-db\nafter:\n    - command: notify\n      safe: true\n", string(out))
+
+	err = yaml.Unmarshal([]byte("before:\n  - {safe: true}\n"), &hooks)
+	require.ErrorIs(t, err, ErrInvalidHook)
+}
+
+func TestLoadResolvedConfig_HookInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `variables:
+  db: postgres
+hooks:
+  before: [lock]
+  after: [unlock]
+mappings:
+  - name: data
+    source: /data
+    target: /backup
+    hooks:
+      before: ["systemctl stop ${db}"]
+      after: ["systemctl start ${db}"]
+    jobs:
+      - name: db
+        source: db
+        target: db
+        hooks:
+          before: [snapshot]
+          on_failure: [alert]
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, &Hooks{
+		Before:    []Hook{{Command: "lock"}, {Command: "systemctl stop postgres"}, {Command: "snapshot"}},
+		After:     []Hook{{Command: "systemctl start postgres"}, {Command: "unlock"}},
+		OnFailure: []Hook{{Command: "alert"}},
+	}, cfg.AllJobs()[0].Hooks)
+}
+
+func TestSyncCommand_Run_Hooks(t *testing.T) {
+	mockExec := NewMockExec(t)
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &bytes.Buffer{})
+
+	var calls []hookCall
+
+	expectHooks(mockExec, &calls)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(func(context.Context, LineHandler, string, ...string) error {
+			calls = append(calls, hookCall{command: "rsync"})
+
+			return nil
+		}).Once()
+
+	result := cmd.Run(t.Context(), hookedJob())
+
+	assert.Equal(t, Success, result.Status)
+	assert.Equal(t, []string{"stop-db", "rsync", "start-db", "notify"}, commands(calls))
+	assert.Equal(t, []string{
+		"BACKUP_HOOK=before",
+		"BACKUP_JOB_NAME=test-job",
+		"BACKUP_SOURCE=/home/user/docs/",
+		"BACKUP_TARGET=/backup/user/docs/",
+		"BACKUP_LOG=/logs/base/job-test-job.log",
+		"BACKUP_SIMULATE=false",
+	}, calls[0].env)
+	assert.Contains(t, calls[2].env, "BACKUP_STATUS=SUCCESS")
+}
+
+func TestSyncCommand_Run_HookFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		failing      []string
+		rsyncErr     error
+		wantStatus   JobStatus
+		wantCommands []string
+		wantOutput   string
+	}{
+		{
+			name:         "BeforeFails",
+			failing:      []string{"stop-db"},
+			wantStatus:   Skipped,
+			wantCommands: []string{"stop-db", "alert", "start-db", "notify"},
+			wantOutput:   `Status [test-job]: SKIPPED (hook failed: before hook "stop-db": exit status 1)`,
+		},
+		{
+			name:         "RsyncFails",
+			rsyncErr:     errCommandNotFound,
+			wantStatus:   Failure,
+			wantCommands: []string{"stop-db", "rsync", "alert", "start-db", "notify"},
+		},
+		{
+			name:         "AfterFails",
+			failing:      []string{"start-db"},
+			wantStatus:   Success,
+			wantCommands: []string{"stop-db", "rsync", "start-db"},
+			wantOutput:   `Warning: hook failed: after hook "start-db": exit status 1`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockExec := NewMockExec(t)
+
+			var buf bytes.Buffer
+
+			cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
+
+			var calls []hookCall
+
+			expectHooks(mockExec, &calls, test.failing...)
+			mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+				RunAndReturn(func(context.Context, LineHandler, string, ...string) error {
+					calls = append(calls, hookCall{command: "rsync"})
+
+					return test.rsyncErr
+				}).Maybe()
+
+			result := cmd.Run(t.Context(), hookedJob())
+			cmd.ReportJobStatus("test-job", result, testutil.NewTestLogger(&bytes.Buffer{}))
+
+			assert.Equal(t, test.wantStatus, result.Status)
+			assert.Equal(t, test.wantCommands, commands(calls))
+			assert.Contains(t, buf.String(), test.wantOutput)
+
+			if test.wantStatus != Success {
+				assert.Contains(t, calls[len(calls)-1].env, "BACKUP_STATUS="+string(test.wantStatus))
+			}
+		})
+	}
+}
+
+func TestSimulateCommand_Run_OnlySafeHooks(t *testing.T) {
+	mockExec := NewMockExec(t)
+
+	var buf bytes.Buffer
+
+	cmd := NewSimulateCommand(rsyncPath, t.TempDir(), mockExec, &buf)
+
+	var calls []hookCall
+
+	expectHooks(mockExec, &calls)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(isDryRun)).
+		Return(nil).Once()
+
+	result := cmd.Run(t.Context(), hookedJob())
+
+	assert.Equal(t, Success, result.Status)
+	assert.Equal(t, []string{"notify"}, commands(calls))
+	assert.Contains(t, calls[0].env, "BACKUP_SIMULATE=true")
+	assert.Contains(t, buf.String(), "Hook [before] not run in simulate: stop-db")
+}
+
+func TestListCommand_Run_PrintsHooks(t *testing.T) {
+	var buf bytes.Buffer
+
+	cmd := NewListCommand(rsyncPath, NewMockExec(t), &buf)
+
+	result := cmd.Run(t.Context(), hookedJob())
+
+	assert.Equal(t, Success, result.Status)
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, []string{
+		"Hook [before]: stop-db",
+		"Hook [after]: start-db",
+		"Hook [after]: notify (safe)",
+		"Hook [on_failure]: alert",
+		"",
+	}, lines[2:])
+}
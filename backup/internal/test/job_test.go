@@ -3,9 +3,12 @@ package internal_test
 import (
 	. "backup-rsync/backup/internal"
 	"backup-rsync/backup/internal/testutil"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
@@ -45,16 +48,46 @@ func TestApply(t *testing.T) {
 			job := testutil.NewTestJob(testutil.WithEnabled(test.enabled))
 
 			if test.expectRun {
-				mockJobCommand.EXPECT().Run(job).Return(test.mockReturn).Once()
+				mockJobCommand.EXPECT().Run(mock.Anything, job).Return(JobResult{Status: test.mockReturn}).Once()
 			}
 
-			status := job.Apply(mockJobCommand)
+			result := job.Apply(t.Context(), mockJobCommand)
 
-			assert.Equal(t, test.wantStatus, status)
+			assert.Equal(t, test.wantStatus, result.Status)
 		})
 	}
 }
 
+func TestApply_CancelledContext_ReturnsCancelled(t *testing.T) {
+	mockJobCommand := NewMockJobCommand(t)
+	job := testutil.NewTestJob()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	result := job.Apply(ctx, mockJobCommand)
+
+	assert.Equal(t, Cancelled, result.Status)
+}
+
+func TestApply_Timeout_SetsDeadline(t *testing.T) {
+	mockJobCommand := NewMockJobCommand(t)
+	job := testutil.NewTestJob()
+	job.Timeout = time.Hour
+
+	mockJobCommand.EXPECT().Run(mock.Anything, job).RunAndReturn(func(ctx context.Context, _ Job) JobResult {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+
+		return JobResult{Status: Success}
+	}).Once()
+
+	result := job.Apply(t.Context(), mockJobCommand)
+
+	assert.Equal(t, Success, result.Status)
+}
+
 func TestUnmarshalYAML_InvalidNode(t *testing.T) {
 	// A scalar node cannot be decoded into the JobYAML struct
 	node := &yaml.Node{
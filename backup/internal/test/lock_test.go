@@ -0,0 +1,134 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.lock")
+	info := NewLockInfo("run", "/etc/backup/sync.yaml")
+
+	lock, err := AcquireLock(t.Context(), path, info, false, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Nil(t, lock.Stale)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var recorded LockInfo
+
+	require.NoError(t, json.Unmarshal(content, &recorded))
+	assert.Equal(t, os.Getpid(), recorded.PID)
+	assert.Equal(t, "/etc/backup/sync.yaml", recorded.ConfigPath)
+
+	_, err = AcquireLock(t.Context(), path, NewLockInfo("run", "/etc/backup/sync.yaml"), false, &bytes.Buffer{})
+	require.ErrorIs(t, err, ErrLocked)
+	assert.Contains(t, err.Error(), "is held by "+recorded.String())
+
+	require.NoError(t, lock.Release())
+	assert.NoFileExists(t, path)
+
+	lock, err = AcquireLock(t.Context(), path, info, false, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
+
+func TestAcquireLock_Stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.lock")
+	crashed := LockInfo{PID: 4242, Command: "run", ConfigPath: "sync.yaml", StartTime: time.Now().Add(-time.Hour)}
+
+	content, err := json.Marshal(crashed)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	lock, err := AcquireLock(t.Context(), path, NewLockInfo("run", "sync.yaml"), false, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { lock.Release() })
+
+	require.NotNil(t, lock.Stale)
+	assert.Equal(t, 4242, lock.Stale.PID)
+}
+
+func TestAcquireLock_Wait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.lock")
+
+	held, err := AcquireLock(t.Context(), path, NewLockInfo("run", "sync.yaml"), false, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		held.Release()
+	}()
+
+	var out bytes.Buffer
+
+	lock, err := AcquireLock(t.Context(), path, NewLockInfo("run", "sync.yaml"), true, &out)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+	assert.Contains(t, out.String(), "Waiting for lock "+path+" held by PID ")
+}
+
+func TestAcquireLock_WaitCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.lock")
+
+	held, err := AcquireLock(t.Context(), path, NewLockInfo("run", "sync.yaml"), false, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { held.Release() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = AcquireLock(ctx, path, NewLockInfo("run", "sync.yaml"), true, &bytes.Buffer{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAcquireLocks_ReleasesOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "a.lock"), filepath.Join(dir, "b.lock")
+
+	held, err := AcquireLock(t.Context(), second, NewLockInfo("run", "other.yaml"), false, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { held.Release() })
+
+	_, err = AcquireLocks(t.Context(), []string{first, second}, NewLockInfo("run", "sync.yaml"), false, &bytes.Buffer{})
+	require.ErrorIs(t, err, ErrLocked)
+	assert.NoFileExists(t, first)
+}
+
+func TestConfig_LockPaths(t *testing.T) {
+	cfg := Config{
+		LockDir: "/run/backup",
+		Mappings: []Mapping{{Jobs: []Job{
+			{Name: "photos", Target: "/mnt/backup2/photos", Enabled: true},
+			{Name: "docs", Target: "/mnt/backup1/docs/", Enabled: true},
+			{Name: "old", Target: "/mnt/backup3/old", Enabled: false},
+			{Name: "remote", Target: "/srv/docs", Enabled: true, TargetRemote: &Remote{Host: "nas"}},
+		}}},
+	}
+
+	assert.Equal(t, []string{"/run/backup/backup-config-etc_backup_sync.yaml.lock"},
+		cfg.LockPaths("/etc/backup/sync.yaml"))
+
+	cfg.LockTargets = true
+
+	assert.Equal(t, []string{
+		"/run/backup/backup-config-etc_backup_sync.yaml.lock",
+		"/run/backup/backup-target-mnt_backup1_docs.lock",
+		"/run/backup/backup-target-mnt_backup2_photos.lock",
+		"/run/backup/backup-target-nas__srv_docs.lock",
+	}, cfg.LockPaths("/etc/backup/sync.yaml"))
+}
@@ -274,5 +274,5 @@ func TestLoadResolvedConfigWithMacros_Error(t *testing.T) {
 
 	_, err := LoadResolvedConfig(path)
 	require.Error(t, err)
-	assert.Contains(t, err.Error(), "config resolution failed")
+	assert.Contains(t, err.Error(), "mapping 'm' target: resolving")
 }
@@ -0,0 +1,215 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// SHA-256 hashes of the file contents used below.
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	worldSHA256 = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+var manifestTime = time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+
+func writeTargetFile(t *testing.T, fs afero.Fs, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, fs.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, afero.WriteFile(fs, path, []byte(content), 0644))
+	require.NoError(t, fs.Chtimes(path, modTime, modTime))
+}
+
+func manifestTarget(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	writeTargetFile(t, fs, "/backup/docs/a.txt", "hello", manifestTime)
+	writeTargetFile(t, fs, "/backup/docs/sub/b.txt", "world", manifestTime)
+
+	return fs
+}
+
+func TestManifestStore_BuildAndWrite(t *testing.T) {
+	fs := manifestTarget(t)
+	store := ManifestStore{Fs: fs, Workers: 2}
+
+	manifest, hashed, err := store.Build(t.Context(), "docs", "/backup/docs", Manifest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, hashed)
+	assert.Equal(t, "docs", manifest.Job)
+	assert.Equal(t, []ManifestEntry{
+		{Path: "a.txt", Size: 5, ModTime: manifestTime, Mode: "0644", SHA256: helloSHA256},
+		{Path: "sub/b.txt", Size: 5, ModTime: manifestTime, Mode: "0644", SHA256: worldSHA256},
+	}, manifest.Files)
+
+	require.NoError(t, store.Write("/backup/docs", manifest))
+
+	read, err := store.Read("/backup/docs")
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Files, read.Files)
+
+	rebuilt, _, err := store.Build(t.Context(), "docs", "/backup/docs", read)
+	require.NoError(t, err)
+	assert.Len(t, rebuilt.Files, 2, "the manifest does not list itself")
+
+	files, err := afero.ReadDir(fs, "/backup/docs")
+	require.NoError(t, err)
+	assert.Len(t, files, 3, "the temporary file is renamed")
+}
+
+func TestManifestStore_BuildIncremental(t *testing.T) {
+	fs := manifestTarget(t)
+	store := ManifestStore{Fs: fs}
+
+	previous, _, err := store.Build(t.Context(), "docs", "/backup/docs", Manifest{})
+	require.NoError(t, err)
+
+	// A recorded hash is trusted while size and modification time match.
+	previous.Files[0].SHA256 = "trusted"
+
+	writeTargetFile(t, fs, "/backup/docs/sub/b.txt", "world!", manifestTime)
+	writeTargetFile(t, fs, "/backup/docs/c.txt", "hello", manifestTime)
+
+	manifest, hashed, err := store.Build(t.Context(), "docs", "/backup/docs", previous)
+	require.NoError(t, err)
+	assert.Equal(t, 2, hashed)
+	require.Len(t, manifest.Files, 3)
+	assert.Equal(t, "trusted", manifest.Files[0].SHA256)
+	assert.Equal(t, helloSHA256, manifest.Files[1].SHA256)
+	assert.NotEqual(t, worldSHA256, manifest.Files[2].SHA256)
+}
+
+func TestManifestStore_BuildNegativeWorkers(t *testing.T) {
+	manifest, hashed, err := ManifestStore{Fs: manifestTarget(t), Workers: -1}.
+		Build(t.Context(), "docs", "/backup/docs", Manifest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, hashed)
+	assert.Equal(t, helloSHA256, manifest.Files[0].SHA256)
+}
+
+func TestManifestStore_BuildCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, _, err := ManifestStore{Fs: manifestTarget(t), Workers: 1}.Build(ctx, "docs", "/backup/docs", Manifest{})
+
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestManifestStore_ReadMissing(t *testing.T) {
+	_, err := ManifestStore{Fs: afero.NewMemMapFs()}.Read("/backup/docs")
+
+	require.ErrorIs(t, err, ErrNoManifest)
+}
+
+func TestManifestStore_Check(t *testing.T) {
+	fs := manifestTarget(t)
+	writeTargetFile(t, fs, "/backup/docs/c.txt", "gone", manifestTime)
+	writeTargetFile(t, fs, "/backup/docs/d.txt", "edit", manifestTime)
+
+	store := ManifestStore{Fs: fs}
+
+	manifest, _, err := store.Build(t.Context(), "docs", "/backup/docs", Manifest{})
+	require.NoError(t, err)
+
+	check, err := store.Check(t.Context(), "/backup/docs", manifest)
+	require.NoError(t, err)
+	assert.True(t, check.OK())
+	assert.Equal(t, 4, check.Files)
+
+	writeTargetFile(t, fs, "/backup/docs/a.txt", "jello", manifestTime)
+	writeTargetFile(t, fs, "/backup/docs/d.txt", "edited", manifestTime.Add(time.Hour))
+	writeTargetFile(t, fs, "/backup/docs/e.txt", "new", manifestTime)
+	writeTargetFile(t, fs, "/backup/docs/sub/b.txt", "world", manifestTime.Add(time.Hour))
+	require.NoError(t, fs.Remove("/backup/docs/c.txt"))
+
+	check, err = store.Check(t.Context(), "/backup/docs", manifest)
+	require.NoError(t, err)
+	assert.False(t, check.OK())
+	assert.Equal(t, ManifestCheck{
+		Files:     4,
+		Corrupted: []string{"a.txt"},
+		Modified:  []string{"d.txt"},
+		Missing:   []string{"c.txt"},
+		Extra:     []string{"e.txt"},
+	}, check)
+	assert.Equal(t, 4, check.Differences())
+}
+
+func TestArgumentsForJob_Manifest(t *testing.T) {
+	job := Job{Source: "/home/", Target: "/backup", Delete: true, Manifest: true, Exclusions: []string{"*.json"}}
+
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "--delete", "--filter=protect /.backup-manifest.json", "--exclude=*.json",
+		"/home/", "/backup",
+	}, ArgumentsForJob(job, "", false))
+}
+
+func TestSyncCommand_Run_Manifest(t *testing.T) {
+	fs := manifestTarget(t)
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.Anything).Return(nil).Once()
+
+	var buf bytes.Buffer
+
+	command := NewSyncCommand(rsyncPath, t.TempDir(), mockExec, &buf)
+	command.Manifests = ManifestStore{Fs: fs}
+
+	job := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Enabled: true, Manifest: true}
+
+	result := command.Run(t.Context(), job)
+
+	require.Equal(t, Success, result.Status)
+	assert.Contains(t, buf.String(), "Manifest: /backup/docs/.backup-manifest.json (2 files, 2 hashed)\n")
+
+	manifest, err := command.Manifests.Read("/backup/docs")
+	require.NoError(t, err)
+	assert.Len(t, manifest.Files, 2)
+}
+
+func TestSyncCommand_Run_ManifestFailureWarns(t *testing.T) {
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.Anything).Return(nil).Once()
+
+	var buf bytes.Buffer
+
+	command := NewSyncCommand(rsyncPath, t.TempDir(), mockExec, &buf)
+	command.Manifests = ManifestStore{Fs: afero.NewMemMapFs()}
+
+	result := command.Run(t.Context(), testutil.NewTestJob(func(job *Job) { job.Manifest = true }))
+
+	assert.Equal(t, Success, result.Status)
+	assert.Contains(t, buf.String(), "Warning: Failed to write the manifest of job test-job")
+}
+
+func TestLoadResolvedConfig_ManifestOfRemoteTarget(t *testing.T) {
+	path := testutil.WriteConfigFile(t, "mappings:\n  - name: m\n    source: /srv\n    target: nas:/backup\n"+
+		"    jobs:\n      - name: docs\n        source: docs\n        target: docs\n        manifest: true\n")
+
+	_, err := LoadResolvedConfig(path)
+
+	require.ErrorIs(t, err, ErrInvalidRemote)
+}
+
+func TestRestoreCommand_ArgumentsManifest(t *testing.T) {
+	job := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Manifest: true}
+
+	args, err := NewRestoreCommand(rsyncPath, nil, &bytes.Buffer{}, RestoreOptions{}).Arguments(job)
+
+	require.NoError(t, err)
+	assert.Contains(t, args, "--exclude=/.backup-manifest.json")
+}
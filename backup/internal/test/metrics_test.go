@@ -0,0 +1,190 @@
+package internal_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricsReport() *RunReport {
+	start := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+
+	return &RunReport{
+		Command:    "run",
+		ConfigPath: "/etc/backup/sync.yaml",
+		StartTime:  start,
+		EndTime:    start.Add(762500 * time.Millisecond),
+		Summary:    map[JobStatus]int{Success: 1, Failure: 1},
+		Jobs: []JobReport{
+			{Name: "docs", Status: Success, DurationSeconds: 412.3,
+				Stats: &TransferStats{RegularFilesTransferred: 45, TotalTransferredSize: 3145728}},
+			{Name: "music", Status: Failure, DurationSeconds: 349.8, ExitCode: 23},
+		},
+	}
+}
+
+func metricsConfig() Config {
+	return Config{Mappings: []Mapping{{Name: "home", Jobs: []Job{{Name: "docs"}, {Name: "music"}}}}}
+}
+
+func TestMetrics_Write(t *testing.T) {
+	lastMusic := time.Date(2026, 10, 14, 3, 0, 0, 0, time.UTC)
+	entries := []HistoryEntry{
+		{Time: lastMusic.Add(-24 * time.Hour), Job: "music", Status: Success},
+		{Time: lastMusic, Job: "music", Status: Warning},
+		{Time: lastMusic.Add(24 * time.Hour), Job: "music", Status: Failure},
+		{Time: time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC), Job: "docs", Status: Success},
+	}
+
+	var out bytes.Buffer
+
+	err := NewMetrics(metricsReport(), metricsConfig(), entries).Write(&out)
+	require.NoError(t, err)
+
+	labels := `config="/etc/backup/sync.yaml",mode="run",mapping="home",job=`
+	config := `config="/etc/backup/sync.yaml",mode="run"`
+
+	assert.Equal(t, `# HELP backup_job_last_run_timestamp_seconds Start time of the last run of the job.
+# TYPE backup_job_last_run_timestamp_seconds gauge
+backup_job_last_run_timestamp_seconds{`+labels+`"docs"} 1792206000
+backup_job_last_run_timestamp_seconds{`+labels+`"music"} 1792206000
+# HELP backup_job_last_success_timestamp_seconds Start time of the last run the job succeeded in.
+# TYPE backup_job_last_success_timestamp_seconds gauge
+backup_job_last_success_timestamp_seconds{`+labels+`"docs"} 1792206000
+backup_job_last_success_timestamp_seconds{`+labels+`"music"} 1791946800
+# HELP backup_job_duration_seconds Duration of the last run of the job.
+# TYPE backup_job_duration_seconds gauge
+backup_job_duration_seconds{`+labels+`"docs"} 412.3
+backup_job_duration_seconds{`+labels+`"music"} 349.8
+# HELP backup_job_exit_code rsync exit code of the last run of the job.
+# TYPE backup_job_exit_code gauge
+backup_job_exit_code{`+labels+`"docs"} 0
+backup_job_exit_code{`+labels+`"music"} 23
+# HELP backup_job_transferred_bytes Bytes transferred by the last run of the job.
+# TYPE backup_job_transferred_bytes gauge
+backup_job_transferred_bytes{`+labels+`"docs"} 3145728
+# HELP backup_job_transferred_files Regular files transferred by the last run of the job.
+# TYPE backup_job_transferred_files gauge
+backup_job_transferred_files{`+labels+`"docs"} 45
+# HELP backup_run_timestamp_seconds Start time of the last run.
+# TYPE backup_run_timestamp_seconds gauge
+backup_run_timestamp_seconds{`+config+`} 1792206000
+# HELP backup_run_duration_seconds Duration of the last run.
+# TYPE backup_run_duration_seconds gauge
+backup_run_duration_seconds{`+config+`} 762.5
+# HELP backup_run_jobs Number of jobs of the last run, by status.
+# TYPE backup_run_jobs gauge
+backup_run_jobs{`+config+`,status="SUCCESS"} 1
+backup_run_jobs{`+config+`,status="FAILURE"} 1
+backup_run_jobs{`+config+`,status="SKIPPED"} 0
+backup_run_jobs{`+config+`,status="TIMEOUT"} 0
+backup_run_jobs{`+config+`,status="CANCELLED"} 0
+backup_run_jobs{`+config+`,status="WARNING"} 0
+backup_run_jobs{`+config+`,status="REFUSED"} 0
+backup_run_jobs{`+config+`,status="MISMATCH"} 0
+`, out.String())
+}
+
+func TestMetrics_EscapesLabels(t *testing.T) {
+	report := NewRunReport("run", `C:\backup "nightly".yaml`)
+
+	var out bytes.Buffer
+
+	err := NewMetrics(report, Config{}, nil).Write(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `backup_run_timestamp_seconds{config="C:\\backup \"nightly\".yaml",mode="run"}`)
+}
+
+func TestMetrics_WriteFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/var/lib/node_exporter", 0o755))
+	require.NoError(t, afero.WriteFile(fs, "/var/lib/node_exporter/backup.prom", []byte("stale"), 0o644))
+
+	err := NewMetrics(metricsReport(), metricsConfig(), nil).WriteFile(fs, "/var/lib/node_exporter/backup.prom")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "/var/lib/node_exporter/backup.prom")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `backup_job_exit_code{config="/etc/backup/sync.yaml",mode="run",mapping="home",job="music"} 23`)
+	assert.NotContains(t, string(content), "backup_job_last_success_timestamp_seconds")
+
+	files, err := afero.ReadDir(fs, "/var/lib/node_exporter")
+	require.NoError(t, err)
+	require.Len(t, files, 1, "the temporary file is renamed")
+	assert.Equal(t, "backup.prom", files[0].Name())
+	assert.Equal(t, LogFilePermission, int(files[0].Mode().Perm()))
+}
+
+func TestMetrics_WriteFileError(t *testing.T) {
+	fs := afero.NewReadOnlyFs(afero.NewMemMapFs())
+
+	err := NewMetrics(metricsReport(), metricsConfig(), nil).WriteFile(fs, "/metrics/backup.prom")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "creating metrics file")
+}
+
+func TestMetrics_OmitsJobsThatDidNotRun(t *testing.T) {
+	report := metricsReport()
+	report.Jobs[1] = JobReport{Name: "music", Status: Skipped}
+
+	var out bytes.Buffer
+
+	err := NewMetrics(report, metricsConfig(), nil).Write(&out)
+	require.NoError(t, err)
+	assert.NotContains(t, out.String(), `job="music"`)
+	assert.Contains(t, out.String(), `backup_run_jobs{config="/etc/backup/sync.yaml",mode="run",status="SKIPPED"} 0`)
+}
+
+func TestMetrics_WriteFileKeepsJobsThatDidNotRun(t *testing.T) {
+	labels := `config="/etc/backup/sync.yaml",mode="run",mapping="home",job=`
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/metrics/backup.prom", []byte(`# HELP backup_job_exit_code rsync exit code.
+# TYPE backup_job_exit_code gauge
+backup_job_exit_code{`+labels+`"music"} 12
+backup_job_exit_code{`+labels+`"photos"} 23
+backup_job_transferred_bytes{`+labels+`"music"} 1024
+backup_job_transferred_bytes{`+labels+`"photos"} 2048
+backup_run_duration_seconds{config="/etc/backup/sync.yaml",mode="run"} 1
+`), 0o644))
+
+	report := metricsReport()
+	report.Jobs = append(report.Jobs, JobReport{Name: "photos", Status: Cancelled})
+
+	err := NewMetrics(report, metricsConfig(), nil).WriteFile(fs, "/metrics/backup.prom")
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(fs, "/metrics/backup.prom")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `backup_job_exit_code{`+labels+`"music"} 23
+backup_job_exit_code{`+labels+`"photos"} 23
+`)
+	assert.Contains(t, string(content), `backup_job_transferred_bytes{`+labels+`"photos"} 2048`)
+	assert.NotContains(t, string(content), `backup_job_transferred_bytes{`+labels+`"music"}`)
+	assert.Contains(t, string(content), `backup_run_duration_seconds{config="/etc/backup/sync.yaml",mode="run"} 762.5`)
+	assert.NotContains(t, string(content), `backup_run_duration_seconds{config="/etc/backup/sync.yaml",mode="run"} 1`)
+}
+
+func TestMetrics_WriteFileKeepsRunsApartFromSimulations(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, NewMetrics(metricsReport(), metricsConfig(), nil).WriteFile(fs, "/metrics/backup.prom"))
+
+	simulation := metricsReport()
+	simulation.Command = "simulate"
+	simulation.Jobs[1].ExitCode = 0
+
+	require.NoError(t, NewMetrics(simulation, metricsConfig(), nil).WriteFile(fs, "/metrics/backup.prom"))
+
+	content, err := afero.ReadFile(fs, "/metrics/backup.prom")
+	require.NoError(t, err)
+	assert.Contains(t, string(content),
+		`backup_job_exit_code{config="/etc/backup/sync.yaml",mode="simulate",mapping="home",job="music"} 0`)
+	assert.Contains(t, string(content),
+		`backup_job_exit_code{config="/etc/backup/sync.yaml",mode="run",mapping="home",job="music"} 23`)
+}
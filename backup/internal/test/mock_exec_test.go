@@ -5,6 +5,9 @@
 package internal_test
 
 import (
+	"backup-rsync/backup/internal"
+	"context"
+
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -36,12 +39,12 @@ func (_m *MockExec) EXPECT() *MockExec_Expecter {
 }
 
 // Execute provides a mock function for the type MockExec
-func (_mock *MockExec) Execute(name string, args ...string) ([]byte, error) {
+func (_mock *MockExec) Execute(ctx context.Context, name string, args ...string) ([]byte, error) {
 	var tmpRet mock.Arguments
 	if len(args) > 0 {
-		tmpRet = _mock.Called(name, args)
+		tmpRet = _mock.Called(ctx, name, args)
 	} else {
-		tmpRet = _mock.Called(name)
+		tmpRet = _mock.Called(ctx, name)
 	}
 	ret := tmpRet
 
@@ -51,18 +54,18 @@ func (_mock *MockExec) Execute(name string, args ...string) ([]byte, error) {
 
 	var r0 []byte
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(string, ...string) ([]byte, error)); ok {
-		return returnFunc(name, args...)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, ...string) ([]byte, error)); ok {
+		return returnFunc(ctx, name, args...)
 	}
-	if returnFunc, ok := ret.Get(0).(func(string, ...string) []byte); ok {
-		r0 = returnFunc(name, args...)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, ...string) []byte); ok {
+		r0 = returnFunc(ctx, name, args...)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]byte)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func(string, ...string) error); ok {
-		r1 = returnFunc(name, args...)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, ...string) error); ok {
+		r1 = returnFunc(ctx, name, args...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -75,28 +78,34 @@ type MockExec_Execute_Call struct {
 }
 
 // Execute is a helper method to define mock.On call
+//   - ctx context.Context
 //   - name string
 //   - args ...string
-func (_e *MockExec_Expecter) Execute(name interface{}, args ...interface{}) *MockExec_Execute_Call {
+func (_e *MockExec_Expecter) Execute(ctx interface{}, name interface{}, args ...interface{}) *MockExec_Execute_Call {
 	return &MockExec_Execute_Call{Call: _e.mock.On("Execute",
-		append([]interface{}{name}, args...)...)}
+		append([]interface{}{ctx, name}, args...)...)}
 }
 
-func (_c *MockExec_Execute_Call) Run(run func(name string, args ...string)) *MockExec_Execute_Call {
+func (_c *MockExec_Execute_Call) Run(run func(ctx context.Context, name string, args ...string)) *MockExec_Execute_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 string
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(string)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
 		}
-		var arg1 []string
+		var arg2 []string
 		var variadicArgs []string
-		if len(args) > 1 {
-			variadicArgs = args[1].([]string)
+		if len(args) > 2 {
+			variadicArgs = args[2].([]string)
 		}
-		arg1 = variadicArgs
+		arg2 = variadicArgs
 		run(
 			arg0,
-			arg1...,
+			arg1,
+			arg2...,
 		)
 	})
 	return _c
@@ -107,7 +116,85 @@ func (_c *MockExec_Execute_Call) Return(bytes []byte, err error) *MockExec_Execu
 	return _c
 }
 
-func (_c *MockExec_Execute_Call) RunAndReturn(run func(name string, args ...string) ([]byte, error)) *MockExec_Execute_Call {
+func (_c *MockExec_Execute_Call) RunAndReturn(run func(ctx context.Context, name string, args ...string) ([]byte, error)) *MockExec_Execute_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Stream provides a mock function for the type MockExec
+func (_mock *MockExec) Stream(ctx context.Context, handler internal.LineHandler, name string, args ...string) error {
+	var tmpRet mock.Arguments
+	if len(args) > 0 {
+		tmpRet = _mock.Called(ctx, handler, name, args)
+	} else {
+		tmpRet = _mock.Called(ctx, handler, name)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stream")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, internal.LineHandler, string, ...string) error); ok {
+		r0 = returnFunc(ctx, handler, name, args...)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockExec_Stream_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Stream'
+type MockExec_Stream_Call struct {
+	*mock.Call
+}
+
+// Stream is a helper method to define mock.On call
+//   - ctx context.Context
+//   - handler internal.LineHandler
+//   - name string
+//   - args ...string
+func (_e *MockExec_Expecter) Stream(ctx interface{}, handler interface{}, name interface{}, args ...interface{}) *MockExec_Stream_Call {
+	return &MockExec_Stream_Call{Call: _e.mock.On("Stream",
+		append([]interface{}{ctx, handler, name}, args...)...)}
+}
+
+func (_c *MockExec_Stream_Call) Run(run func(ctx context.Context, handler internal.LineHandler, name string, args ...string)) *MockExec_Stream_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 internal.LineHandler
+		if args[1] != nil {
+			arg1 = args[1].(internal.LineHandler)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		var arg3 []string
+		var variadicArgs []string
+		if len(args) > 3 {
+			variadicArgs = args[3].([]string)
+		}
+		arg3 = variadicArgs
+		run(
+			arg0,
+			arg1,
+			arg2,
+			arg3...,
+		)
+	})
+	return _c
+}
+
+func (_c *MockExec_Stream_Call) Return(err error) *MockExec_Stream_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockExec_Stream_Call) RunAndReturn(run func(ctx context.Context, handler internal.LineHandler, name string, args ...string) error) *MockExec_Stream_Call {
 	_c.Call.Return(run)
 	return _c
 }
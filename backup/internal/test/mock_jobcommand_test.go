@@ -6,6 +6,7 @@ package internal_test
 
 import (
 	"backup-rsync/backup/internal"
+	"context"
 	"log/slog"
 
 	mock "github.com/stretchr/testify/mock"
@@ -97,9 +98,60 @@ func (_c *MockJobCommand_GetVersionInfo_Call) RunAndReturn(run func() (string, s
 	return _c
 }
 
+// JobLogPath provides a mock function for the type MockJobCommand
+func (_mock *MockJobCommand) JobLogPath(job internal.Job) string {
+	ret := _mock.Called(job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for JobLogPath")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func(internal.Job) string); ok {
+		r0 = returnFunc(job)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockJobCommand_JobLogPath_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'JobLogPath'
+type MockJobCommand_JobLogPath_Call struct {
+	*mock.Call
+}
+
+// JobLogPath is a helper method to define mock.On call
+//   - job internal.Job
+func (_e *MockJobCommand_Expecter) JobLogPath(job interface{}) *MockJobCommand_JobLogPath_Call {
+	return &MockJobCommand_JobLogPath_Call{Call: _e.mock.On("JobLogPath", job)}
+}
+
+func (_c *MockJobCommand_JobLogPath_Call) Run(run func(job internal.Job)) *MockJobCommand_JobLogPath_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 internal.Job
+		if args[0] != nil {
+			arg0 = args[0].(internal.Job)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockJobCommand_JobLogPath_Call) Return(s string) *MockJobCommand_JobLogPath_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockJobCommand_JobLogPath_Call) RunAndReturn(run func(job internal.Job) string) *MockJobCommand_JobLogPath_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // ReportJobStatus provides a mock function for the type MockJobCommand
-func (_mock *MockJobCommand) ReportJobStatus(jobName string, status internal.JobStatus, logger *slog.Logger) {
-	_mock.Called(jobName, status, logger)
+func (_mock *MockJobCommand) ReportJobStatus(jobName string, result internal.JobResult, logger *slog.Logger) {
+	_mock.Called(jobName, result, logger)
 	return
 }
 
@@ -110,21 +162,21 @@ type MockJobCommand_ReportJobStatus_Call struct {
 
 // ReportJobStatus is a helper method to define mock.On call
 //   - jobName string
-//   - status internal.JobStatus
+//   - result internal.JobResult
 //   - logger *slog.Logger
-func (_e *MockJobCommand_Expecter) ReportJobStatus(jobName interface{}, status interface{}, logger interface{}) *MockJobCommand_ReportJobStatus_Call {
-	return &MockJobCommand_ReportJobStatus_Call{Call: _e.mock.On("ReportJobStatus", jobName, status, logger)}
+func (_e *MockJobCommand_Expecter) ReportJobStatus(jobName interface{}, result interface{}, logger interface{}) *MockJobCommand_ReportJobStatus_Call {
+	return &MockJobCommand_ReportJobStatus_Call{Call: _e.mock.On("ReportJobStatus", jobName, result, logger)}
 }
 
-func (_c *MockJobCommand_ReportJobStatus_Call) Run(run func(jobName string, status internal.JobStatus, logger *slog.Logger)) *MockJobCommand_ReportJobStatus_Call {
+func (_c *MockJobCommand_ReportJobStatus_Call) Run(run func(jobName string, result internal.JobResult, logger *slog.Logger)) *MockJobCommand_ReportJobStatus_Call {
 	_c.Call.Run(func(args mock.Arguments) {
 		var arg0 string
 		if args[0] != nil {
 			arg0 = args[0].(string)
 		}
-		var arg1 internal.JobStatus
+		var arg1 internal.JobResult
 		if args[1] != nil {
-			arg1 = args[1].(internal.JobStatus)
+			arg1 = args[1].(internal.JobResult)
 		}
 		var arg2 *slog.Logger
 		if args[2] != nil {
@@ -144,14 +196,14 @@ func (_c *MockJobCommand_ReportJobStatus_Call) Return() *MockJobCommand_ReportJo
 	return _c
 }
 
-func (_c *MockJobCommand_ReportJobStatus_Call) RunAndReturn(run func(jobName string, status internal.JobStatus, logger *slog.Logger)) *MockJobCommand_ReportJobStatus_Call {
+func (_c *MockJobCommand_ReportJobStatus_Call) RunAndReturn(run func(jobName string, result internal.JobResult, logger *slog.Logger)) *MockJobCommand_ReportJobStatus_Call {
 	_c.Run(run)
 	return _c
 }
 
 // ReportSummary provides a mock function for the type MockJobCommand
-func (_mock *MockJobCommand) ReportSummary(counts map[internal.JobStatus]int, logger *slog.Logger) {
-	_mock.Called(counts, logger)
+func (_mock *MockJobCommand) ReportSummary(summary internal.RunSummary, logger *slog.Logger) {
+	_mock.Called(summary, logger)
 	return
 }
 
@@ -161,17 +213,17 @@ type MockJobCommand_ReportSummary_Call struct {
 }
 
 // ReportSummary is a helper method to define mock.On call
-//   - counts map[internal.JobStatus]int
+//   - summary internal.RunSummary
 //   - logger *slog.Logger
-func (_e *MockJobCommand_Expecter) ReportSummary(counts interface{}, logger interface{}) *MockJobCommand_ReportSummary_Call {
-	return &MockJobCommand_ReportSummary_Call{Call: _e.mock.On("ReportSummary", counts, logger)}
+func (_e *MockJobCommand_Expecter) ReportSummary(summary interface{}, logger interface{}) *MockJobCommand_ReportSummary_Call {
+	return &MockJobCommand_ReportSummary_Call{Call: _e.mock.On("ReportSummary", summary, logger)}
 }
 
-func (_c *MockJobCommand_ReportSummary_Call) Run(run func(counts map[internal.JobStatus]int, logger *slog.Logger)) *MockJobCommand_ReportSummary_Call {
+func (_c *MockJobCommand_ReportSummary_Call) Run(run func(summary internal.RunSummary, logger *slog.Logger)) *MockJobCommand_ReportSummary_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 map[internal.JobStatus]int
+		var arg0 internal.RunSummary
 		if args[0] != nil {
-			arg0 = args[0].(map[internal.JobStatus]int)
+			arg0 = args[0].(internal.RunSummary)
 		}
 		var arg1 *slog.Logger
 		if args[1] != nil {
@@ -190,24 +242,24 @@ func (_c *MockJobCommand_ReportSummary_Call) Return() *MockJobCommand_ReportSumm
 	return _c
 }
 
-func (_c *MockJobCommand_ReportSummary_Call) RunAndReturn(run func(counts map[internal.JobStatus]int, logger *slog.Logger)) *MockJobCommand_ReportSummary_Call {
+func (_c *MockJobCommand_ReportSummary_Call) RunAndReturn(run func(summary internal.RunSummary, logger *slog.Logger)) *MockJobCommand_ReportSummary_Call {
 	_c.Run(run)
 	return _c
 }
 
 // Run provides a mock function for the type MockJobCommand
-func (_mock *MockJobCommand) Run(job internal.Job) internal.JobStatus {
-	ret := _mock.Called(job)
+func (_mock *MockJobCommand) Run(ctx context.Context, job internal.Job) internal.JobResult {
+	ret := _mock.Called(ctx, job)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Run")
 	}
 
-	var r0 internal.JobStatus
-	if returnFunc, ok := ret.Get(0).(func(internal.Job) internal.JobStatus); ok {
-		r0 = returnFunc(job)
+	var r0 internal.JobResult
+	if returnFunc, ok := ret.Get(0).(func(context.Context, internal.Job) internal.JobResult); ok {
+		r0 = returnFunc(ctx, job)
 	} else {
-		r0 = ret.Get(0).(internal.JobStatus)
+		r0 = ret.Get(0).(internal.JobResult)
 	}
 	return r0
 }
@@ -218,30 +270,36 @@ type MockJobCommand_Run_Call struct {
 }
 
 // Run is a helper method to define mock.On call
+//   - ctx context.Context
 //   - job internal.Job
-func (_e *MockJobCommand_Expecter) Run(job interface{}) *MockJobCommand_Run_Call {
-	return &MockJobCommand_Run_Call{Call: _e.mock.On("Run", job)}
+func (_e *MockJobCommand_Expecter) Run(ctx interface{}, job interface{}) *MockJobCommand_Run_Call {
+	return &MockJobCommand_Run_Call{Call: _e.mock.On("Run", ctx, job)}
 }
 
-func (_c *MockJobCommand_Run_Call) Run(run func(job internal.Job)) *MockJobCommand_Run_Call {
+func (_c *MockJobCommand_Run_Call) Run(run func(ctx context.Context, job internal.Job)) *MockJobCommand_Run_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 internal.Job
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(internal.Job)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 internal.Job
+		if args[1] != nil {
+			arg1 = args[1].(internal.Job)
 		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *MockJobCommand_Run_Call) Return(jobStatus internal.JobStatus) *MockJobCommand_Run_Call {
-	_c.Call.Return(jobStatus)
+func (_c *MockJobCommand_Run_Call) Return(jobResult internal.JobResult) *MockJobCommand_Run_Call {
+	_c.Call.Return(jobResult)
 	return _c
 }
 
-func (_c *MockJobCommand_Run_Call) RunAndReturn(run func(job internal.Job) internal.JobStatus) *MockJobCommand_Run_Call {
+func (_c *MockJobCommand_Run_Call) RunAndReturn(run func(ctx context.Context, job internal.Job) internal.JobResult) *MockJobCommand_Run_Call {
 	_c.Call.Return(run)
 	return _c
 }
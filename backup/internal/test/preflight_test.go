@@ -0,0 +1,276 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"500M", 500 << 20, false},
+		{"10GiB", 10 << 30, false},
+		{"1.5 TB", 3 << 39, false},
+		{"2kb", 2048, false},
+		{"10.0 GiB", 10 << 30, false},
+		{"", 0, true},
+		{"GiB", 0, true},
+		{"10 PB", 0, true},
+		{"-5G", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			size, err := ParseByteSize(test.value)
+
+			if test.wantErr {
+				require.ErrorIs(t, err, ErrInvalidByteSize)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, size)
+		})
+	}
+}
+
+func TestPreflight_YAML(t *testing.T) {
+	var mapping Mapping
+
+	err := yaml.Unmarshal([]byte(`
+name: disk
+preflight:
+  require_mountpoint: true
+  require_file: .backup-target-id
+  min_free_space: 10GiB
+  non_empty_source: true
+  on_failure: skip
+`), &mapping)
+	require.NoError(t, err)
+
+	assert.Equal(t, &Preflight{
+		RequireMountpoint: true,
+		RequireFile:       ".backup-target-id",
+		MinFreeSpace:      10 << 30,
+		NonEmptySource:    true,
+		OnFailure:         PreflightSkip,
+	}, mapping.Preflight)
+
+	out, err := yaml.Marshal(mapping)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "min_free_space: 10.0 GiB\n")
+
+	err = yaml.Unmarshal([]byte("preflight:\n  on_failure: ignore\n"), &mapping)
+	require.ErrorIs(t, err, ErrInvalidPreflightPolicy)
+
+	err = yaml.Unmarshal([]byte("preflight:\n  min_free_space: lots\n"), &mapping)
+	require.ErrorIs(t, err, ErrInvalidByteSize)
+}
+
+func preflightFs(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/home/docs", 0755))
+	require.NoError(t, fs.MkdirAll("/home/empty", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/home/docs/a.txt", nil, 0644))
+	require.NoError(t, afero.WriteFile(fs, "/mnt/backup/.backup-target-id", nil, 0644))
+
+	return fs
+}
+
+func TestPreflightChecker_Check(t *testing.T) {
+	tests := []struct {
+		name       string
+		mapping    *Preflight
+		job        *Preflight
+		jobSource  string
+		mounted    bool
+		free       int64
+		wantOK     bool
+		wantStatus JobStatus
+		wantReason string
+	}{
+		{name: "NoConditions", wantOK: true},
+		{name: "AllConditionsHold", mounted: true, free: 20 << 30, wantOK: true,
+			mapping: &Preflight{RequireMountpoint: true, RequireFile: ".backup-target-id", MinFreeSpace: 10 << 30},
+			job:     &Preflight{NonEmptySource: true}},
+		{name: "NotMounted", mapping: &Preflight{RequireMountpoint: true},
+			wantStatus: Failure, wantReason: "preflight: /mnt/backup is not a mount point"},
+		{name: "NotMountedSkipped", mapping: &Preflight{RequireMountpoint: true, OnFailure: PreflightSkip},
+			wantStatus: Skipped, wantReason: "preflight: /mnt/backup is not a mount point"},
+		{name: "JobPolicyOverridesMapping", mapping: &Preflight{RequireMountpoint: true, OnFailure: PreflightSkip},
+			job:        &Preflight{OnFailure: PreflightFail},
+			wantStatus: Failure, wantReason: "preflight: /mnt/backup is not a mount point"},
+		{name: "SentinelMissing", job: &Preflight{RequireFile: "id"},
+			wantStatus: Failure, wantReason: "preflight: required file /mnt/backup/docs/id not found"},
+		{name: "SentinelAbsolute", job: &Preflight{RequireFile: "/mnt/backup/.backup-target-id"}, wantOK: true},
+		{name: "LowFreeSpace", mapping: &Preflight{MinFreeSpace: 10 << 30}, free: 5 << 30,
+			wantStatus: Failure, wantReason: "preflight: 5.0 GiB free on /mnt/backup, 10.0 GiB required"},
+		{name: "EmptySource", job: &Preflight{NonEmptySource: true}, jobSource: "/home/empty",
+			wantStatus: Failure, wantReason: "preflight: source /home/empty is empty or missing"},
+		{name: "MissingSource", job: &Preflight{NonEmptySource: true}, jobSource: "/home/missing",
+			wantStatus: Failure, wantReason: "preflight: source /home/missing is empty or missing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumes := NewMockVolumeInfo(t)
+			volumes.EXPECT().IsMountPoint("/mnt/backup").Return(test.mounted, nil).Maybe()
+			volumes.EXPECT().FreeSpace(mock.Anything).Return(test.free, nil).Maybe()
+
+			checker := PreflightChecker{Fs: preflightFs(t), Volumes: volumes}
+
+			source := test.jobSource
+			if source == "" {
+				source = "/home/docs"
+			}
+
+			mapping := Mapping{Name: "m", Source: "/home", Target: "/mnt/backup", Preflight: test.mapping}
+			job := Job{Name: "docs", Source: source, Target: "/mnt/backup/docs", Enabled: true, Preflight: test.job}
+
+			result, ok := checker.Check(mapping, job)
+
+			assert.Equal(t, test.wantOK, ok)
+			assert.Equal(t, test.wantStatus, result.Status)
+			assert.Equal(t, test.wantReason, result.Reason)
+		})
+	}
+}
+
+func TestPreflightChecker_FreeSpaceOfMissingTarget(t *testing.T) {
+	volumes := NewMockVolumeInfo(t)
+	volumes.EXPECT().FreeSpace("/mnt/backup").Return(1<<30, nil).Once()
+
+	checker := PreflightChecker{Fs: preflightFs(t), Volumes: volumes}
+	job := Job{Name: "new", Target: "/mnt/backup/new/sub", Preflight: &Preflight{MinFreeSpace: 1 << 20}}
+
+	_, ok := checker.Check(Mapping{}, job)
+
+	assert.True(t, ok)
+}
+
+func TestPreflightChecker_VolumeError(t *testing.T) {
+	volumes := NewMockVolumeInfo(t)
+	volumes.EXPECT().IsMountPoint("/mnt/backup").Return(false, ErrVolumeInfoUnsupported).Once()
+
+	checker := PreflightChecker{Fs: preflightFs(t), Volumes: volumes}
+	mapping := Mapping{Target: "/mnt/backup", Preflight: &Preflight{RequireMountpoint: true}}
+
+	result, ok := checker.Check(mapping, Job{Name: "docs"})
+
+	assert.False(t, ok)
+	assert.Equal(t, Failure, result.Status)
+	assert.Contains(t, result.Reason, "checking mount point /mnt/backup")
+}
+
+func TestOsVolumeInfo(t *testing.T) {
+	var volumes OsVolumeInfo
+
+	dir := filepath.Join(t.TempDir(), "sub")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	mounted, err := volumes.IsMountPoint("/")
+	if err != nil {
+		t.Skipf("volume information unavailable: %v", err)
+	}
+
+	assert.True(t, mounted)
+
+	mounted, err = volumes.IsMountPoint(dir)
+	require.NoError(t, err)
+	assert.False(t, mounted)
+
+	free, err := volumes.FreeSpace(dir)
+	require.NoError(t, err)
+	assert.Positive(t, free)
+}
+
+func TestConfigApply_PreflightAfterBeforeHooks(t *testing.T) {
+	tests := []struct {
+		name         string
+		mount        bool
+		wantCommands []string
+		wantOutput   string
+	}{
+		{
+			name:         "BeforeHookPreparesTarget",
+			mount:        true,
+			wantCommands: []string{"mount", "rsync", "umount"},
+			wantOutput:   "Status [docs]: SUCCESS",
+		},
+		{
+			name:         "PreflightFails",
+			wantCommands: []string{"mount", "alert", "umount"},
+			wantOutput:   "Status [docs]: SKIPPED (preflight: required file /mnt/backup/ready not found)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			mockExec := NewMockExec(t)
+
+			var (
+				buf   bytes.Buffer
+				calls []string
+			)
+
+			cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
+			cfg := Config{Mappings: []Mapping{{
+				Name: "m", Target: "/mnt/backup", Preflight: &Preflight{RequireFile: "ready", OnFailure: PreflightSkip},
+				Jobs: []Job{{
+					Name: "docs", Source: "/home/docs/", Target: "/mnt/backup/docs/", Enabled: true,
+					Hooks: &Hooks{
+						Before:    []Hook{{Command: "mount"}},
+						After:     []Hook{{Command: "umount"}},
+						OnFailure: []Hook{{Command: "alert"}},
+					},
+				}},
+			}}}
+
+			mockExec.EXPECT().Execute(mock.Anything, rsyncPath, []string{RsyncVersionFlag}).
+				Return([]byte("rsync  version 3.2.7  protocol version 31\n"), nil).Once()
+			mockExec.EXPECT().Stream(mock.Anything, mock.Anything, "env", mock.Anything).
+				RunAndReturn(func(_ context.Context, _ LineHandler, _ string, args ...string) error {
+					calls = append(calls, args[len(args)-1])
+					if args[len(args)-1] == "mount" && test.mount {
+						return afero.WriteFile(fs, "/mnt/backup/ready", nil, 0o600)
+					}
+
+					return nil
+				})
+			mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+				RunAndReturn(func(context.Context, LineHandler, string, ...string) error {
+					calls = append(calls, "rsync")
+
+					return nil
+				}).Maybe()
+
+			err := cfg.Apply(t.Context(), cmd, testutil.NewTestLogger(io.Discard), ApplyOptions{
+				Preflight: &PreflightChecker{Fs: fs, Volumes: NewMockVolumeInfo(t)},
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCommands, calls)
+			assert.Contains(t, buf.String(), test.wantOutput)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRemotePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantRemote *Remote
+		wantPath   string
+	}{
+		{"backup@nas:/volume1", &Remote{Host: "nas", User: "backup"}, "/volume1"},
+		{"nas:/volume1", &Remote{Host: "nas"}, "/volume1"},
+		{"nas:backups", &Remote{Host: "nas"}, "backups"},
+		{"/home/user", nil, "/home/user"},
+		{"/home/user:data", nil, "/home/user:data"},
+		{"C:/Users", nil, "C:/Users"},
+		{"nas::module", nil, "nas::module"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			remote, path, isRemote := SplitRemotePath(test.path)
+
+			assert.Equal(t, test.wantRemote, remote)
+			assert.Equal(t, test.wantPath, path)
+			assert.Equal(t, test.wantRemote != nil, isRemote)
+		})
+	}
+}
+
+func TestRemote_Spec(t *testing.T) {
+	var local *Remote
+
+	assert.Equal(t, "/backup", local.Spec("/backup"))
+	assert.Equal(t, "nas:/backup", (&Remote{Host: "nas"}).Spec("/backup"))
+	assert.Equal(t, "backup@nas:/backup", (&Remote{Host: "nas", User: "backup"}).Spec("/backup"))
+}
+
+func TestRemote_SSHCommand(t *testing.T) {
+	assert.Equal(t, "ssh -o BatchMode=yes", (&Remote{Host: "nas"}).SSHCommand())
+	assert.Equal(t, "ssh -o BatchMode=yes -p 2222 -i '/keys/backup key'",
+		(&Remote{Host: "nas", Port: 2222, SSHKey: "/keys/backup key"}).SSHCommand())
+	assert.Equal(t, `ssh -o BatchMode=yes -i '/keys/it''s "mine"'`,
+		(&Remote{Host: "nas", SSHKey: `/keys/it's "mine"`}).SSHCommand())
+}
+
+func TestArgumentsForJob_RemoteShell(t *testing.T) {
+	job := Job{Source: "/home/", Target: "/backup", TargetRemote: &Remote{Host: "nas", Port: 2222}}
+
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "-e", "ssh -o BatchMode=yes -p 2222", "/home/", "nas:/backup",
+	}, ArgumentsForJob(job, "", false))
+}
+
+func TestLoadResolvedConfig_InlineRemote(t *testing.T) {
+	path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		Variable("nas", "nas.local").
+		AddMapping("home", "/home/user", "backup@${nas}:/volume1/user").
+		AddJobToMapping("docs", "Documents", "docs").
+		Build())
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	job := cfg.AllJobs()[0]
+	assert.Equal(t, &Remote{Host: "nas.local", User: "backup"}, job.TargetRemote)
+	assert.Equal(t, "/volume1/user/docs", job.Target)
+	assert.Equal(t, "backup@nas.local:/volume1/user/docs", job.TargetSpec())
+	assert.Equal(t, "/home/user/Documents/", job.SourceSpec())
+}
+
+func TestLoadResolvedConfig_RemoteBlock(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `
+variables:
+  key: /root/.ssh/backup
+mappings:
+  - name: web
+    source: /srv
+    target: /backup/web
+    source_remote:
+      host: web1
+      user: root
+      port: 2222
+      ssh_key: ${key}
+    jobs:
+      - name: www
+        source: www
+        target: www
+      - name: api
+        source: api
+        target: api
+        source_remote:
+          host: web2
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	jobs := cfg.AllJobs()
+	assert.Equal(t, &Remote{Host: "web1", User: "root", Port: 2222, SSHKey: "/root/.ssh/backup"}, jobs[0].SourceRemote)
+	assert.Equal(t, "root@web1:/srv/www/", jobs[0].SourceSpec())
+	assert.Equal(t, "web2:/srv/api/", jobs[1].SourceSpec())
+	assert.Contains(t, cfg.String(), "source_remote:")
+}
+
+func TestLoadResolvedConfig_InvalidRemotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping string
+		wantErr error
+	}{
+		{"BothRemote", "source: web1:/srv\n    target: nas:/backup", ErrRemoteToRemote},
+		{"InlineAndBlock", "source: /srv\n    target: nas:/backup\n    target_remote: {host: nas}", ErrInvalidRemote},
+		{"MissingHost", "source: /srv\n    target: /backup\n    target_remote: {user: backup}", ErrInvalidRemote},
+		{"InvalidPort", "source: /srv\n    target: nas:/backup\n    target_remote: {host: nas, port: 70000}",
+			ErrInvalidRemote},
+		{"SnapshotOfRemoteTarget", "source: /srv\n    target: nas:/backup\n    mode: snapshot", ErrInvalidRemote},
+		{"PreflightOfRemoteTarget", "source: /srv\n    target: nas:/backup\n    preflight: {require_mountpoint: true}",
+			ErrInvalidRemote},
+		{"PreflightOfRemoteSource", "source: web1:/srv\n    target: /backup\n    preflight: {non_empty_source: true}",
+			ErrInvalidRemote},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := testutil.WriteConfigFile(t, "mappings:\n  - name: m\n    "+test.mapping+
+				"\n    jobs:\n      - name: docs\n        source: docs\n        target: docs\n")
+
+			_, err := LoadResolvedConfig(path)
+			require.ErrorIs(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestLoadResolvedConfig_RemoteOverlap(t *testing.T) {
+	tests := []struct {
+		name        string
+		target1     string
+		target2     string
+		wantOverlap bool
+	}{
+		{"SameHostDifferentUser", "alice@nas:/backup", "bob@nas:/backup/docs", true},
+		{"DifferentHosts", "nas1:/backup", "nas2:/backup/docs", false},
+		{"LocalAndRemote", "/backup", "nas:/backup/docs", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+				AddMapping("one", "/home/one", test.target1).
+				AddJobToMapping("one", "", "").
+				AddMapping("two", "/home/two", test.target2).
+				AddJobToMapping("two", "", "").
+				Build())
+
+			_, err := LoadResolvedConfig(path)
+			if test.wantOverlap {
+				require.ErrorIs(t, err, ErrOverlappingPath)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateLocalSources(t *testing.T) {
+	cfg := Config{Mappings: []Mapping{{Name: "m", Jobs: []Job{
+		{Name: "local", Source: "/home/"},
+		{Name: "web", Source: "/srv/", SourceRemote: &Remote{Host: "web1"}},
+	}}}}
+
+	err := ValidateLocalSources(cfg)
+	require.ErrorIs(t, err, ErrRemoteSource)
+	assert.Contains(t, err.Error(), "job 'web' backs up web1:/srv/")
+
+	cfg.Mappings[0].Jobs = cfg.Mappings[0].Jobs[:1]
+	require.NoError(t, ValidateLocalSources(cfg))
+}
@@ -0,0 +1,150 @@
+package internal_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReportFormat(t *testing.T) {
+	format, err := ParseReportFormat("junit")
+	require.NoError(t, err)
+	assert.Equal(t, ReportJUnit, format)
+	assert.Equal(t, "report.xml", format.FileName())
+	assert.Equal(t, "report.json", ReportJSON.FileName())
+
+	_, err = ParseReportFormat("csv")
+	require.ErrorIs(t, err, ErrUnknownReportFormat)
+	assert.Contains(t, err.Error(), "supported: json, junit")
+}
+
+func TestConfigApply_Report(t *testing.T) {
+	mockCmd := NewMockJobCommand(t)
+	logger := testutil.NewTestLogger(&bytes.Buffer{})
+
+	cfg := Config{
+		Mappings: []Mapping{
+			{
+				Name:   "test",
+				Source: "/src",
+				Target: "/dst",
+				Jobs: []Job{
+					{Name: "job1", Source: "/src/a/", Target: "/dst/a", Enabled: true},
+					{Name: "job2", Source: "/src/b/", Target: "/dst/b", Enabled: false},
+				},
+			},
+		},
+	}
+
+	stats := &TransferStats{Files: 3, RegularFilesTransferred: 1}
+	failed := JobResult{Status: Failure, Reason: "rsync exit code 23: partial transfer due to error",
+		ExitCode: 23, Attempts: 2, Stats: stats}
+
+	mockCmd.EXPECT().GetVersionInfo().Return("rsync  version 3.2.7  protocol version 31\nCopyright", "/usr/bin/rsync", nil)
+	mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).Return(failed).Once()
+	mockCmd.EXPECT().ReportJobStatus(mock.Anything, mock.Anything, logger)
+	mockCmd.EXPECT().ReportSummary(mock.Anything, logger).Once()
+	mockCmd.EXPECT().JobLogPath(mock.AnythingOfType("internal.Job")).RunAndReturn(func(job Job) string {
+		return "/logs/job-" + job.Name + ".log"
+	})
+
+	report := NewRunReport("run", "/etc/backup.yaml")
+	before := time.Now()
+
+	err := cfg.Apply(t.Context(), mockCmd, logger, ApplyOptions{Report: report})
+
+	require.ErrorIs(t, err, ErrJobFailure)
+	assert.Equal(t, "run", report.Command)
+	assert.Equal(t, "/etc/backup.yaml", report.ConfigPath)
+	assert.Equal(t, "rsync  version 3.2.7  protocol version 31", report.RsyncVersion)
+	assert.False(t, report.StartTime.Before(before))
+	assert.False(t, report.EndTime.Before(report.StartTime))
+	assert.Equal(t, map[JobStatus]int{Failure: 1, Skipped: 1}, report.Summary)
+	assert.Equal(t, *stats, report.Totals)
+
+	require.Len(t, report.Jobs, 2)
+	assert.Equal(t, JobReport{
+		Name: "job1", Status: Failure, Reason: failed.Reason, ExitCode: 23, Attempts: 2, Stats: stats,
+		DurationSeconds: report.Jobs[0].DurationSeconds, LogPath: "/logs/job-job1.log",
+	}, report.Jobs[0])
+	assert.Equal(t, Skipped, report.Jobs[1].Status)
+}
+
+func testRunReport() *RunReport {
+	start := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+
+	return &RunReport{
+		Command:      "run",
+		ConfigPath:   "/etc/backup.yaml",
+		RsyncVersion: "rsync version 3.2.7",
+		StartTime:    start,
+		EndTime:      start.Add(90 * time.Second),
+		Summary:      map[JobStatus]int{Success: 1, Failure: 1, Skipped: 1},
+		Jobs: []JobReport{
+			{Name: "docs", Status: Success, DurationSeconds: 60, Stats: &TransferStats{Files: 2},
+				LogPath: "/logs/job-docs.log"},
+			{Name: "music", Status: Failure, Reason: "rsync exit code 11: error in file I/O", ExitCode: 11,
+				DurationSeconds: 30},
+			{Name: "photos", Status: Skipped, Reason: "preflight: /mnt is not a mount point"},
+		},
+	}
+}
+
+func TestRunReport_WriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, testRunReport().Write(&buf, ReportJSON))
+
+	output := buf.String()
+	assert.Contains(t, output, `"start_time": "2026-10-17T03:00:00Z"`)
+	assert.Contains(t, output, `"summary": {
+    "FAILURE": 1,
+    "SKIPPED": 1,
+    "SUCCESS": 1
+  }`)
+	assert.Contains(t, output, `"name": "music",
+      "status": "FAILURE",
+      "reason": "rsync exit code 11: error in file I/O",
+      "duration_seconds": 30,
+      "exit_code": 11
+    }`)
+}
+
+func TestRunReport_WriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, testRunReport().Write(&buf, ReportJUnit))
+
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="backup run" tests="3" failures="1" skipped="1" time="90.000" timestamp="2026-10-17T03:00:00Z">
+    <properties>
+      <property name="config_path" value="/etc/backup.yaml"></property>
+      <property name="rsync_version" value="rsync version 3.2.7"></property>
+    </properties>
+    <testcase name="docs" classname="backup.run" time="60.000">
+      <system-out>Log: /logs/job-docs.log</system-out>
+    </testcase>
+    <testcase name="music" classname="backup.run" time="30.000">
+      <failure message="rsync exit code 11: error in file I/O" type="FAILURE"></failure>
+    </testcase>
+    <testcase name="photos" classname="backup.run" time="0.000">
+      <skipped message="preflight: /mnt is not a mount point" type="SKIPPED"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func TestRunReport_UnknownFormat(t *testing.T) {
+	err := testRunReport().Write(&bytes.Buffer{}, "csv")
+
+	require.ErrorIs(t, err, ErrUnknownReportFormat)
+}
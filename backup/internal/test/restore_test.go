@@ -0,0 +1,185 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func restoreSnapshotFs(t *testing.T) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"2026-10-15T02-00-00", "2026-10-16T02-00-00", "2026-10-17T02-00-00"} {
+		require.NoError(t, fs.MkdirAll(filepath.Join("/backup/docs", name), 0755))
+	}
+
+	return fs
+}
+
+func TestSnapshotStore_Find(t *testing.T) {
+	store := SnapshotStore{Fs: restoreSnapshotFs(t)}
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"", "2026-10-17T02-00-00"},
+		{"2026-10-16T02-00-00", "2026-10-16T02-00-00"},
+		{"2026-10-16", "2026-10-16T02-00-00"},
+		{"2026-10-16T01:59", "2026-10-15T02-00-00"},
+		{"2026-10-17 12:00:00", "2026-10-17T02-00-00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.at, func(t *testing.T) {
+			snapshot, err := store.Find("/backup/docs", test.at)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, snapshot.Name)
+		})
+	}
+}
+
+func TestSnapshotStore_FindErrors(t *testing.T) {
+	store := SnapshotStore{Fs: restoreSnapshotFs(t)}
+
+	for _, at := range []string{"2026-10-14", "yesterday"} {
+		_, err := store.Find("/backup/docs", at)
+		require.ErrorIs(t, err, ErrNoSnapshot, at)
+	}
+
+	_, err := store.Find("/backup/music", "")
+	require.ErrorIs(t, err, ErrNoSnapshot)
+}
+
+func TestRestoreCommand_Arguments(t *testing.T) {
+	job := Job{
+		Name:       "docs",
+		Source:     "/home/docs/",
+		Target:     "/backup/docs",
+		Delete:     true,
+		Exclusions: []string{"*.tmp"},
+		Rsync:      &RsyncOptions{ExtraArgs: []string{"--delete-excluded", "--numeric-ids"}},
+	}
+
+	tests := []struct {
+		name    string
+		job     Job
+		options RestoreOptions
+		want    []string
+	}{
+		{
+			name: "dry run into the source",
+			job:  job,
+			want: []string{"--dry-run", "-aiv", "--stats", "--exclude=*.tmp", "--numeric-ids",
+				"/backup/docs/", "/home/docs/"},
+		},
+		{
+			name:    "path into another directory",
+			job:     job,
+			options: RestoreOptions{To: "/tmp/restore", Path: "reports/2026", Apply: true},
+			want: []string{"-aiv", "--stats", "--exclude=*.tmp", "--numeric-ids", "--relative",
+				"/backup/docs/./reports/2026", "/tmp/restore/"},
+		},
+		{
+			name:    "delete on request",
+			job:     job,
+			options: RestoreOptions{Delete: true, Apply: true, OverwriteSource: true},
+			want: []string{"-aiv", "--stats", "--delete", "--exclude=*.tmp", "--delete-excluded", "--numeric-ids",
+				"/backup/docs/", "/home/docs/"},
+		},
+		{
+			name:    "snapshot at a time",
+			job:     Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Mode: SnapshotMode},
+			options: RestoreOptions{At: "2026-10-16"},
+			want:    []string{"--dry-run", "-aiv", "--stats", "/backup/docs/2026-10-16T02-00-00/", "/home/docs/"},
+		},
+		{
+			name: "remote target",
+			job: Job{Name: "docs", Source: "/home/docs/", Target: "/volume1/docs",
+				TargetRemote: &Remote{Host: "nas", Port: 2222}},
+			want: []string{"--dry-run", "-aiv", "--stats", "-e", "ssh -o BatchMode=yes -p 2222",
+				"nas:/volume1/docs/", "/home/docs/"},
+		},
+		{
+			name: "remote source",
+			job: Job{Name: "docs", Source: "/srv/docs", Target: "/backup/docs",
+				SourceRemote: &Remote{Host: "web", User: "backup"}},
+			want: []string{"--dry-run", "-aiv", "--stats", "-e", "ssh -o BatchMode=yes",
+				"/backup/docs/", "backup@web:/srv/docs/"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command := NewRestoreCommand(rsyncPath, nil, &bytes.Buffer{}, test.options)
+			command.Snapshots = SnapshotStore{Fs: restoreSnapshotFs(t)}
+
+			args, err := command.Arguments(test.job)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, args)
+		})
+	}
+}
+
+func TestRestoreCommand_ArgumentsErrors(t *testing.T) {
+	job := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs"}
+
+	tests := []struct {
+		name    string
+		options RestoreOptions
+		wantErr error
+	}{
+		{"into the source", RestoreOptions{Apply: true}, ErrRestoreRefused},
+		{"below the source", RestoreOptions{To: "/home/docs/reports", Apply: true}, ErrRestoreRefused},
+		{"path outside the source", RestoreOptions{Path: "../music"}, ErrInvalidRestore},
+		{"at without snapshots", RestoreOptions{At: "2026-10-16"}, ErrInvalidRestore},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command := NewRestoreCommand(rsyncPath, nil, &bytes.Buffer{}, test.options)
+
+			_, err := command.Arguments(job)
+
+			require.ErrorIs(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestRestoreCommand_Run(t *testing.T) {
+	mockExec := NewMockExec(t)
+
+	var buf bytes.Buffer
+
+	job := testutil.NewTestJob()
+	command := NewRestoreCommand(rsyncPath, mockExec, &buf, RestoreOptions{To: "/tmp/restore"})
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines("restore output")...)).Once()
+
+	result := command.Run(t.Context(), job)
+
+	assert.Equal(t, Success, result.Status)
+	assert.Contains(t, buf.String(), "Job: test-job")
+	assert.Contains(t, buf.String(), "--dry-run")
+	assert.Contains(t, buf.String(), "restore output")
+}
+
+func TestRestoreCommand_RunRefused(t *testing.T) {
+	command := NewRestoreCommand(rsyncPath, NewMockExec(t), &bytes.Buffer{}, RestoreOptions{Apply: true})
+
+	result := command.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Refused, result.Status)
+	assert.Contains(t, result.Reason, "--overwrite-source")
+}
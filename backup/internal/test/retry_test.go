@@ -0,0 +1,205 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRsyncError(t *testing.T) {
+	rsyncErr := NewRsyncError(testutil.ExitError(23))
+	require.NotNil(t, rsyncErr)
+	assert.Equal(t, 23, rsyncErr.ExitCode)
+	assert.Equal(t, "rsync exit code 23: partial transfer due to error", rsyncErr.Error())
+	require.ErrorIs(t, rsyncErr, testutil.ExitError(23))
+
+	assert.Equal(t, "rsync exit code 99: unknown error", NewRsyncError(testutil.ExitError(99)).Error())
+	assert.Nil(t, NewRsyncError(errCommandNotFound))
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		wantErr bool
+	}{
+		{"Empty", RetryPolicy{}, false},
+		{"Valid", RetryPolicy{Retries: 3, RetryBackoff: time.Minute, RetryOn: []int{23, 30}}, false},
+		{"NegativeRetries", RetryPolicy{Retries: -1}, true},
+		{"NegativeBackoff", RetryPolicy{RetryBackoff: -time.Second}, true},
+		{"InvalidExitCode", RetryPolicy{RetryOn: []int{0}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.policy.Validate()
+			if test.wantErr {
+				require.ErrorIs(t, err, ErrInvalidRetryPolicy)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadResolvedConfig_RetryPolicy(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `mappings:
+  - name: home
+    source: /home
+    target: /backup
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+        retries: 2
+        retry_backoff: 1m
+        retry_on: [23]
+        allow_vanished: true
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, RetryPolicy{Retries: 2, RetryBackoff: time.Minute, RetryOn: []int{23}, AllowVanished: true},
+		cfg.AllJobs()[0].RetryPolicy)
+
+	path = testutil.WriteConfigFile(t, testutil.NewConfigBuilder().
+		AddMapping("home", "/home", "/backup").
+		AddJobToMapping("docs", "docs", "docs").Build()+"        retries: -1\n")
+
+	_, err = LoadResolvedConfig(path)
+	require.ErrorIs(t, err, ErrInvalidRetryPolicy)
+}
+
+func TestSyncCommand_Run_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		exits        []error
+		wantStatus   JobStatus
+		wantAttempts int
+		wantWaits    []time.Duration
+		wantReason   string
+	}{
+		{
+			name:       "NoRetries",
+			exits:      []error{testutil.ExitError(23)},
+			wantStatus: Failure,
+			wantReason: "rsync exit code 23: partial transfer due to error",
+		},
+		{
+			name:         "SucceedsOnRetry",
+			policy:       RetryPolicy{Retries: 3, RetryBackoff: time.Second},
+			exits:        []error{testutil.ExitError(23), testutil.ExitError(30), nil},
+			wantStatus:   Success,
+			wantAttempts: 3,
+			wantWaits:    []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "RetriesExhausted",
+			policy:       RetryPolicy{Retries: 1},
+			exits:        []error{testutil.ExitError(23), testutil.ExitError(23)},
+			wantStatus:   Failure,
+			wantAttempts: 2,
+			wantWaits:    []time.Duration{DefaultRetryBackoff},
+			wantReason:   "rsync exit code 23: partial transfer due to error",
+		},
+		{
+			name:       "NotRetriedExitCode",
+			policy:     RetryPolicy{Retries: 3},
+			exits:      []error{testutil.ExitError(11)},
+			wantStatus: Failure,
+			wantReason: "rsync exit code 11: error in file I/O",
+		},
+		{
+			name:       "RetryOn",
+			policy:     RetryPolicy{Retries: 3, RetryOn: []int{12}},
+			exits:      []error{testutil.ExitError(23)},
+			wantStatus: Failure,
+			wantReason: "rsync exit code 23: partial transfer due to error",
+		},
+		{
+			name:       "NoExitCode",
+			policy:     RetryPolicy{Retries: 3},
+			exits:      []error{errCommandNotFound},
+			wantStatus: Failure,
+		},
+		{
+			name:       "Vanished",
+			exits:      []error{testutil.ExitError(24)},
+			wantStatus: Failure,
+			wantReason: "rsync exit code 24: partial transfer due to vanished source files",
+		},
+		{
+			name:       "AllowVanished",
+			policy:     RetryPolicy{Retries: 3, RetryOn: []int{24}, AllowVanished: true},
+			exits:      []error{testutil.ExitError(24)},
+			wantStatus: Warning,
+			wantReason: "rsync exit code 24: partial transfer due to vanished source files",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockExec := NewMockExec(t)
+			cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &bytes.Buffer{})
+
+			var waits []time.Duration
+
+			cmd.Wait = func(_ context.Context, delay time.Duration) error {
+				waits = append(waits, delay)
+
+				return nil
+			}
+
+			for _, exit := range test.exits {
+				mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+					Return(exit).Once()
+			}
+
+			job := testutil.NewTestJob()
+			job.RetryPolicy = test.policy
+
+			result := cmd.Run(t.Context(), job)
+
+			assert.Equal(t, test.wantStatus, result.Status)
+			assert.Equal(t, test.wantAttempts, result.Attempts)
+			assert.Equal(t, test.wantWaits, waits)
+			assert.Equal(t, test.wantReason, result.Reason)
+		})
+	}
+}
+
+func TestSyncCommand_Run_RetryCancelled(t *testing.T) {
+	mockExec := NewMockExec(t)
+
+	var buf bytes.Buffer
+
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cmd.Wait = func(ctx context.Context, _ time.Duration) error {
+		cancel()
+
+		return ctx.Err()
+	}
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		Return(testutil.ExitError(30)).Once()
+
+	job := testutil.NewTestJob()
+	job.Retries = 2
+
+	result := cmd.Run(ctx, job)
+
+	assert.Equal(t, Cancelled, result.Status)
+	assert.Contains(t, buf.String(),
+		"Retrying job test-job in 30s (rsync exit code 30: timeout in data send/receive, retry 1 of 2)")
+}
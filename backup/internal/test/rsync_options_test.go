@@ -0,0 +1,151 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRsyncOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options RsyncOptions
+		wantErr string
+	}{
+		{"Empty", RsyncOptions{}, ""},
+		{"BwLimit", RsyncOptions{BwLimit: "5M"}, ""},
+		{"BwLimitPlain", RsyncOptions{BwLimit: "1500"}, ""},
+		{"InvalidBwLimit", RsyncOptions{BwLimit: "fast"}, `bwlimit "fast" must be a rate`},
+		{"InvalidBwLimitUnit", RsyncOptions{BwLimit: "5MB"}, `bwlimit "5MB" must be a rate`},
+		{"KnownLongOptions", RsyncOptions{ExtraArgs: []string{"--sparse", "--partial-dir=.rsync-partial"}}, ""},
+		{"KnownShortOptions", RsyncOptions{ExtraArgs: []string{"-HAX", "-x"}}, ""},
+		{"UnknownLongOption", RsyncOptions{ExtraArgs: []string{"--frobnicate"}}, `unknown option "--frobnicate"`},
+		{"UnknownShortOption", RsyncOptions{ExtraArgs: []string{"-HQ"}}, `unknown option -Q in "-HQ"`},
+		{"NotAnOption", RsyncOptions{ExtraArgs: []string{"/tmp"}}, `"/tmp" is not an option`},
+		{"DryRun", RsyncOptions{ExtraArgs: []string{"--dry-run"}}, `"--dry-run" is set by simulate`},
+		{"DryRunShort", RsyncOptions{ExtraArgs: []string{"-Hn"}}, `-n in "-Hn" is set by simulate`},
+		{"LogFile", RsyncOptions{ExtraArgs: []string{"--log-file=/tmp/x"}}, `"--log-file=/tmp/x" is set by the log directory`},
+		{"Delete", RsyncOptions{ExtraArgs: []string{"--delete"}}, `"--delete" is set by delete`},
+		{"DeleteAfter", RsyncOptions{ExtraArgs: []string{"--delete-after"}}, `"--delete-after" is set by delete`},
+		{"DeleteExcluded", RsyncOptions{ExtraArgs: []string{"--delete-excluded"}}, `"--delete-excluded" is set by delete`},
+		{"Del", RsyncOptions{ExtraArgs: []string{"--del"}}, `"--del" is set by delete`},
+		{"Filter", RsyncOptions{ExtraArgs: []string{"--filter=merge x"}}, `"--filter=merge x" is set by filters`},
+		{"FilterShort", RsyncOptions{ExtraArgs: []string{"-F"}}, `"-F" is set by filters`},
+		{"RemoteShell", RsyncOptions{ExtraArgs: []string{"--rsh=ssh"}}, "is set by source_remote and target_remote"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.options.Validate()
+
+			if test.wantErr == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidRsyncOption)
+			assert.Contains(t, err.Error(), test.wantErr)
+		})
+	}
+}
+
+func TestRsyncOptions_Arguments(t *testing.T) {
+	enabled, disabled := true, false
+
+	var unset *RsyncOptions
+
+	assert.Empty(t, unset.Arguments())
+	assert.Equal(t, []string{"--hard-links", "--numeric-ids", "--bwlimit=5M", "--sparse"}, (&RsyncOptions{
+		HardLinks: &enabled, ACLs: &disabled, NumericIDs: &enabled, BwLimit: "5M", ExtraArgs: []string{"--sparse"},
+	}).Arguments())
+}
+
+func TestArgumentsForJob_RsyncOptions(t *testing.T) {
+	enabled := true
+	job := Job{
+		Source: "/home/", Target: "/backup", Exclusions: []string{"*.tmp"},
+		Rsync: &RsyncOptions{Xattrs: &enabled, ExtraArgs: []string{"--one-file-system"}},
+	}
+
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "--exclude=*.tmp", "--xattrs", "--one-file-system", "/home/", "/backup",
+	}, ArgumentsForJob(job, "", false))
+}
+
+func TestLoadResolvedConfig_RsyncOptionsInheritance(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `
+variables:
+  partial: .rsync-partial
+rsync:
+  hard_links: true
+  bwlimit: 10M
+  extra_args: ["--partial-dir=${partial}"]
+mappings:
+  - name: home
+    source: /home
+    target: /backup
+    rsync:
+      acls: true
+      bwlimit: 5M
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+      - name: music
+        source: music
+        target: music
+        rsync:
+          hard_links: false
+          extra_args: ["--sparse"]
+  - name: media
+    source: /media
+    target: /backup/media
+    jobs:
+      - name: photos
+        source: photos
+        target: photos
+`)
+
+	cfg, err := LoadResolvedConfig(path)
+	require.NoError(t, err)
+
+	args := make(map[string][]string)
+	for _, job := range cfg.AllJobs() {
+		args[job.Name] = job.Rsync.Arguments()
+	}
+
+	assert.Equal(t, map[string][]string{
+		"docs":   {"--hard-links", "--acls", "--bwlimit=5M", "--partial-dir=.rsync-partial"},
+		"music":  {"--acls", "--bwlimit=5M", "--sparse"},
+		"photos": {"--hard-links", "--bwlimit=10M", "--partial-dir=.rsync-partial"},
+	}, args)
+}
+
+func TestLoadResolvedConfig_InvalidRsyncOption(t *testing.T) {
+	path := testutil.WriteConfigFile(t, `
+rsync:
+  extra_args: ["--dry-run"]
+mappings:
+  - name: home
+    source: /home
+    target: /backup
+    jobs:
+      - name: docs
+        source: docs
+        target: docs
+`)
+
+	_, err := LoadResolvedConfig(path)
+	require.ErrorIs(t, err, ErrInvalidRsyncOption)
+
+	// Reported once, where the options are set, rather than for each job inheriting them.
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Diagnostics, 1)
+	assert.Equal(t, "rsync", validationErr.Diagnostics[0].Field)
+	assert.Empty(t, validationErr.Diagnostics[0].Job)
+}
@@ -4,10 +4,14 @@ import (
 	. "backup-rsync/backup/internal"
 	"backup-rsync/backup/internal/testutil"
 	"bytes"
+	"context"
 	"errors"
 	"io"
+	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -53,6 +57,30 @@ func TestArgumentsForJob(t *testing.T) {
 				"/home/user/Documents/", "/backup/user/documents",
 			},
 		},
+		{
+			name: "RemoteTarget",
+			job: Job{
+				Delete: true, Source: "/home/user/Documents/", Target: "/volume1/documents",
+				TargetRemote: &Remote{Host: "nas", User: "backup", Port: 2222, SSHKey: "/root/.ssh/backup"},
+			},
+			wantArgs: []string{
+				"-aiv", "--stats", "--delete",
+				"-e", "ssh -o BatchMode=yes -p 2222 -i /root/.ssh/backup",
+				"/home/user/Documents/", "backup@nas:/volume1/documents",
+			},
+		},
+		{
+			name: "RemoteSource",
+			job: Job{
+				Source: "/srv/www/", Target: "/backup/www", SourceRemote: &Remote{Host: "web1"},
+			},
+			simulate: true,
+			wantArgs: []string{
+				"--dry-run", "-aiv", "--stats",
+				"-e", "ssh -o BatchMode=yes",
+				"web1:/srv/www/", "/backup/www",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -93,7 +121,7 @@ func TestGetVersionInfo(t *testing.T) {
 			}
 
 			if strings.HasPrefix(test.binPath, "/") {
-				mockExec.EXPECT().Execute(rsyncPath, mock.MatchedBy(func(args []string) bool {
+				mockExec.EXPECT().Execute(mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
 					return len(args) == 1 && args[0] == RsyncVersionFlag
 				})).Return(test.mockOutput, test.mockErr).Once()
 			}
@@ -150,9 +178,9 @@ func TestListCommand_Run_ReturnsSuccess(t *testing.T) {
 	cmd := NewListCommand(rsyncPath, mockExec, &buf)
 	job := testutil.NewTestJob()
 
-	status := cmd.Run(job)
+	result := cmd.Run(t.Context(), job)
 
-	assert.Equal(t, Success, status)
+	assert.Equal(t, Success, result.Status)
 	assert.Contains(t, buf.String(), "Job: test-job")
 	assert.Contains(t, buf.String(), rsyncPath)
 }
@@ -174,27 +202,256 @@ func TestSyncCommand_Run_Success(t *testing.T) {
 	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
 	job := testutil.NewTestJob()
 
-	mockExec.EXPECT().Execute(rsyncPath, mock.AnythingOfType("[]string")).
-		Return([]byte("sync output"), nil).Once()
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines("sync output")...)).Once()
 
-	status := cmd.Run(job)
+	result := cmd.Run(t.Context(), job)
 
-	assert.Equal(t, Success, status)
+	assert.Equal(t, Success, result.Status)
 	assert.Contains(t, buf.String(), "Job: test-job")
 	assert.Contains(t, buf.String(), "Output:\nsync output")
 }
 
+func TestSyncCommand_Run_StreamsStdoutAndStderr(t *testing.T) {
+	mockExec := NewMockExec(t)
+
+	var buf bytes.Buffer
+
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil,
+			testutil.Line{Stream: Stdout, Text: ">f+++++++++ a.txt"},
+			testutil.StderrLine("rsync: send_files failed to open \"b.txt\": Permission denied (13)"),
+			testutil.Line{Stream: Stdout, Text: ">f+++++++++ c.txt"},
+		)).Once()
+
+	result := cmd.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Success, result.Status)
+	assert.Contains(t, buf.String(), "Output:\n"+
+		">f+++++++++ a.txt\n"+
+		"stderr: rsync: send_files failed to open \"b.txt\": Permission denied (13)\n"+
+		">f+++++++++ c.txt\n")
+}
+
+func TestSyncCommand_Run_ParsesStats(t *testing.T) {
+	mockExec := NewMockExec(t)
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, io.Discard)
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines(strings.Split(rsyncStatsOutput, "\n")...)...)).Once()
+
+	result := cmd.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Success, result.Status)
+	require.NotNil(t, result.Stats)
+	assert.Equal(t, int64(45), result.Stats.RegularFilesTransferred)
+	assert.Equal(t, int64(3), result.Stats.DeletedFiles)
+}
+
 func TestSyncCommand_Run_Failure(t *testing.T) {
 	mockExec := NewMockExec(t)
 	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, io.Discard)
 	job := testutil.NewTestJob()
 
-	mockExec.EXPECT().Execute(rsyncPath, mock.AnythingOfType("[]string")).
-		Return(nil, errCommandNotFound).Once()
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		Return(errCommandNotFound).Once()
+
+	result := cmd.Run(t.Context(), job)
+
+	assert.Equal(t, Failure, result.Status)
+}
+
+func isDryRun(args []string) bool {
+	return slices.Contains(args, "--dry-run")
+}
+
+func TestSyncCommand_Run_MaxDelete(t *testing.T) {
+	// 10 entries in the source, 2 of them new; 4 of the 12 in the target are deleted.
+	dryRun := testutil.StdoutLines(
+		"*deleting   a.txt", "*deleting   b.txt", "*deleting   c/", "*deleting   c/d.txt",
+		">f+++++++++ e.txt",
+		"Number of files: 10",
+		"Number of created files: 2",
+	)
+
+	tests := []struct {
+		name       string
+		limit      DeleteLimit
+		force      bool
+		wantDryRun bool
+		want       JobResult
+	}{
+		{"WithinCount", DeleteLimit{Value: 4}, false, true, JobResult{Status: Success}},
+		{"OverCount", DeleteLimit{Value: 3}, false, true, JobResult{Status: Refused,
+			Reason: "4 of 12 entries would be deleted, max_delete is 3"}},
+		{"WithinPercent", DeleteLimit{Value: 50, Percent: true}, false, true, JobResult{Status: Success}},
+		{"OverPercent", DeleteLimit{Value: 25, Percent: true}, false, true, JobResult{Status: Refused,
+			Reason: "4 of 12 entries would be deleted, max_delete is 25%"}},
+		{"Forced", DeleteLimit{Value: 3}, true, false, JobResult{Status: Success}},
+		{"Unlimited", DeleteLimit{Unlimited: true}, false, false, JobResult{Status: Success}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockExec := NewMockExec(t)
+
+			var buf bytes.Buffer
+
+			cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, &buf)
+			cmd.Force = test.force
+
+			if test.wantDryRun {
+				mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(isDryRun)).
+					RunAndReturn(testutil.FeedLines(nil, dryRun...)).Once()
+			}
+
+			if test.want.Status == Success {
+				mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath,
+					mock.MatchedBy(func(args []string) bool { return !isDryRun(args) })).
+					Return(nil).Once()
+			}
+
+			result := cmd.Run(t.Context(), testutil.NewTestJob(testutil.WithMaxDelete(test.limit)))
 
-	status := cmd.Run(job)
+			assert.Equal(t, test.want.Status, result.Status)
+			assert.Equal(t, test.want.Reason, result.Reason)
+			assert.Equal(t, test.wantDryRun, strings.Contains(buf.String(), "Checking deletions of job test-job"))
+		})
+	}
+}
 
-	assert.Equal(t, Failure, status)
+func TestSyncCommand_Run_MaxDeleteSkippedWithoutDelete(t *testing.T) {
+	mockExec := NewMockExec(t)
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, io.Discard)
+	job := testutil.NewTestJob(testutil.WithMaxDelete(DeleteLimit{Value: 0}))
+	job.Delete = false
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return !isDryRun(args)
+	})).Return(nil).Once()
+
+	assert.Equal(t, Success, cmd.Run(t.Context(), job).Status)
+}
+
+func TestSyncCommand_Run_MaxDeleteCheckFails(t *testing.T) {
+	mockExec := NewMockExec(t)
+	cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, io.Discard)
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(isDryRun)).
+		Return(errCommandNotFound).Once()
+
+	result := cmd.Run(t.Context(), testutil.NewTestJob(testutil.WithMaxDelete(DeleteLimit{Value: 10})))
+
+	assert.Equal(t, JobResult{Status: Failure, Reason: "deletion check failed"}, result)
+}
+
+func TestSyncCommand_Run_InterruptedStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		newCtx  func() (context.Context, context.CancelFunc)
+		wantRun JobStatus
+	}{
+		{"Timeout", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(t.Context(), time.Millisecond)
+		}, Timeout},
+		{"Cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+
+			return ctx, cancel
+		}, Cancelled},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockExec := NewMockExec(t)
+			cmd := NewSyncCommand(rsyncPath, "/logs/base", mockExec, io.Discard)
+			ctx, cancel := test.newCtx()
+			defer cancel()
+
+			mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+				RunAndReturn(func(ctx context.Context, _ LineHandler, _ string, _ ...string) error {
+					<-ctx.Done()
+
+					return ctx.Err()
+				}).Once()
+
+			result := cmd.Run(ctx, testutil.NewTestJob())
+
+			assert.Equal(t, test.wantRun, result.Status)
+		})
+	}
+}
+
+func TestReportSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary RunSummary
+		want    string
+	}{
+		{"Basic", RunSummary{Counts: map[JobStatus]int{Success: 2, Failure: 1}},
+			"Summary: 2 succeeded, 1 failed, 0 skipped\n"},
+		{"WithInterruptions", RunSummary{Counts: map[JobStatus]int{Success: 1, Timeout: 1, Cancelled: 2}},
+			"Summary: 1 succeeded, 0 failed, 0 skipped, 1 timed out, 2 cancelled\n"},
+		{"WithRefused", RunSummary{Counts: map[JobStatus]int{Success: 1, Refused: 1}},
+			"Summary: 1 succeeded, 0 failed, 0 skipped, 1 refused\n"},
+		{"WithWarnings", RunSummary{Counts: map[JobStatus]int{Success: 1, Warning: 2}},
+			"Summary: 1 succeeded, 0 failed, 0 skipped, 2 with warnings\n"},
+		{"WithDifferences", RunSummary{Counts: map[JobStatus]int{Success: 1, Mismatch: 1}},
+			"Summary: 1 succeeded, 0 failed, 0 skipped, 1 with differences\n"},
+		{"WithTotals", RunSummary{
+			Counts: map[JobStatus]int{Success: 2},
+			Totals: TransferStats{RegularFilesTransferred: 12, TotalTransferredSize: 3 << 20,
+				TotalFileSize: 5 << 30, CreatedFiles: 4, DeletedFiles: 7},
+		}, "Summary: 2 succeeded, 0 failed, 0 skipped\n" +
+			"Totals: 12 files transferred, 3.0 MiB of 5.0 GiB transferred, 4 created, 7 deleted\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out, logBuf bytes.Buffer
+
+			cmd := NewSharedCommand(rsyncPath, "", nil, &out)
+
+			cmd.ReportSummary(test.summary, testutil.NewTestLogger(&logBuf))
+
+			assert.Equal(t, test.want, out.String())
+
+			for line := range strings.Lines(test.want) {
+				assert.Contains(t, logBuf.String(), strings.TrimSuffix(line, "\n"))
+			}
+		})
+	}
+}
+
+func TestReportJobStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result JobResult
+		want   string
+	}{
+		{"WithoutStats", JobResult{Status: Failure}, "Status [docs]: FAILURE\n"},
+		{"WithStats", JobResult{Status: Success, Stats: &TransferStats{
+			RegularFilesTransferred: 3, TotalTransferredSize: 2048, DeletedFiles: 1,
+		}}, "Status [docs]: SUCCESS (3 files, 2.0 KiB transferred, 1 deleted)\n"},
+		{"WithReason", JobResult{Status: Refused, Reason: "4 of 12 entries would be deleted, max_delete is 3"},
+			"Status [docs]: REFUSED (4 of 12 entries would be deleted, max_delete is 3)\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out, logBuf bytes.Buffer
+
+			cmd := NewSharedCommand(rsyncPath, "", nil, &out)
+
+			cmd.ReportJobStatus("docs", test.result, testutil.NewTestLogger(&logBuf))
+
+			assert.Equal(t, test.want, out.String())
+			assert.Contains(t, logBuf.String(), "STATUS [docs]: "+strings.TrimPrefix(strings.TrimSpace(test.want), "Status [docs]: "))
+		})
+	}
 }
 
 func TestNewSimulateCommand(t *testing.T) {
@@ -215,27 +472,105 @@ func TestSimulateCommand_Run_Success(t *testing.T) {
 	cmd := NewSimulateCommand(rsyncPath, logDir, mockExec, &buf)
 	job := testutil.NewTestJob()
 
-	mockExec.EXPECT().Execute(rsyncPath, mock.AnythingOfType("[]string")).
-		Return([]byte("simulated output"), nil).Once()
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines("simulated output")...)).Once()
 
-	status := cmd.Run(job)
+	result := cmd.Run(t.Context(), job)
 
-	assert.Equal(t, Success, status)
+	assert.Equal(t, Success, result.Status)
 	assert.Contains(t, buf.String(), "Job: test-job")
 }
 
+func TestSimulateCommand_Run_StreamsToLogFile(t *testing.T) {
+	mockExec := NewMockExec(t)
+	logDir := t.TempDir()
+
+	var buf bytes.Buffer
+
+	cmd := NewSimulateCommand(rsyncPath, logDir, mockExec, &buf)
+	job := testutil.NewTestJob()
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil,
+			testutil.Line{Stream: Stdout, Text: "*deleting   old.txt"},
+			testutil.StderrLine("file has vanished"),
+			testutil.Line{Stream: Stdout, Text: ">f+++++++++ new.txt"},
+		)).Once()
+
+	result := cmd.Run(t.Context(), job)
+
+	require.Equal(t, Success, result.Status)
+
+	logContent, err := os.ReadFile(cmd.JobLogPath(job))
+	require.NoError(t, err)
+	assert.Equal(t, "*deleting   old.txt\nstderr: file has vanished\n>f+++++++++ new.txt\n", string(logContent))
+	assert.Contains(t, buf.String(), "stderr: file has vanished\n")
+	assert.NotContains(t, buf.String(), "new.txt")
+	assert.Equal(t, []Change{
+		{Type: ChangeDeleted, Path: "old.txt", Itemize: "*deleting"},
+		{Type: ChangeNew, Path: "new.txt", Itemize: ">f+++++++++"},
+	}, result.Changes)
+}
+
+func TestSimulateCommand_ReportJobStatus(t *testing.T) {
+	result := JobResult{Status: Success, Changes: []Change{
+		{Type: ChangeNew, Path: "new.txt"},
+		{Type: ChangeDeleted, Path: "old/a.txt"},
+		{Type: ChangeDeleted, Path: "old/"},
+		{Type: ChangeDeleted, Path: "notes.txt"},
+		{Type: ChangeDeleted, Path: "draft.txt"},
+	}}
+
+	tests := []struct {
+		name         string
+		topDeletions int
+		result       JobResult
+		want         string
+	}{
+		{"WithDeletions", 2, result, "Status [docs]: SUCCESS\n" +
+			"Changes [docs]: 1 new, 0 updated, 4 deleted, 0 attribute-only, 0 symlink, 0 directory\n" +
+			"Top deletions [docs]:\n" +
+			"  old/ (2 entries)\n" +
+			"  draft.txt\n"},
+		{"NoDeletionsListed", 0, result, "Status [docs]: SUCCESS\n" +
+			"Changes [docs]: 1 new, 0 updated, 4 deleted, 0 attribute-only, 0 symlink, 0 directory\n"},
+		{"Skipped", 2, JobResult{Status: Skipped}, "Status [docs]: SKIPPED\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			report := &ChangeReport{}
+			cmd := NewSimulateCommand(rsyncPath, "", nil, &out)
+			cmd.TopDeletions = test.topDeletions
+			cmd.Report = report
+
+			cmd.ReportJobStatus("docs", test.result, testutil.NewTestLogger(io.Discard))
+
+			assert.Equal(t, test.want, out.String())
+
+			if test.result.Status == Skipped {
+				assert.Empty(t, report.Jobs)
+			} else {
+				assert.Equal(t, []JobChanges{{Job: "docs", Status: Success, Changes: test.result.Changes}}, report.Jobs)
+			}
+		})
+	}
+}
+
 func TestSimulateCommand_Run_Failure(t *testing.T) {
 	mockExec := NewMockExec(t)
 	logDir := t.TempDir()
 	cmd := NewSimulateCommand(rsyncPath, logDir, mockExec, io.Discard)
 	job := testutil.NewTestJob()
 
-	mockExec.EXPECT().Execute(rsyncPath, mock.AnythingOfType("[]string")).
-		Return(nil, errCommandNotFound).Once()
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		Return(errCommandNotFound).Once()
 
-	status := cmd.Run(job)
+	result := cmd.Run(t.Context(), job)
 
-	assert.Equal(t, Failure, status)
+	assert.Equal(t, Failure, result.Status)
 }
 
 func TestSimulateCommand_Run_LogWriteError(t *testing.T) {
@@ -247,11 +582,11 @@ func TestSimulateCommand_Run_LogWriteError(t *testing.T) {
 	cmd := NewSimulateCommand(rsyncPath, "/nonexistent/path", mockExec, &buf)
 	job := testutil.NewTestJob()
 
-	mockExec.EXPECT().Execute(rsyncPath, mock.AnythingOfType("[]string")).
-		Return([]byte("output"), nil).Once()
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.AnythingOfType("[]string")).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines("output")...)).Once()
 
-	status := cmd.Run(job)
+	result := cmd.Run(t.Context(), job)
 
-	assert.Equal(t, Success, status)
+	assert.Equal(t, Success, result.Status)
 	assert.Contains(t, buf.String(), "Warning: Failed to write output to log file")
 }
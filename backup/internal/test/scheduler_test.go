@@ -0,0 +1,220 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyTracker records the highest number of simultaneously running jobs, overall and per target.
+type concurrencyTracker struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	perTarget map[string]int
+	maxTarget map[string]int
+}
+
+func newConcurrencyTracker() *concurrencyTracker {
+	return &concurrencyTracker{perTarget: make(map[string]int), maxTarget: make(map[string]int)}
+}
+
+func (c *concurrencyTracker) run(_ context.Context, job Job) JobResult {
+	target := job.Target[:strings.LastIndex(job.Target, "/")]
+
+	c.mu.Lock()
+	c.active++
+	c.perTarget[target]++
+	c.maxActive = max(c.maxActive, c.active)
+	c.maxTarget[target] = max(c.maxTarget[target], c.perTarget[target])
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.active--
+	c.perTarget[target]--
+	c.mu.Unlock()
+
+	if strings.HasSuffix(job.Name, "fail") {
+		return JobResult{Status: Failure}
+	}
+
+	return JobResult{Status: Success}
+}
+
+func parallelConfig() Config {
+	var cfg Config
+
+	for _, disk := range []string{"/disk1", "/disk2", "/disk3"} {
+		mapping := Mapping{Name: disk, Source: "/src" + disk, Target: disk}
+
+		for i := range 3 {
+			name := fmt.Sprintf("%s-job%d", strings.TrimPrefix(disk, "/"), i)
+			mapping.Jobs = append(mapping.Jobs, Job{
+				Name: name, Source: mapping.Source + "/" + name + "/", Target: disk + "/" + name, Enabled: true,
+			})
+		}
+
+		cfg.Mappings = append(cfg.Mappings, mapping)
+	}
+
+	return cfg
+}
+
+func TestConfigApply_Parallel_RespectsLimits(t *testing.T) {
+	tests := []struct {
+		name                     string
+		opts                     ApplyOptions
+		wantMaxActive, wantMaxPT int
+	}{
+		{"Sequential", ApplyOptions{}, 1, 1},
+		{"ParallelOnePerTarget", ApplyOptions{Parallel: 4, PerTarget: 1}, 3, 1},
+		{"ParallelTwoPerTarget", ApplyOptions{Parallel: 4, PerTarget: 2}, 4, 2},
+		{"ParallelNoTargetLimit", ApplyOptions{Parallel: 2}, 2, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockCmd := NewMockJobCommand(t)
+			tracker := newConcurrencyTracker()
+			logger := testutil.NewTestLogger(io.Discard)
+
+			var reported []string
+
+			mockCmd.EXPECT().GetVersionInfo().Return("", "", errCommandNotFound).Once()
+			mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).RunAndReturn(tracker.run).Times(9)
+			mockCmd.EXPECT().ReportJobStatus(mock.Anything, JobResult{Status: Success}, logger).
+				Run(func(jobName string, _ JobResult, _ *slog.Logger) { reported = append(reported, jobName) }).Times(9)
+			mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{Success: 9}}, logger).Once()
+
+			err := parallelConfig().Apply(t.Context(), mockCmd, logger, test.opts)
+
+			require.NoError(t, err)
+			assert.LessOrEqual(t, tracker.maxActive, test.wantMaxActive)
+
+			for target, peak := range tracker.maxTarget {
+				assert.LessOrEqual(t, peak, test.wantMaxPT, "target %s", target)
+			}
+
+			var want []string
+			for _, job := range parallelConfig().AllJobs() {
+				want = append(want, job.Name)
+			}
+
+			assert.Equal(t, want, reported, "statuses must be reported in configuration order")
+		})
+	}
+}
+
+func TestConfigApply_Parallel_CountsFailures(t *testing.T) {
+	mockCmd := NewMockJobCommand(t)
+	logger := testutil.NewTestLogger(io.Discard)
+	tracker := newConcurrencyTracker()
+
+	cfg := Config{Mappings: []Mapping{{Name: "m", Target: "/disk", Jobs: []Job{
+		{Name: "ok", Target: "/disk/ok", Enabled: true},
+		{Name: "must-fail", Target: "/disk/fail", Enabled: true},
+		{Name: "off", Target: "/disk/off", Enabled: false},
+	}}}}
+
+	mockCmd.EXPECT().GetVersionInfo().Return("", "", errCommandNotFound).Once()
+	mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).RunAndReturn(tracker.run).Twice()
+	mockCmd.EXPECT().ReportJobStatus("ok", JobResult{Status: Success}, logger).Once()
+	mockCmd.EXPECT().ReportJobStatus("must-fail", JobResult{Status: Failure}, logger).Once()
+	mockCmd.EXPECT().ReportJobStatus("off", JobResult{Status: Skipped}, logger).Once()
+	mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{Success: 1, Failure: 1, Skipped: 1}}, logger).Once()
+
+	err := cfg.Apply(t.Context(), mockCmd, logger, ApplyOptions{Parallel: 3})
+
+	require.ErrorIs(t, err, ErrJobFailure)
+	assert.Contains(t, err.Error(), "1 of 3 jobs")
+}
+
+func TestConfigApply_CancelledRun(t *testing.T) {
+	mockCmd := NewMockJobCommand(t)
+	logger := testutil.NewTestLogger(io.Discard)
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	cfg := Config{Mappings: []Mapping{{Name: "m", Target: "/disk", Jobs: []Job{
+		{Name: "first", Target: "/disk/first", Enabled: true},
+		{Name: "second", Target: "/disk/second", Enabled: true},
+	}}}}
+
+	mockCmd.EXPECT().GetVersionInfo().Return("", "", errCommandNotFound).Once()
+	mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).
+		RunAndReturn(func(_ context.Context, _ Job) JobResult {
+			cancel()
+
+			return JobResult{Status: Cancelled}
+		}).Once()
+	mockCmd.EXPECT().ReportJobStatus("first", JobResult{Status: Cancelled}, logger).Once()
+	mockCmd.EXPECT().ReportJobStatus("second", JobResult{Status: Cancelled}, logger).Once()
+	mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{Cancelled: 2}}, logger).Once()
+
+	err := cfg.Apply(ctx, mockCmd, logger, ApplyOptions{})
+
+	require.ErrorIs(t, err, ErrRunCancelled)
+	assert.Contains(t, err.Error(), "2 of 2 jobs cancelled")
+}
+
+func TestConfigApply_StatusCountsAsFailure(t *testing.T) {
+	for _, status := range []JobStatus{Timeout, Refused} {
+		t.Run(string(status), func(t *testing.T) {
+			mockCmd := NewMockJobCommand(t)
+			logger := testutil.NewTestLogger(io.Discard)
+
+			cfg := Config{Mappings: []Mapping{{Name: "m", Target: "/disk", Jobs: []Job{
+				{Name: "slow", Target: "/disk/slow", Enabled: true},
+			}}}}
+
+			mockCmd.EXPECT().GetVersionInfo().Return("", "", errCommandNotFound).Once()
+			mockCmd.EXPECT().Run(mock.Anything, mock.AnythingOfType("internal.Job")).Return(JobResult{Status: status}).Once()
+			mockCmd.EXPECT().ReportJobStatus("slow", JobResult{Status: status}, logger).Once()
+			mockCmd.EXPECT().ReportSummary(RunSummary{Counts: map[JobStatus]int{status: 1}}, logger).Once()
+
+			err := cfg.Apply(t.Context(), mockCmd, logger, ApplyOptions{})
+
+			require.ErrorIs(t, err, ErrJobFailure)
+		})
+	}
+}
+
+func TestSyncWriter_ConcurrentLinesStayWhole(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewSyncWriter(&buf)
+
+	var wg sync.WaitGroup
+
+	for worker := range 8 {
+		wg.Go(func() {
+			for i := range 50 {
+				fmt.Fprintf(writer, "worker-%d line-%d %s\n", worker, i, strings.Repeat("x", 64))
+			}
+		})
+	}
+
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 400)
+
+	for _, line := range lines {
+		assert.Regexp(t, `^worker-\d line-\d+ x{64}$`, line)
+	}
+}
@@ -0,0 +1,138 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_UnknownField(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "Top level",
+			yaml:    "mapings: []\n",
+			wantErr: `1:1: unknown field "mapings" in Config`,
+		},
+		{
+			name:    "Mapping",
+			yaml:    "mappings:\n  - name: m\n    source: /src\n    target: /dst\n    exclusion: [\"*.tmp\"]\n",
+			wantErr: `5:5: unknown field "exclusion" in Mapping`,
+		},
+		{
+			name:    "Job",
+			yaml:    "mappings:\n  - name: m\n    jobs:\n      - name: docs\n        enabeld: false\n",
+			wantErr: `5:9: unknown field "enabeld" in Job`,
+		},
+		{
+			name:    "Nested",
+			yaml:    "mappings:\n  - name: m\n    jobs:\n      - name: docs\n        preflight:\n          require_mount: true\n",
+			wantErr: `6:11: unknown field "require_mount" in Preflight`,
+		},
+		{
+			name:    "Hook",
+			yaml:    "hooks:\n  before:\n    - command: true\n      saf: true\n",
+			wantErr: `4:7: unknown field "saf" in Hook`,
+		},
+		{
+			name:    "Filter rule",
+			yaml:    "mappings:\n  - name: m\n    filters:\n      - exlude: \"*.tmp\"\n",
+			wantErr: `4:9: unknown field "exlude" in Mapping`,
+		},
+		{
+			name:    "Several",
+			yaml:    "mapings: []\nvariables:\n  user: alice\nlock_dirs: /run\n",
+			wantErr: "1:1: unknown field \"mapings\" in Config\n4:1: unknown field \"lock_dirs\" in Config",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadConfig(strings.NewReader(test.yaml))
+
+			require.ErrorIs(t, err, ErrUnknownField)
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestLoadConfig_KnownFields(t *testing.T) {
+	yamlData := `
+variables:
+  base: /mnt/backup
+hooks:
+  before:
+    - "mount /mnt/backup"
+    - command: "logger start"
+      safe: true
+mappings:
+  - name: "home"
+    source: "/home"
+    target: "${base}"
+    max_delete: 10%
+    filters:
+      - exclude: "*.tmp"
+    jobs:
+      - &docs
+        name: "docs"
+        source: "docs"
+        target: "docs"
+        delete: false
+        retries: 2
+        preflight:
+          min_free_space: 10GiB
+          on_failure: skip
+      - <<: *docs
+        name: "music"
+        source: "music"
+        target: "music"
+`
+
+	cfg, err := LoadConfig(strings.NewReader(yamlData))
+
+	require.NoError(t, err)
+	require.Len(t, cfg.Mappings[0].Jobs, 2)
+	assert.Equal(t, 2, cfg.Mappings[0].Jobs[1].Retries)
+	assert.False(t, cfg.Mappings[0].Jobs[1].Delete)
+}
+
+func TestLoadResolvedConfig_UnknownFieldNamesFile(t *testing.T) {
+	path := testutil.WriteConfigFile(t, "mappings:\n  - name: m\n    source: /src\n    target: /dst\n"+
+		"    jobs:\n      - name: docs\n        source: docs\n        target: docs\n        exclusion: [\"*.tmp\"]\n")
+
+	_, err := LoadResolvedConfig(path)
+
+	require.ErrorIs(t, err, ErrUnknownField)
+	assert.Contains(t, err.Error(), path+`:9:9: unknown field "exclusion" in Job`)
+}
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+
+	assert.Equal(t, "http://json-schema.org/draft-07/schema#", schema.Schema)
+	assert.Equal(t, false, schema.AdditionalProperties)
+	assert.Equal(t, "#/definitions/Mapping", schema.Properties["mappings"].Items.Ref)
+
+	job := schema.Definitions["Job"]
+	require.NotNil(t, job)
+	assert.Equal(t, "boolean", job.Properties["enabled"].Type)
+	assert.Equal(t, "integer", job.Properties["retries"].Type, "inlined fields are listed")
+	assert.Equal(t, []string{"mirror", "snapshot"}, job.Properties["mode"].Enum)
+	assert.NotContains(t, job.Properties, "mappingfilters")
+
+	rule := schema.Definitions["Mapping"].Properties["filters"].Items
+	assert.Contains(t, rule.Properties, "exclude_from")
+	assert.Equal(t, 1, *rule.MaxProperties)
+
+	content, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"$ref":"#/definitions/Job"`)
+}
@@ -0,0 +1,97 @@
+package internal_test
+
+import (
+	"testing"
+
+	. "backup-rsync/backup/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selectorConfig() Config {
+	return Config{
+		Mappings: []Mapping{
+			{Name: "home", Jobs: []Job{
+				{Name: "home_docs", Tags: []string{"daily", "important"}},
+				{Name: "home_music", Tags: []string{"weekly"}},
+			}},
+			{Name: "data", Jobs: []Job{
+				{Name: "data_photos", Tags: []string{"weekly", "important"}},
+				{Name: "data_scratch"},
+			}},
+		},
+	}
+}
+
+func selectedNames(cfg Config) []string {
+	names := []string{}
+	for _, job := range cfg.AllJobs() {
+		names = append(names, job.Name)
+	}
+
+	return names
+}
+
+func TestJobSelector_Select(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector JobSelector
+		want     []string
+	}{
+		{"Empty", JobSelector{}, []string{"home_docs", "home_music", "data_photos", "data_scratch"}},
+		{"ByName", JobSelector{Names: []string{"data_photos", "home_docs"}}, []string{"home_docs", "data_photos"}},
+		{"ByGlob", JobSelector{Names: []string{"home_*"}}, []string{"home_docs", "home_music"}},
+		{"ByMapping", JobSelector{Mappings: []string{"data"}}, []string{"data_photos", "data_scratch"}},
+		{"ByTag", JobSelector{Tags: []string{"important"}}, []string{"home_docs", "data_photos"}},
+		{"MappingAndTag", JobSelector{Mappings: []string{"home"}, Tags: []string{"weekly"}}, []string{"home_music"}},
+		{"ExcludeGlob", JobSelector{Exclude: []string{"*_scratch", "home_music"}}, []string{"home_docs", "data_photos"}},
+		{"NameAndExclude", JobSelector{Names: []string{"*"}, Exclude: []string{"data_*"}},
+			[]string{"home_docs", "home_music"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := test.selector.Select(selectorConfig())
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, selectedNames(selected))
+		})
+	}
+}
+
+func TestJobSelector_Select_DropsEmptyMappings(t *testing.T) {
+	selected, err := JobSelector{Names: []string{"home_docs"}}.Select(selectorConfig())
+
+	require.NoError(t, err)
+	require.Len(t, selected.Mappings, 1)
+	assert.Equal(t, "home", selected.Mappings[0].Name)
+}
+
+func TestJobSelector_Select_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector JobSelector
+		wantErr  error
+		wantMsg  string
+	}{
+		{"UnknownJob", JobSelector{Names: []string{"nope"}}, ErrUnknownJob,
+			`unknown job "nope" (valid jobs: home_docs, home_music, data_photos, data_scratch)`},
+		{"UnmatchedGlob", JobSelector{Names: []string{"x*"}}, ErrUnknownJob, `unknown job "x*"`},
+		{"UnknownExclude", JobSelector{Exclude: []string{"nope"}}, ErrUnknownJob, `unknown job "nope"`},
+		{"MalformedPattern", JobSelector{Names: []string{"[a"}}, ErrUnknownJob, "syntax error in pattern"},
+		{"UnknownMapping", JobSelector{Mappings: []string{"media"}}, ErrUnknownMapping,
+			`unknown mapping "media" (valid mappings: home, data)`},
+		{"UnknownTag", JobSelector{Tags: []string{"hourly"}}, ErrUnknownTag,
+			`unknown tag "hourly" (valid tags: daily, important, weekly)`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.selector.Select(selectorConfig())
+
+			require.ErrorIs(t, err, test.wantErr)
+			assert.Contains(t, err.Error(), test.wantMsg)
+		})
+	}
+}
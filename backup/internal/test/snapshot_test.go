@@ -0,0 +1,323 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMode_YAML(t *testing.T) {
+	var job Job
+
+	require.NoError(t, yaml.Unmarshal([]byte("name: docs\nmode: snapshot\nretention: {daily: 7, monthly: 12}\n"), &job))
+	assert.Equal(t, SnapshotMode, job.Mode)
+	assert.Equal(t, &Retention{Daily: 7, Monthly: 12}, job.Retention)
+
+	err := yaml.Unmarshal([]byte("name: docs\nmode: versioned\n"), &job)
+	require.ErrorIs(t, err, ErrInvalidMode)
+}
+
+func snapshotsAt(times ...string) []Snapshot {
+	snapshots := make([]Snapshot, 0, len(times))
+
+	for _, value := range times {
+		created, err := time.ParseInLocation(SnapshotTimeFormat, value, time.Local)
+		if err != nil {
+			panic(err)
+		}
+
+		snapshots = append(snapshots, Snapshot{Name: value, Time: created})
+	}
+
+	return snapshots
+}
+
+func TestRetention_Keep(t *testing.T) {
+	snapshots := snapshotsAt(
+		"2026-08-20T02-00-00", // August, week 34
+		"2026-09-28T02-00-00", // September, week 40
+		"2026-10-05T02-00-00", // week 41
+		"2026-10-12T02-00-00", // Monday, week 42
+		"2026-10-15T02-00-00",
+		"2026-10-16T02-00-00",
+		"2026-10-17T01-00-00",
+		"2026-10-17T02-00-00",
+	)
+
+	tests := []struct {
+		name      string
+		retention Retention
+		want      []string
+	}{
+		{"KeepsNewestAlways", Retention{}, []string{"2026-10-17T02-00-00"}},
+		{"Daily", Retention{Daily: 3}, []string{
+			"2026-10-15T02-00-00", "2026-10-16T02-00-00", "2026-10-17T02-00-00",
+		}},
+		{"Weekly", Retention{Weekly: 3}, []string{
+			"2026-09-28T02-00-00", "2026-10-05T02-00-00", "2026-10-17T02-00-00",
+		}},
+		{"Monthly", Retention{Monthly: 3}, []string{
+			"2026-08-20T02-00-00", "2026-09-28T02-00-00", "2026-10-17T02-00-00",
+		}},
+		{"Combined", Retention{Daily: 2, Monthly: 2}, []string{
+			"2026-09-28T02-00-00", "2026-10-16T02-00-00", "2026-10-17T02-00-00",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keep := test.retention.Keep(snapshots)
+
+			var kept []string
+
+			for _, snapshot := range snapshots {
+				if keep[snapshot.Name] {
+					kept = append(kept, snapshot.Name)
+				}
+			}
+
+			assert.Equal(t, test.want, kept)
+		})
+	}
+}
+
+func TestSnapshotStore_List(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, dir := range []string{"2026-10-16T02-00-00", "2026-10-15T02-00-00", "2026-10-17T02-00-00.partial", "other"} {
+		require.NoError(t, fs.MkdirAll(filepath.Join("/backup/docs", dir), 0755))
+	}
+
+	require.NoError(t, afero.WriteFile(fs, "/backup/docs/2026-10-14T02-00-00", nil, 0644))
+
+	store := SnapshotStore{Fs: fs}
+
+	snapshots, err := store.List("/backup/docs")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "2026-10-15T02-00-00", snapshots[0].Name)
+	assert.Equal(t, "/backup/docs/2026-10-16T02-00-00", snapshots[1].Path)
+
+	snapshots, err = store.List("/backup/missing")
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestSnapshotStore_Complete(t *testing.T) {
+	target := t.TempDir()
+	store := SnapshotStore{Fs: afero.NewOsFs()}
+
+	for _, name := range []string{"2026-10-16T02-00-00", "2026-10-17T02-00-00"} {
+		require.NoError(t, os.Mkdir(PartialPath(target, name), 0755))
+		require.NoError(t, store.Complete(target, name))
+
+		link, err := os.Readlink(filepath.Join(target, LatestSnapshot))
+		require.NoError(t, err)
+		assert.Equal(t, name, link)
+		assert.DirExists(t, filepath.Join(target, name))
+		assert.NoDirExists(t, PartialPath(target, name))
+	}
+
+	assert.True(t, store.HasLatest(target))
+}
+
+func TestSnapshotStore_CompleteWithoutSymlinks(t *testing.T) {
+	store := SnapshotStore{Fs: afero.NewMemMapFs()}
+
+	require.ErrorIs(t, store.Complete("/backup", "2026-10-17T02-00-00"), ErrSymlinksUnsupported)
+}
+
+func TestSnapshotStore_Prune(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	names := []string{"2026-10-15T02-00-00", "2026-10-16T02-00-00", "2026-10-17T02-00-00"}
+
+	for _, name := range names {
+		require.NoError(t, fs.MkdirAll(filepath.Join("/backup/docs", name), 0755))
+	}
+
+	store := SnapshotStore{Fs: fs}
+
+	pruned, err := store.Prune("/backup/docs", Retention{Daily: 2}, true)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, names[0], pruned[0].Name)
+
+	exists, err := afero.DirExists(fs, "/backup/docs/"+names[0])
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = store.Prune("/backup/docs", Retention{Daily: 2}, false)
+	require.NoError(t, err)
+
+	exists, err = afero.DirExists(fs, "/backup/docs/"+names[0])
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	pruned, err = store.Prune("/backup/docs", Retention{}, false)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+}
+
+func TestSnapshotStore_PrunePartials(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, dir := range []string{"2026-10-16T02-00-00", "2026-10-17T02-00-00.partial", "notes.partial"} {
+		require.NoError(t, fs.MkdirAll(filepath.Join("/backup/docs", dir), 0755))
+	}
+
+	store := SnapshotStore{Fs: fs}
+
+	pruned, err := store.Prune("/backup/docs", Retention{Daily: 7}, true)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, "2026-10-17T02-00-00.partial", pruned[0].Name)
+
+	pruned, err = store.Prune("/backup/docs", Retention{}, false)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+
+	entries, err := afero.ReadDir(fs, "/backup/docs")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "2026-10-16T02-00-00", entries[0].Name())
+	assert.Equal(t, "notes.partial", entries[1].Name())
+}
+
+func TestArgumentsForSnapshot(t *testing.T) {
+	job := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Delete: true}
+
+	assert.Equal(t, []string{
+		"-aiv", "--stats", "--delete", "--link-dest=/backup/docs/latest", "/home/docs/", "/backup/docs/s.partial",
+	}, ArgumentsForSnapshot(job, "/backup/docs/s.partial", "/backup/docs/latest", "", false))
+
+	assert.Equal(t, []string{
+		"--dry-run", "-aiv", "--stats", "--delete", "/home/docs/", "/backup/docs/s.partial",
+	}, ArgumentsForSnapshot(job, "/backup/docs/s.partial", "", "", true))
+}
+
+func newSnapshotCommand(t *testing.T, mockExec *MockExec, output io.Writer, now string) SyncCommand {
+	t.Helper()
+
+	cmd := NewSyncCommand(rsyncPath, "", mockExec, output)
+	cmd.Snapshots = SnapshotStore{Fs: afero.NewOsFs()}
+	cmd.Now = func() time.Time {
+		created, err := time.ParseInLocation(SnapshotTimeFormat, now, time.Local)
+		require.NoError(t, err)
+
+		return created
+	}
+
+	return cmd
+}
+
+// writeSnapshot stands in for rsync creating the destination directory.
+func writeSnapshot(t *testing.T, target, name string, err error) func(context.Context, LineHandler, string, ...string) error {
+	t.Helper()
+
+	return func(context.Context, LineHandler, string, ...string) error {
+		require.NoError(t, os.MkdirAll(PartialPath(target, name), 0755))
+
+		return err
+	}
+}
+
+func TestSyncCommand_Run_Snapshot(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "docs")
+	job := Job{
+		Name: "docs", Source: "/home/docs/", Target: target, Enabled: true,
+		Mode: SnapshotMode, Retention: &Retention{Daily: 1},
+	}
+
+	first, second := "2026-10-16T02-00-00", "2026-10-17T02-00-00"
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return !slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--link-dest=") }) &&
+			slices.Contains(args, PartialPath(target, first))
+	})).RunAndReturn(writeSnapshot(t, target, first, nil)).Once()
+
+	result := newSnapshotCommand(t, mockExec, io.Discard, first).Run(t.Context(), job)
+	require.Equal(t, Success, result.Status)
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return slices.Contains(args, "--link-dest="+filepath.Join(target, LatestSnapshot)) &&
+			slices.Contains(args, PartialPath(target, second))
+	})).RunAndReturn(writeSnapshot(t, target, second, nil)).Once()
+
+	var buf bytes.Buffer
+
+	result = newSnapshotCommand(t, mockExec, &buf, second).Run(t.Context(), job)
+	require.Equal(t, Success, result.Status)
+
+	assert.Contains(t, buf.String(), "Snapshot: "+filepath.Join(target, second))
+	assert.Contains(t, buf.String(), "Pruned snapshot: "+filepath.Join(target, first))
+	assert.NoDirExists(t, filepath.Join(target, first))
+
+	link, err := os.Readlink(filepath.Join(target, LatestSnapshot))
+	require.NoError(t, err)
+	assert.Equal(t, second, link)
+}
+
+func TestSyncCommand_Run_SnapshotFailureKeepsLatest(t *testing.T) {
+	target := t.TempDir()
+	job := Job{Name: "docs", Source: "/home/docs/", Target: target, Enabled: true, Mode: SnapshotMode}
+	name := "2026-10-17T02-00-00"
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.Anything).
+		RunAndReturn(writeSnapshot(t, target, name, errCommandNotFound)).Once()
+
+	result := newSnapshotCommand(t, mockExec, io.Discard, name).Run(t.Context(), job)
+
+	assert.Equal(t, Failure, result.Status)
+	assert.NoDirExists(t, PartialPath(target, name), "the partial snapshot of a failed run is removed")
+	assert.NoFileExists(t, filepath.Join(target, LatestSnapshot))
+}
+
+func TestSyncCommand_Run_SnapshotRemovesOldPartials(t *testing.T) {
+	target := t.TempDir()
+	job := Job{Name: "docs", Source: "/home/docs/", Target: target, Enabled: true, Mode: SnapshotMode}
+	stale, name := "2026-10-16T02-00-00", "2026-10-17T02-00-00"
+	require.NoError(t, os.MkdirAll(filepath.Join(PartialPath(target, stale), "docs"), 0755))
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.Anything).
+		RunAndReturn(writeSnapshot(t, target, name, nil)).Once()
+
+	result := newSnapshotCommand(t, mockExec, io.Discard, name).Run(t.Context(), job)
+
+	require.Equal(t, Success, result.Status)
+	assert.NoDirExists(t, PartialPath(target, stale))
+	assert.DirExists(t, filepath.Join(target, name))
+}
+
+func TestSimulateCommand_Run_Snapshot(t *testing.T) {
+	target := t.TempDir()
+	job := Job{Name: "docs", Source: "/home/docs/", Target: target, Enabled: true, Mode: SnapshotMode}
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return isDryRun(args) && slices.Contains(args, PartialPath(target, "2026-10-17T02-00-00"))
+	})).Return(nil).Once()
+
+	cmd := NewSimulateCommand(rsyncPath, "", mockExec, io.Discard)
+	cmd.Snapshots = SnapshotStore{Fs: afero.NewOsFs()}
+	cmd.Now = func() time.Time { return time.Date(2026, 10, 17, 2, 0, 0, 0, time.Local) }
+
+	assert.Equal(t, Success, cmd.Run(t.Context(), job).Status)
+
+	entries, err := os.ReadDir(target)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
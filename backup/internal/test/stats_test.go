@@ -0,0 +1,125 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rsyncStatsOutput = `>f+++++++++ docs/a.txt
+*deleting   docs/old.txt
+
+Number of files: 1,234 (reg: 1,000, dir: 234)
+Number of created files: 12 (reg: 10, dir: 2)
+Number of deleted files: 3 (reg: 3)
+Number of regular files transferred: 45
+Total file size: 5,368,709,120 bytes
+Total transferred file size: 3,145,728 bytes
+Literal data: 2,097,152 bytes
+Matched data: 1,048,576 bytes
+File list size: 65,536
+File list generation time: 0.001 seconds
+File list transfer time: 0.000 seconds
+Total bytes sent: 2,200,000
+Total bytes received: 1,024
+
+sent 2,200,000 bytes  received 1,024 bytes  4,402,048.00 bytes/sec
+total size is 5,368,709,120  speedup is 1,706.67
+`
+
+func TestParseStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *TransferStats
+	}{
+		{"Rsync3", rsyncStatsOutput, &TransferStats{
+			Files:                   1234,
+			CreatedFiles:            12,
+			DeletedFiles:            3,
+			RegularFilesTransferred: 45,
+			TotalFileSize:           5368709120,
+			TotalTransferredSize:    3145728,
+			LiteralData:             2097152,
+			MatchedData:             1048576,
+			TotalBytesSent:          2200000,
+			TotalBytesReceived:      1024,
+			Speedup:                 1706.67,
+		}},
+		{"LegacyLabel", "Number of files: 10\nNumber of files transferred: 4\n", &TransferStats{
+			Files:                   10,
+			RegularFilesTransferred: 4,
+		}},
+		{"DryRun", "total size is 2,048  speedup is 2.00 (DRY RUN)\n", &TransferStats{Speedup: 2}},
+		{"NoStatistics", ">f+++++++++ a.txt\nsent 10 bytes\n", nil},
+		{"MalformedNumber", "Number of files: many\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, ParseStats(test.output))
+		})
+	}
+}
+
+func TestStatsParser_StatsIsACopy(t *testing.T) {
+	var parser StatsParser
+
+	parser.Feed("Number of files: 1")
+
+	first := parser.Stats()
+	require.NotNil(t, first)
+
+	parser.Feed("Number of files: 2")
+
+	assert.Equal(t, int64(1), first.Files)
+	assert.Equal(t, int64(2), parser.Stats().Files)
+}
+
+func TestTransferStats_Add(t *testing.T) {
+	total := TransferStats{}
+
+	total.Add(TransferStats{
+		Files: 2, DeletedFiles: 1, TotalFileSize: 300, LiteralData: 50, MatchedData: 50,
+		TotalBytesSent: 60, TotalBytesReceived: 40, Speedup: 3,
+	})
+	total.Add(TransferStats{
+		Files: 3, CreatedFiles: 4, TotalFileSize: 900, LiteralData: 300,
+		TotalBytesSent: 90, TotalBytesReceived: 10, Speedup: 9,
+	})
+
+	assert.Equal(t, TransferStats{
+		Files:              5,
+		CreatedFiles:       4,
+		DeletedFiles:       1,
+		TotalFileSize:      1200,
+		LiteralData:        350,
+		MatchedData:        50,
+		TotalBytesSent:     150,
+		TotalBytesReceived: 50,
+		Speedup:            6,
+	}, total)
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{3 << 20, "3.0 MiB"},
+		{5 << 30, "5.0 GiB"},
+		{1 << 40, "1.0 TiB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			assert.Equal(t, test.want, FormatBytes(test.size))
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package internal_test
+
+import (
+	. "backup-rsync/backup/internal"
+	"backup-rsync/backup/internal/testutil"
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSamplePercent(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"10%", 10, false},
+		{"2.5", 2.5, false},
+		{"100%", 100, false},
+		{"0%", 0, true},
+		{"150%", 0, true},
+		{"some", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			percent, err := ParseSamplePercent(test.value)
+
+			if test.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSample)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, test.want, percent, 0)
+		})
+	}
+}
+
+func isChecksumDryRun(args []string) bool {
+	return isDryRun(args) && slices.Contains(args, "--checksum")
+}
+
+func TestVerifyCommand_Run_Matches(t *testing.T) {
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(isChecksumDryRun)).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines(".f..t...... touched.txt", ".d..t...... ./")...)).
+		Once()
+
+	command := NewVerifyCommand(rsyncPath, t.TempDir(), mockExec, io.Discard)
+
+	result := command.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Success, result.Status)
+	require.NotNil(t, result.Verification)
+	assert.Empty(t, result.Verification.Differences)
+}
+
+func TestVerifyCommand_Run_Differences(t *testing.T) {
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(isChecksumDryRun)).
+		RunAndReturn(testutil.FeedLines(nil, testutil.StdoutLines(
+			"cd+++++++++ reports/",
+			">f+++++++++ reports/q3.pdf",
+			">fcs....... notes.txt",
+			".f..t...... touched.txt",
+			"*deleting   old.txt",
+		)...)).Once()
+
+	var buf bytes.Buffer
+
+	command := NewVerifyCommand(rsyncPath, t.TempDir(), mockExec, &buf)
+
+	result := command.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Mismatch, result.Status)
+	assert.Equal(t, "3 files differ", result.Reason)
+	require.NotNil(t, result.Verification)
+	assert.Len(t, result.Verification.Differences, 3)
+
+	command.ReportJobStatus("test-job", result, testutil.NewTestLogger(&bytes.Buffer{}))
+
+	assert.Contains(t, buf.String(), "Status [test-job]: MISMATCH (3 files differ)\n"+
+		"Differs [test-job]: missing reports/q3.pdf\n"+
+		"Differs [test-job]: changed notes.txt\n"+
+		"Differs [test-job]: extra old.txt\n")
+}
+
+func TestVerifyCommand_Run_Failure(t *testing.T) {
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.Anything).
+		Return(testutil.ExitError(23)).Once()
+
+	command := NewVerifyCommand(rsyncPath, t.TempDir(), mockExec, io.Discard)
+
+	result := command.Run(t.Context(), testutil.NewTestJob())
+
+	assert.Equal(t, Failure, result.Status)
+	assert.Equal(t, 23, result.ExitCode)
+	assert.Nil(t, result.Verification)
+}
+
+func TestVerifyCommand_Run_Sample(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	logPath := filepath.Join("logs", "sync-2026-10-17T03-00-00-verify")
+	require.NoError(t, fs.MkdirAll(logPath, 0o755))
+
+	listing := testutil.StdoutLines(
+		"drwxr-xr-x          4,096 2026/10/17 03:00:00 .",
+		"-rw-r--r--          1,234 2026/10/17 03:00:00 a.txt",
+		"-rw-r--r--          1,234 2026/10/17 03:00:00 b  c.txt",
+		"lrwxrwxrwx              5 2026/10/17 03:00:00 link -> a.txt",
+		"drwxr-xr-x          4,096 2026/10/17 03:00:00 sub",
+		"-rw-r--r--     12,345,678 2026/10/17 03:00:00 sub/d.txt",
+	)
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return slices.Equal(args, []string{"--list-only", "--recursive", "--exclude=*.tmp", "/home/user/docs/"})
+	})).RunAndReturn(testutil.FeedLines(nil, listing...)).Once()
+
+	filesFrom := filepath.Join(logPath, "job-test-job.sample")
+
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return isChecksumDryRun(args) && slices.Contains(args, "--files-from="+filesFrom) &&
+			!slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--delete") })
+	})).Return(nil).Once()
+
+	var buf bytes.Buffer
+
+	command := NewVerifyCommand(rsyncPath, logPath, mockExec, &buf)
+	command.SamplePercent = 50
+	command.Rand = rand.New(rand.NewPCG(1, 2))
+	command.Fs = fs
+
+	result := command.Run(t.Context(), testutil.NewTestJob())
+
+	require.Equal(t, Success, result.Status)
+	assert.Equal(t, &VerifyResult{SamplePercent: 50, SampledFiles: 2, Differences: []Change{}}, result.Verification)
+
+	content, err := afero.ReadFile(fs, filesFrom)
+	require.NoError(t, err)
+
+	sampled := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, sampled, 2)
+	assert.Subset(t, []string{"a.txt", "b  c.txt", "sub/d.txt"}, sampled)
+
+	command.ReportJobStatus("test-job", result, testutil.NewTestLogger(&bytes.Buffer{}))
+	assert.Contains(t, buf.String(), "Sample [test-job]: 2 files (50%)\n")
+}
+
+func TestVerifyCommand_Run_Snapshot(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/backup/docs/2026-10-16T02-00-00", 0755))
+	require.NoError(t, fs.MkdirAll("/backup/docs/2026-10-17T02-00-00", 0755))
+
+	mockExec := NewMockExec(t)
+	mockExec.EXPECT().Stream(mock.Anything, mock.Anything, rsyncPath, mock.MatchedBy(func(args []string) bool {
+		return args[len(args)-1] == "/backup/docs/2026-10-17T02-00-00"
+	})).Return(nil).Once()
+
+	command := NewVerifyCommand(rsyncPath, t.TempDir(), mockExec, io.Discard)
+	command.Snapshots = SnapshotStore{Fs: fs}
+
+	job := Job{Name: "docs", Source: "/home/docs/", Target: "/backup/docs", Mode: SnapshotMode}
+
+	assert.Equal(t, Success, command.Run(t.Context(), job).Status)
+
+	job.Target = "/backup/music"
+	result := command.Run(t.Context(), job)
+
+	assert.Equal(t, Failure, result.Status)
+	assert.Contains(t, result.Reason, ErrNoSnapshot.Error())
+}
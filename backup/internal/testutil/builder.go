@@ -16,6 +16,9 @@ type jobDef struct {
 	delete     *bool
 	enabled    *bool
 	exclusions []string
+	tags       []string
+	maxDelete  string
+	mode       string
 }
 
 type mappingDef struct {
@@ -23,6 +26,8 @@ type mappingDef struct {
 	source     string
 	target     string
 	exclusions []string
+	maxDelete  string
+	mode       string
 	jobs       []jobDef
 }
 
@@ -37,6 +42,7 @@ type ConfigBuilder struct {
 	variables    map[string]string
 	templateVars []string
 	includes     []includeDef
+	maxDelete    string
 }
 
 // NewConfigBuilder creates an empty ConfigBuilder.
@@ -77,6 +83,35 @@ func (b *ConfigBuilder) AddJobToMapping(name, source, target string, opts ...Job
 	return b
 }
 
+// MappingMaxDelete sets max_delete on the last mapping.
+func (b *ConfigBuilder) MappingMaxDelete(limit string) *ConfigBuilder {
+	if len(b.mappings) == 0 {
+		panic("MappingMaxDelete called with no mappings")
+	}
+
+	b.mappings[len(b.mappings)-1].maxDelete = limit
+
+	return b
+}
+
+// MappingMode sets the mode of the last mapping.
+func (b *ConfigBuilder) MappingMode(mode string) *ConfigBuilder {
+	if len(b.mappings) == 0 {
+		panic("MappingMode called with no mappings")
+	}
+
+	b.mappings[len(b.mappings)-1].mode = mode
+
+	return b
+}
+
+// MaxDelete sets the config-wide default max_delete.
+func (b *ConfigBuilder) MaxDelete(limit string) *ConfigBuilder {
+	b.maxDelete = limit
+
+	return b
+}
+
 // Variable adds a variable for substitution.
 func (b *ConfigBuilder) Variable(key, value string) *ConfigBuilder {
 	b.variables[key] = value
@@ -113,6 +148,10 @@ func (b *ConfigBuilder) Build() string {
 		}
 	}
 
+	if b.maxDelete != "" {
+		fmt.Fprintf(&result, "max_delete: %q\n", b.maxDelete)
+	}
+
 	result.WriteString("mappings:\n")
 
 	for _, m := range b.mappings {
@@ -167,6 +206,14 @@ func writeMapping(writer *strings.Builder, mapping mappingDef) {
 		}
 	}
 
+	if mapping.maxDelete != "" {
+		fmt.Fprintf(writer, "    max_delete: %q\n", mapping.maxDelete)
+	}
+
+	if mapping.mode != "" {
+		fmt.Fprintf(writer, "    mode: %q\n", mapping.mode)
+	}
+
 	if len(mapping.jobs) > 0 {
 		writer.WriteString("    jobs:\n")
 
@@ -196,6 +243,22 @@ func writeJob(writer *strings.Builder, job jobDef) {
 			fmt.Fprintf(writer, "          - %q\n", e)
 		}
 	}
+
+	if len(job.tags) > 0 {
+		writer.WriteString("        tags:\n")
+
+		for _, tag := range job.tags {
+			fmt.Fprintf(writer, "          - %q\n", tag)
+		}
+	}
+
+	if job.maxDelete != "" {
+		fmt.Fprintf(writer, "        max_delete: %q\n", job.maxDelete)
+	}
+
+	if job.mode != "" {
+		fmt.Fprintf(writer, "        mode: %q\n", job.mode)
+	}
 }
 
 // Enabled sets the enabled flag on a job.
@@ -212,3 +275,18 @@ func Delete(v bool) JobOpt {
 func Exclusions(v ...string) JobOpt {
 	return func(j *jobDef) { j.exclusions = v }
 }
+
+// Tags sets the tags on a job.
+func Tags(v ...string) JobOpt {
+	return func(j *jobDef) { j.tags = v }
+}
+
+// MaxDelete sets max_delete on a job.
+func MaxDelete(limit string) JobOpt {
+	return func(j *jobDef) { j.maxDelete = limit }
+}
+
+// Mode sets the mode of a job.
+func Mode(mode string) JobOpt {
+	return func(j *jobDef) { j.mode = mode }
+}
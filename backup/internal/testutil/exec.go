@@ -0,0 +1,54 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"backup-rsync/backup/internal"
+)
+
+// Line is a scripted line of command output.
+type Line struct {
+	Stream internal.OutputStream
+	Text   string
+}
+
+// StdoutLines converts text lines into scripted stdout lines.
+func StdoutLines(lines ...string) []Line {
+	scripted := make([]Line, 0, len(lines))
+	for line := range slices.Values(lines) {
+		scripted = append(scripted, Line{Stream: internal.Stdout, Text: line})
+	}
+
+	return scripted
+}
+
+// StderrLine creates a scripted stderr line.
+func StderrLine(text string) Line {
+	return Line{Stream: internal.Stderr, Text: text}
+}
+
+// FeedLines returns an Exec.Stream implementation that feeds the scripted lines to
+// the handler and then returns err. Use it with a mock's RunAndReturn.
+func FeedLines(err error, lines ...Line) func(context.Context, internal.LineHandler, string, ...string) error {
+	return func(_ context.Context, handler internal.LineHandler, _ string, _ ...string) error {
+		for line := range slices.Values(lines) {
+			handler(line.Stream, line.Text)
+		}
+
+		return err
+	}
+}
+
+// ExitError is a command error carrying an exit code, like *exec.ExitError.
+type ExitError int
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// ExitCode returns the exit code of the command.
+func (e ExitError) ExitCode() int {
+	return int(e)
+}
@@ -31,3 +31,8 @@ func NewTestJob(opts ...TestJobOpt) internal.Job {
 func WithEnabled(enabled bool) TestJobOpt {
 	return func(job *internal.Job) { job.Enabled = enabled }
 }
+
+// WithMaxDelete sets the job deletion limit.
+func WithMaxDelete(limit internal.DeleteLimit) TestJobOpt {
+	return func(job *internal.Job) { job.MaxDelete = &limit }
+}
@@ -0,0 +1,148 @@
+package testutil
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SMTPMessage is a message received by an SMTPServer.
+type SMTPMessage struct {
+	// Auth is the argument of the AUTH command, if the client authenticated.
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+// SMTPServer is a minimal SMTP server on localhost that records the messages it
+// receives. It offers PLAIN authentication, but neither STARTTLS nor any checks.
+type SMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []SMTPMessage
+}
+
+// NewSMTPServer starts an SMTPServer that is stopped when the test ends.
+func NewSMTPServer(t *testing.T) *SMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start SMTP server: %v", err)
+	}
+
+	server := &SMTPServer{listener: listener}
+
+	go server.serve()
+
+	t.Cleanup(func() { listener.Close() })
+
+	return server
+}
+
+// Host returns the host the server listens on.
+func (s *SMTPServer) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *SMTPServer) Port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	number, _ := strconv.Atoi(port)
+
+	return number
+}
+
+// Messages returns the messages received so far.
+func (s *SMTPServer) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SMTPMessage(nil), s.messages...)
+}
+
+func (s *SMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		if err == nil {
+			go s.handle(textproto.NewConn(conn))
+		}
+	}
+}
+
+func (s *SMTPServer) handle(conn *textproto.Conn) {
+	defer conn.Close()
+
+	var message SMTPMessage
+
+	_ = conn.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			_ = conn.PrintfLine("250-localhost")
+			_ = conn.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			message.Auth = arg
+			_ = conn.PrintfLine("235 authenticated")
+		case "MAIL":
+			message.From = smtpAddress(arg)
+			_ = conn.PrintfLine("250 ok")
+		case "RCPT":
+			message.To = append(message.To, smtpAddress(arg))
+			_ = conn.PrintfLine("250 ok")
+		case "DATA":
+			_ = conn.PrintfLine("354 end with .")
+
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			message.Data = string(data)
+			s.record(message)
+			message = SMTPMessage{Auth: message.Auth}
+
+			_ = conn.PrintfLine("250 queued")
+		case "QUIT":
+			_ = conn.PrintfLine("221 bye")
+
+			return
+		default:
+			_ = conn.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *SMTPServer) record(message SMTPMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, message)
+}
+
+// smtpAddress extracts the address from "FROM:<address>" or "TO:<address>".
+func smtpAddress(arg string) string {
+	_, address, _ := strings.Cut(arg, ":")
+	address, _, _ = strings.Cut(strings.TrimSpace(address), " ")
+
+	return strings.Trim(address, "<>")
+}
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+var (
+	ErrInvalidSample  = errors.New("invalid sample")
+	ErrVerifyMismatch = errors.New("backup does not match its source")
+)
+
+// VerifyResult records how a job was verified and the differences found.
+type VerifyResult struct {
+	// SamplePercent is the percentage of files checked, or zero when all were checked.
+	SamplePercent float64 `json:"sample_percent,omitempty"`
+	// SampledFiles is the number of files checked in a sample.
+	SampledFiles int `json:"sampled_files,omitempty"`
+	// Differences are the files of the target that are missing, extra or differ from
+	// the source.
+	Differences []Change `json:"differences"`
+}
+
+// VerifyCommand compares the target of each job with its source by checksum, running
+// rsync with --dry-run --checksum, and reports the files that differ.
+type VerifyCommand struct {
+	SharedCommand
+
+	// SamplePercent checks a random sample of this percentage of the source files.
+	// Zero checks all files.
+	SamplePercent float64
+	// Rand picks the sample. Nil uses the global source of math/rand.
+	Rand *rand.Rand
+	// Fs holds the log directory the samples are written to.
+	Fs afero.Fs
+}
+
+// NewVerifyCommand creates a VerifyCommand with the given dependencies.
+func NewVerifyCommand(binPath string, logPath string, shell Exec, output io.Writer) VerifyCommand {
+	return VerifyCommand{SharedCommand: NewSharedCommand(binPath, logPath, shell, output), Fs: afero.NewOsFs()}
+}
+
+// ParseSamplePercent parses a sample size such as "10%" or "2.5".
+func ParseSamplePercent(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || !(percent > 0 && percent <= 100) {
+		return 0, fmt.Errorf("%w: %q must be a percentage above 0 and up to 100", ErrInvalidSample, value)
+	}
+
+	return percent, nil
+}
+
+// Run verifies the job. Jobs in snapshot mode are verified against their latest snapshot.
+// Without differences, the job succeeds; with differences, its status is Mismatch.
+func (c VerifyCommand) Run(ctx context.Context, job Job) JobResult {
+	if job.Mode == SnapshotMode {
+		snapshot, err := c.Snapshots.Find(job.Target, "")
+		if err != nil {
+			return JobResult{Status: Failure, Reason: err.Error()}
+		}
+
+		job.Target = snapshot.Path
+	}
+
+	verification := &VerifyResult{Differences: []Change{}}
+	args := slices.Insert(ArgumentsForJob(job, "", true), 1, "--checksum")
+
+	if c.SamplePercent > 0 && c.SamplePercent < 100 {
+		filesFrom, sampled, result := c.sample(ctx, job)
+		if result.Status != Success {
+			return result
+		}
+
+		verification.SamplePercent, verification.SampledFiles = c.SamplePercent, sampled
+
+		// --files-from turns off --recursive, without which rsync rejects --delete.
+		args = slices.DeleteFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--delete") })
+		args = slices.Insert(args, 1, "--files-from="+filesFrom)
+	}
+
+	result := c.RunWithArgsAndCaptureOutput(ctx, job, args, c.JobLogPath(job))
+	if result.Status != Success && result.Status != Warning {
+		return result
+	}
+
+	for change := range slices.Values(result.Changes) {
+		if change.differs() {
+			verification.Differences = append(verification.Differences, change)
+		}
+	}
+
+	result.Verification = verification
+
+	if differences := len(verification.Differences); differences > 0 {
+		result.Status = Mismatch
+		result.Reason = fmt.Sprintf("%d files differ", differences)
+
+		if differences == 1 {
+			result.Reason = "1 file differs"
+		}
+	}
+
+	return result
+}
+
+// differs reports whether change means the target does not match the source: a file or
+// symlink that is missing, extra or has other content. Attribute-only changes, such as
+// a different modification time, and directories are not differences.
+func (change Change) differs() bool {
+	return change.Type != ChangeAttributes && change.Type != ChangeDirectory
+}
+
+// sample lists the regular files of the job's source and writes a random sample of
+// SamplePercent of them to a file in the log directory. It returns the path of the file
+// for --files-from and the number of files in the sample.
+func (c VerifyCommand) sample(ctx context.Context, job Job) (string, int, JobResult) {
+	args := []string{"--list-only", "--recursive"}
+
+	for rule := range slices.Values(job.FilterRules()) {
+		args = append(args, rule.Argument())
+	}
+
+	if remote := job.SourceRemote; remote != nil {
+		args = append(args, "-e", remote.SSHCommand())
+	}
+
+	var files []string
+
+	result := c.stream(ctx, append(args, job.SourceSpec()), func(stream OutputStream, line string) {
+		if name, ok := parseListedFile(line); stream == Stdout && ok {
+			files = append(files, name)
+		}
+	})
+	if result.Status != Success {
+		return "", 0, result
+	}
+
+	shuffle := rand.Shuffle
+	if c.Rand != nil {
+		shuffle = c.Rand.Shuffle
+	}
+
+	shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+
+	files = files[:int(math.Ceil(float64(len(files))*c.SamplePercent/100))] //nolint:mnd // percent
+	slices.Sort(files)
+
+	path := filepath.Join(c.BaseLogPath, "job-"+job.Name+".sample")
+
+	err := afero.WriteFile(c.Fs, path, []byte(strings.Join(files, "\n")+"\n"), LogFilePermission)
+	if err != nil {
+		return "", 0, JobResult{Status: Failure, Reason: fmt.Sprintf("writing sample: %v", err)}
+	}
+
+	return path, len(files), result
+}
+
+// parseListedFile returns the name of a regular file in a line of rsync --list-only
+// output, such as "-rw-r--r--          1,234 2026/10/17 03:00:00 docs/report.pdf".
+func parseListedFile(line string) (string, bool) {
+	if !strings.HasPrefix(line, "-") {
+		return "", false
+	}
+
+	const fields = 4 // permissions, size, date and time precede the name
+
+	rest := line
+
+	for range fields {
+		var found bool
+
+		_, rest, found = strings.Cut(strings.TrimLeft(rest, " "), " ")
+		if !found {
+			return "", false
+		}
+	}
+
+	return rest, rest != ""
+}
+
+// ReportJobStatus reports the status of a job followed by its differences.
+func (c VerifyCommand) ReportJobStatus(jobName string, result JobResult, logger *slog.Logger) {
+	c.SharedCommand.ReportJobStatus(jobName, result, logger)
+
+	if result.Verification == nil {
+		return
+	}
+
+	if sample := result.Verification; sample.SamplePercent > 0 {
+		fmt.Fprintf(c.Output, "Sample [%s]: %d files (%s%%)\n", jobName, sample.SampledFiles,
+			strconv.FormatFloat(sample.SamplePercent, 'f', -1, 64))
+	}
+
+	for change := range slices.Values(result.Verification.Differences) {
+		line := fmt.Sprintf("Differs [%s]: %s %s", jobName, change.difference(), change.Path)
+		logger.Info(line)
+		fmt.Fprintln(c.Output, line)
+	}
+}
+
+// difference describes how the target differs from the source in change.
+func (change Change) difference() string {
+	switch {
+	case change.Type == ChangeDeleted:
+		return "extra"
+	case strings.Trim(change.Itemize[2:], "+") == "":
+		return "missing"
+	default:
+		return "changed"
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package internal
+
+// IsMountPoint is not supported on this platform.
+func (OsVolumeInfo) IsMountPoint(_ string) (bool, error) {
+	return false, ErrVolumeInfoUnsupported
+}
+
+// FreeSpace is not supported on this platform.
+func (OsVolumeInfo) FreeSpace(_ string) (int64, error) {
+	return 0, ErrVolumeInfoUnsupported
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd
+
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+)
+
+// IsMountPoint reports whether path is the root of a mounted filesystem: it lives
+// on another device than its parent directory, or is the root directory itself.
+func (OsVolumeInfo) IsMountPoint(path string) (bool, error) {
+	path = filepath.Clean(path)
+
+	var self, parent syscall.Stat_t
+
+	if err := syscall.Stat(path, &self); err != nil {
+		return false, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	if err := syscall.Stat(filepath.Dir(path), &parent); err != nil {
+		return false, fmt.Errorf("stat %s: %w", filepath.Dir(path), err)
+	}
+
+	return self.Dev != parent.Dev || self.Ino == parent.Ino, nil
+}
+
+// FreeSpace returns the bytes available to unprivileged users on the filesystem of path.
+func (OsVolumeInfo) FreeSpace(path string) (int64, error) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("statfs %s: %w", path, err)
+	}
+
+	//nolint:gosec,unconvert // field types differ between platforms
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
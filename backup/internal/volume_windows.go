@@ -0,0 +1,38 @@
+//go:build windows
+
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// IsMountPoint reports whether path is the root of a volume, e.g. `D:\`.
+// Volumes mounted into folders are not detected.
+func (OsVolumeInfo) IsMountPoint(path string) (bool, error) {
+	cleaned := filepath.Clean(path)
+
+	return cleaned == filepath.VolumeName(cleaned)+`\`, nil
+}
+
+// FreeSpace returns the bytes available to the current user on the volume of path.
+func (OsVolumeInfo) FreeSpace(path string) (int64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, fmt.Errorf("free space of %s: %w", path, err)
+	}
+
+	var available uint64
+
+	ret, _, callErr := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&available)), 0, 0)
+	if ret == 0 {
+		return 0, fmt.Errorf("free space of %s: %w", path, callErr)
+	}
+
+	return int64(available), nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"backup-rsync/backup/cmd"
+	"errors"
 	"os"
 )
 
@@ -10,6 +11,11 @@ func main() {
 
 	err := rootCmd.Execute()
 	if err != nil {
+		var exitErr *cmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 }